@@ -4,11 +4,18 @@ import (
 	"article-analysis/internal/config"
 	"article-analysis/internal/handler"
 	"article-analysis/internal/middleware"
+	"article-analysis/internal/model"
 	"article-analysis/internal/repository"
 	"article-analysis/internal/service"
 	"article-analysis/pkg/logger"
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 
@@ -61,23 +68,55 @@ func main() {
 	// 初始化依赖
 	articleRepo := repository.NewArticleRepository(db)
 	analysisRepo := repository.NewAnalysisRepository(db)
+	taskRepo := repository.NewTaskRepository(db)
 
 	articleService := service.NewArticleService(articleRepo, log)
-	analysisService := service.NewAnalysisService(analysisRepo, articleRepo, cfg, log)
+	analysisService := service.NewAnalysisService(analysisRepo, articleRepo, taskRepo, cfg, log)
+	eventBus := service.NewEventBus()
+	articleService.SetEventBus(eventBus)
+	analysisService.SetEventBus(eventBus)
+
+	// 恢复上次运行中断的任务，再启动分析工作池
+	if err := analysisService.RecoverOnStartup(); err != nil {
+		log.Error("恢复中断的分析任务失败", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	analysisService.Start(ctx)
 
 	articleHandler := handler.NewArticleHandler(articleService)
 	analysisHandler := handler.NewAnalysisHandler(analysisService)
+	eventHandler := handler.NewEventHandler(eventBus)
 
 	// 设置路由
-	router := setupRouter(articleHandler, analysisHandler, log)
+	if len(cfg.Server.AdminKeys) == 0 {
+		log.Warn("未配置 server.admin_keys，管理接口不校验调用方")
+	}
+	if len(cfg.Server.EditorKeys)+len(cfg.Server.AdminKeys) == 0 {
+		log.Warn("未配置 server.editor_keys，所有调用方均可修改、审核分析结果并看到未审核的结果")
+	}
+	router := setupRouter(cfg, articleHandler, analysisHandler, eventHandler, log)
 
 	// 启动服务
 	addr := fmt.Sprintf(":%d", cfg.Server.Port)
-	log.Info("服务器启动", zap.String("address", addr))
-	if err := router.Run(addr); err != nil {
-		log.Fatal("服务器启动失败", zap.Error(err))
-		os.Exit(1)
+	server := &http.Server{Addr: addr, Handler: router}
+	go func() {
+		log.Info("服务器启动", zap.String("address", addr))
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("服务器启动失败", zap.Error(err))
+		}
+	}()
+
+	// 收到退出信号后停止接收请求，中断执行中的任务使其重新排队
+	<-ctx.Done()
+	log.Info("服务器正在关闭")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error("服务器关闭失败", err)
 	}
+	analysisService.Wait()
+	log.Info("服务器已关闭")
 }
 
 func initDB(cfg *config.Config) (*gorm.DB, error) {
@@ -115,16 +154,40 @@ func autoMigrate(db *gorm.DB) error {
 	return db.AutoMigrate(
 		&repository.Article{},
 		&repository.ArticleAnalysis{},
+		&model.AnalysisTask{},
+		&model.AnalysisTaskAttempt{},
+		&model.AnalysisBatch{},
+		&model.BudgetAlert{},
+		&model.AnalysisCache{},
+		&model.AnalysisSchema{},
+		&model.AnalysisRevision{},
 	)
 }
 
-func setupRouter(articleHandler *handler.ArticleHandler, analysisHandler *handler.AnalysisHandler, log *logger.Logger) *gin.Engine {
+// editorKeys 返回可编辑分析结果的 API Key：编辑和管理员的密钥
+func editorKeys(cfg *config.Config) []string {
+	keys := append([]string{}, cfg.Server.EditorKeys...)
+	return append(keys, cfg.Server.AdminKeys...)
+}
+
+// knownAPIKeys 返回配置中出现的全部 API Key，只有这些密钥能识别出调用方
+func knownAPIKeys(cfg *config.Config) []string {
+	keys := editorKeys(cfg)
+	for _, rb := range cfg.Budget.Requesters {
+		keys = append(keys, rb.APIKey)
+	}
+	return keys
+}
+
+func setupRouter(cfg *config.Config, articleHandler *handler.ArticleHandler, analysisHandler *handler.AnalysisHandler, eventHandler *handler.EventHandler, log *logger.Logger) *gin.Engine {
 	router := gin.New()
 
 	// 全局中间件
 	router.Use(middleware.Logger(log))
 	router.Use(gin.Recovery())
 	router.Use(middleware.CORS())
+	router.Use(middleware.APIKeys(knownAPIKeys(cfg)))
+	router.Use(middleware.Editor(editorKeys(cfg)))
 
 	// 健康检查端点
 	router.GET("/health", func(c *gin.Context) {
@@ -152,10 +215,55 @@ func setupRouter(articleHandler *handler.ArticleHandler, analysisHandler *handle
 			articles.DELETE("/:id", articleHandler.DeleteArticle)
 			articles.POST("/:id/analyze", analysisHandler.AnalyzeArticle)
 			articles.GET("/:id/analysis", analysisHandler.GetAnalysisResult)
+			articles.GET("/:id/analysis/estimate", analysisHandler.EstimateAnalysis)
+			articles.PUT("/:id/analysis", middleware.EditorOnly(), analysisHandler.UpdateAnalysis)
+			articles.PUT("/:id/analysis/status", middleware.EditorOnly(), analysisHandler.SetReviewStatus)
+			articles.GET("/:id/analysis/revisions", middleware.EditorOnly(), analysisHandler.ListRevisions)
+			articles.POST("/:id/analyze/compare", analysisHandler.AnalyzeWithProfiles)
+			articles.GET("/:id/analyses", analysisHandler.ListAnalyses)
+			articles.GET("/:id/analyses/compare", middleware.EditorOnly(), analysisHandler.CompareAnalyses)
+			articles.GET("/:id/analyses/:analysis_id", analysisHandler.GetAnalysis)
+			articles.PUT("/:id/analyses/:analysis_id/current", middleware.EditorOnly(), analysisHandler.SetCurrentAnalysis)
 		}
 
+		// 文章和分析状态变化的实时推送
+		api.GET("/ws", eventHandler.ServeWS)
+
 		// 分析任务状态
 		api.GET("/analysis/status/:task_id", analysisHandler.GetAnalysisStatus)
+
+		// 分析任务管理
+		tasks := api.Group("/analysis/tasks")
+		{
+			tasks.DELETE("/:id", analysisHandler.CancelTask)
+			tasks.POST("/:id/cancel", analysisHandler.CancelTask)
+			tasks.GET("/:id/events", analysisHandler.TaskEvents)
+		}
+
+		// 批量分析
+		api.POST("/analysis/batch", analysisHandler.AnalyzeBatch)
+		api.GET("/analysis/batch/:batch_id", analysisHandler.GetBatchStatus)
+
+		// 模型配置
+		api.GET("/analysis/profiles", analysisHandler.ListProfiles)
+		api.GET("/analysis/prompts", analysisHandler.ListPrompts)
+		api.GET("/analysis/genres", analysisHandler.ListGenres)
+
+		// 分析方案
+		api.GET("/analysis/schemas", analysisHandler.ListSchemas)
+		api.GET("/analysis/schemas/:name", analysisHandler.GetSchema)
+
+		// 用量统计
+		api.GET("/usage/report", analysisHandler.GetUsageReport)
+		api.GET("/budget", analysisHandler.GetBudgetStatus)
+
+		// 管理接口
+		admin := api.Group("/admin", middleware.AdminOnly(cfg.Server.AdminKeys))
+		{
+			admin.POST("/schemas", analysisHandler.CreateSchema)
+			admin.PUT("/schemas/:name", analysisHandler.UpdateSchema)
+			admin.DELETE("/schemas/:name", analysisHandler.DeleteSchema)
+		}
 	}
 
 	return router
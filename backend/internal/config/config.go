@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -10,6 +11,8 @@ type Config struct {
 	Database DatabaseConfig `mapstructure:"database"`
 	Server   ServerConfig   `mapstructure:"server"`
 	OpenAI   OpenAIConfig   `mapstructure:"openai"`
+	Analysis AnalysisConfig `mapstructure:"analysis"`
+	Budget   BudgetConfig   `mapstructure:"budget"`
 	Log      LogConfig      `mapstructure:"log"`
 }
 
@@ -23,14 +26,181 @@ type DatabaseConfig struct {
 }
 
 type ServerConfig struct {
-	Port int    `mapstructure:"port"`
-	Mode string `mapstructure:"mode"`
+	Port       int      `mapstructure:"port"`
+	Mode       string   `mapstructure:"mode"`
+	AdminKeys  []string `mapstructure:"admin_keys"`  // 允许调用管理接口的 X-API-Key，为空时不校验
+	EditorKeys []string `mapstructure:"editor_keys"` // 允许修改和审核分析结果的 X-API-Key，管理员也可以；均为空时不区分编辑和学生
 }
 
 type OpenAIConfig struct {
-	APIKey  string `mapstructure:"api_key"`
-	APIBase string `mapstructure:"api_base"`
-	Model   string `mapstructure:"model"`
+	Provider       string               `mapstructure:"provider"` // 模型服务类型: openai / ollama / anthropic
+	APIKey         string               `mapstructure:"api_key"`
+	APIBase        string               `mapstructure:"api_base"`
+	Model          string               `mapstructure:"model"`
+	MaxRetries     int                  `mapstructure:"max_retries"`      // 可重试错误的最大重试次数
+	RetryBaseDelay int                  `mapstructure:"retry_base_delay"` // 首次重试的基础等待（毫秒），之后按指数增长
+	RetryMaxDelay  int                  `mapstructure:"retry_max_delay"`  // 单次重试等待上限（毫秒），Retry-After 不受此限制
+	DefaultProfile string               `mapstructure:"default_profile"`  // 未指定时使用的模型配置名
+	Profiles       []ModelProfile       `mapstructure:"profiles"`
+	Fallbacks      []string             `mapstructure:"fallbacks"`    // 默认降级链：主模型服务不可用时按顺序尝试的模型配置名
+	InputPrice     float64              `mapstructure:"input_price"`  // 未配置 profiles 时的输入价格（每百万 token）
+	OutputPrice    float64              `mapstructure:"output_price"` // 未配置 profiles 时的输出价格（每百万 token）
+	Currency       string               `mapstructure:"currency"`     // 价格币种
+	JSONMode       string               `mapstructure:"json_mode"`    // 结构化输出方式，模型配置未填写时沿用
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+}
+
+// CircuitBreakerConfig 模型服务熔断配置
+type CircuitBreakerConfig struct {
+	FailureThreshold int `mapstructure:"failure_threshold"` // 连续失败多少次后熔断
+	OpenDuration     int `mapstructure:"open_duration"`     // 熔断持续时间（秒），之后放行一次探测请求
+}
+
+// ImplicitProfileName 未配置 profiles 时，由顶层字段生成的模型配置名
+const ImplicitProfileName = "default"
+
+// ModelProfile 命名的模型配置，分析时可按名称选择
+type ModelProfile struct {
+	Name        string   `mapstructure:"name"`
+	Provider    string   `mapstructure:"provider"`
+	APIBase     string   `mapstructure:"api_base"`
+	APIKey      string   `mapstructure:"api_key"`
+	Model       string   `mapstructure:"model"`
+	Temperature *float32 `mapstructure:"temperature"`  // 未配置时为 0.7
+	MaxTokens   int      `mapstructure:"max_tokens"`   // 0 表示由服务端决定
+	Timeout     int      `mapstructure:"timeout"`      // 单次分析超时（秒），0 表示使用 analysis.timeout
+	ChunkTokens int      `mapstructure:"chunk_tokens"` // 分段上限，0 表示使用 analysis.chunk_tokens，应按模型上下文窗口设置
+	Fallbacks   []string `mapstructure:"fallbacks"`    // 该配置专用的降级链，未配置时使用 openai.fallbacks
+	InputPrice  float64  `mapstructure:"input_price"`  // 输入价格（每百万 token），用于费用预估
+	OutputPrice float64  `mapstructure:"output_price"` // 输出价格（每百万 token）
+	JSONMode    string   `mapstructure:"json_mode"`    // 结构化输出方式: auto / schema / object / tool / off，未配置时为 auto
+}
+
+// GetTemperature 返回配置的温度，未配置时为 0.7
+func (p ModelProfile) GetTemperature() float32 {
+	if p.Temperature == nil {
+		return 0.7
+	}
+	return *p.Temperature
+}
+
+// ResolvedProfiles 返回全部模型配置
+//
+// 未配置 profiles 时由顶层 provider/api_key/api_base/model 生成名为 default 的配置；
+// profile 未填写服务类型、地址或密钥时，服务类型相同则沿用顶层配置。
+func (c OpenAIConfig) ResolvedProfiles() []ModelProfile {
+	if len(c.Profiles) == 0 {
+		return []ModelProfile{{
+			Name:        ImplicitProfileName,
+			Provider:    c.Provider,
+			APIBase:     c.APIBase,
+			APIKey:      c.APIKey,
+			Model:       c.Model,
+			InputPrice:  c.InputPrice,
+			OutputPrice: c.OutputPrice,
+			JSONMode:    c.JSONMode,
+		}}
+	}
+
+	profiles := make([]ModelProfile, 0, len(c.Profiles))
+	for _, p := range c.Profiles {
+		if p.Provider == "" {
+			p.Provider = c.Provider
+		}
+		if strings.EqualFold(p.Provider, c.Provider) {
+			if p.APIBase == "" {
+				p.APIBase = c.APIBase
+			}
+			if p.APIKey == "" {
+				p.APIKey = c.APIKey
+			}
+		}
+		if p.Model == "" {
+			p.Model = c.Model
+		}
+		if p.JSONMode == "" {
+			p.JSONMode = c.JSONMode
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles
+}
+
+// DefaultProfileName 返回默认模型配置名，未指定时取第一个
+func (c OpenAIConfig) DefaultProfileName() string {
+	if c.DefaultProfile != "" {
+		return c.DefaultProfile
+	}
+	return c.ResolvedProfiles()[0].Name
+}
+
+// FallbackChain 返回使用指定模型配置时依次尝试的配置名，第一个为该配置本身
+func (c OpenAIConfig) FallbackChain(name string) []string {
+	fallbacks := c.Fallbacks
+	for _, p := range c.Profiles {
+		if p.Name == name && p.Fallbacks != nil {
+			fallbacks = p.Fallbacks
+		}
+	}
+
+	chain := []string{name}
+	seen := map[string]bool{name: true}
+	for _, fallback := range fallbacks {
+		if !seen[fallback] {
+			seen[fallback] = true
+			chain = append(chain, fallback)
+		}
+	}
+	return chain
+}
+
+// AnalysisConfig 分析任务队列配置
+type AnalysisConfig struct {
+	MaxConcurrency int `mapstructure:"max_concurrency"` // 同时执行的分析任务数上限
+	PollInterval   int `mapstructure:"poll_interval"`   // 空闲时轮询任务表的间隔（秒）
+	Timeout        int `mapstructure:"timeout"`         // 单个任务的执行超时（秒），长文分段分析时按模型调用次数累计
+	LeaseDuration  int `mapstructure:"lease_duration"`  // 任务租约时长（秒），超过未续约视为执行者已失联
+	Heartbeat      int `mapstructure:"heartbeat"`       // 续约间隔（秒），应明显小于租约时长
+	MaxAttempts    int `mapstructure:"max_attempts"`    // 中断的任务最多执行次数，超过后标记为失败
+	MaxBatchSize   int `mapstructure:"max_batch_size"`  // 单次批量提交的文章数上限
+	ChunkTokens    int `mapstructure:"chunk_tokens"`    // 单次送入模型的文章内容 token 上限，超过时分段分析后合并
+	RepairAttempts int `mapstructure:"repair_attempts"` // 模型输出未通过校验时要求其修正的次数，0 表示不修正直接失败
+
+	PromptDir     string `mapstructure:"prompt_dir"`     // 提示词模板目录，其中的 <版本>.tmpl 文件作为可选的提示词版本
+	DefaultPrompt string `mapstructure:"default_prompt"` // 未指定时使用的提示词版本，为空时使用内置版本
+
+	GenrePrompts map[string]string `mapstructure:"genre_prompts"` // 体裁使用的提示词版本，覆盖内置的对应关系
+
+	MaxEstimatedTokens int     `mapstructure:"max_estimated_tokens"` // 单次分析预估 token 数（输入+输出）上限，0 表示不限制
+	MaxEstimatedCost   float64 `mapstructure:"max_estimated_cost"`   // 单次分析预估费用上限，0 表示不限制
+}
+
+// BudgetConfig 模型用量预算，提交分析前检查，额度为 0 表示不限制
+type BudgetConfig struct {
+	WarningThreshold float64           `mapstructure:"warning_threshold"` // 用量达到额度的该比例时产生预警
+	Global           BudgetLimits      `mapstructure:"global"`            // 全部调用方合计的额度
+	PerRequester     BudgetLimits      `mapstructure:"per_requester"`     // 未单独配置的调用方各自的额度
+	Requesters       []RequesterBudget `mapstructure:"requesters"`
+}
+
+// BudgetLimits 按日、按月的 token 与费用额度
+type BudgetLimits struct {
+	DailyTokens   int64   `mapstructure:"daily_tokens"`
+	MonthlyTokens int64   `mapstructure:"monthly_tokens"`
+	DailyCost     float64 `mapstructure:"daily_cost"`
+	MonthlyCost   float64 `mapstructure:"monthly_cost"`
+}
+
+// IsZero 是否未设置任何额度
+func (l BudgetLimits) IsZero() bool {
+	return l.DailyTokens <= 0 && l.MonthlyTokens <= 0 && l.DailyCost <= 0 && l.MonthlyCost <= 0
+}
+
+// RequesterBudget 单个调用方的额度，按请求头 X-API-Key 识别
+type RequesterBudget struct {
+	User         string `mapstructure:"user"` // 调用方名称，仅作说明，不参与识别
+	APIKey       string `mapstructure:"api_key"`
+	BudgetLimits `mapstructure:",squash"`
 }
 
 type LogConfig struct {
@@ -65,8 +235,28 @@ func LoadConfig() (*Config, error) {
 	viper.SetDefault("server.port", 8080)
 	viper.SetDefault("server.mode", "debug")
 
+	viper.SetDefault("openai.provider", "openai")
 	viper.SetDefault("openai.api_base", "https://api.moonshot.cn/v1")
 	viper.SetDefault("openai.model", "kimi-k2-0905-preview")
+	viper.SetDefault("openai.max_retries", 3)
+	viper.SetDefault("openai.retry_base_delay", 1000)
+	viper.SetDefault("openai.retry_max_delay", 30000)
+	viper.SetDefault("openai.currency", "CNY")
+	viper.SetDefault("openai.json_mode", "auto")
+	viper.SetDefault("openai.circuit_breaker.failure_threshold", 5)
+	viper.SetDefault("openai.circuit_breaker.open_duration", 30)
+
+	viper.SetDefault("analysis.max_concurrency", 2)
+	viper.SetDefault("analysis.poll_interval", 2)
+	viper.SetDefault("analysis.timeout", 120)
+	viper.SetDefault("analysis.lease_duration", 60)
+	viper.SetDefault("analysis.heartbeat", 15)
+	viper.SetDefault("analysis.max_attempts", 3)
+	viper.SetDefault("analysis.max_batch_size", 500)
+	viper.SetDefault("analysis.chunk_tokens", 12000)
+	viper.SetDefault("analysis.repair_attempts", 2)
+
+	viper.SetDefault("budget.warning_threshold", 0.8)
 
 	viper.SetDefault("log.level", "info")
 
@@ -77,6 +267,8 @@ func LoadConfig() (*Config, error) {
 	viper.BindEnv("openai.api_key", "OPENAI_API_KEY")
 	viper.BindEnv("openai.api_base", "OPENAI_API_BASE")
 	viper.BindEnv("openai.model", "OPENAI_MODEL")
+	viper.BindEnv("openai.provider", "LLM_PROVIDER")
+	viper.BindEnv("analysis.max_concurrency", "ANALYSIS_MAX_CONCURRENCY")
 
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
@@ -59,4 +59,28 @@ func TestLoadConfig_NoEnvVariable(t *testing.T) {
 	// 验证使用默认值
 	assert.Equal(t, "", config.OpenAI.APIKey, "没有环境变量时API密钥应该为空")
 	assert.Equal(t, "https://api.moonshot.cn/v1", config.OpenAI.APIBase, "没有环境变量时应该使用默认API基础URL")
-}
\ No newline at end of file
+}
+
+func TestOpenAIConfig_ResolvedProfiles(t *testing.T) {
+	cfg := OpenAIConfig{Provider: "openai", APIKey: "top-key", APIBase: "https://api.example.com/v1", Model: "base-model"}
+
+	profiles := cfg.ResolvedProfiles()
+	assert.Len(t, profiles, 1)
+	assert.Equal(t, ImplicitProfileName, profiles[0].Name)
+	assert.Equal(t, "base-model", profiles[0].Model)
+	assert.Equal(t, ImplicitProfileName, cfg.DefaultProfileName())
+
+	cfg.Profiles = []ModelProfile{
+		{Name: "fast", Model: "small-model"},
+		{Name: "local", Provider: "ollama", Model: "qwen2"},
+	}
+	cfg.DefaultProfile = "local"
+
+	profiles = cfg.ResolvedProfiles()
+	assert.Len(t, profiles, 2)
+	assert.Equal(t, "top-key", profiles[0].APIKey, "同一提供商的配置应继承顶层密钥")
+	assert.Equal(t, "https://api.example.com/v1", profiles[0].APIBase)
+	assert.Equal(t, "", profiles[1].APIKey, "不同提供商的配置不应继承顶层密钥")
+	assert.Equal(t, float32(0.7), profiles[1].GetTemperature())
+	assert.Equal(t, "local", cfg.DefaultProfileName())
+}
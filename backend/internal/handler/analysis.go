@@ -1,8 +1,12 @@
 package handler
 
 import (
+	"article-analysis/internal/middleware"
 	"article-analysis/internal/model"
 	"article-analysis/internal/service"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"regexp"
 	"strconv"
@@ -35,16 +39,25 @@ func (h *AnalysisHandler) AnalyzeArticle(c *gin.Context) { // ignore_security_al
 		return
 	}
 
-	task, err := h.analysisService.AnalyzeArticle(id)
+	opts, err := bindAnalyzeOptions(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, model.ApiResponse{
-			Code:      422,
-			Message:   err.Error(),
+			Code:      400,
+			Message:   "参数错误：" + err.Error(),
 			Timestamp: time.Now().Unix(),
 		})
 		return
 	}
 
+	task, err := h.analysisService.AnalyzeArticle(id, opts)
+	if err != nil {
+		if respondBudgetExhausted(c, err) {
+			return
+		}
+		respondAnalysisError(c, err)
+		return
+	}
+
 	c.JSON(http.StatusOK, model.ApiResponse{
 		Code:    200,
 		Message: "分析任务已提交",
@@ -56,6 +69,82 @@ func (h *AnalysisHandler) AnalyzeArticle(c *gin.Context) { // ignore_security_al
 	})
 }
 
+// analyzeRequest 提交分析时可选的请求体，查询参数同名时优先
+type analyzeRequest struct {
+	Profile string `json:"profile"`
+	Prompt  string `json:"prompt_version"` // 提示词版本，为空时使用默认版本
+	Schema  string `json:"schema"`         // 分析方案，为空时使用内置方案
+	Genre   string `json:"genre"`          // 文章体裁，为空时自动识别
+	Force   bool   `json:"force"`          // 忽略缓存重新分析
+}
+
+// bindAnalyzeOptions 从查询参数或请求体中读取分析选项
+func bindAnalyzeOptions(c *gin.Context) (service.AnalyzeOptions, error) {
+	var req analyzeRequest
+	if c.Request.ContentLength != 0 && strings.Contains(c.ContentType(), "json") {
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			return service.AnalyzeOptions{}, err
+		}
+	}
+	if profile := c.Query("profile"); profile != "" {
+		req.Profile = profile
+	}
+	if prompt := c.Query("prompt_version"); prompt != "" {
+		req.Prompt = prompt
+	}
+	if schema := c.Query("schema"); schema != "" {
+		req.Schema = schema
+	}
+	if genre := c.Query("genre"); genre != "" {
+		req.Genre = genre
+	}
+	if force := c.Query("force"); force != "" {
+		value, err := strconv.ParseBool(force)
+		if err != nil {
+			return service.AnalyzeOptions{}, errors.New("force 参数应为 true 或 false")
+		}
+		req.Force = value
+	}
+	return service.AnalyzeOptions{
+		Profile:   strings.TrimSpace(req.Profile),
+		Requester: requesterFrom(c),
+		Force:     req.Force,
+		Prompt:    strings.TrimSpace(req.Prompt),
+		Schema:    strings.TrimSpace(req.Schema),
+		Genre:     strings.TrimSpace(req.Genre),
+	}, nil
+}
+
+// ListProfiles 获取可用的模型配置
+func (h *AnalysisHandler) ListProfiles(c *gin.Context) {
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "success",
+		Data:      h.analysisService.ListProfiles(),
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// ListPrompts 获取可用的提示词版本
+func (h *AnalysisHandler) ListPrompts(c *gin.Context) {
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "success",
+		Data:      h.analysisService.ListPrompts(),
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// ListGenres 获取可选的文章体裁及其使用的提示词版本
+func (h *AnalysisHandler) ListGenres(c *gin.Context) {
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "success",
+		Data:      h.analysisService.ListGenres(),
+		Timestamp: time.Now().Unix(),
+	})
+}
+
 // GetAnalysisResult 获取分析结果
 func (h *AnalysisHandler) GetAnalysisResult(c *gin.Context) {
 	idStr := c.Param("id")
@@ -69,7 +158,12 @@ func (h *AnalysisHandler) GetAnalysisResult(c *gin.Context) {
 		return
 	}
 
-	result, err := h.analysisService.GetAnalysisResult(id)
+	// 学生只能看到审核通过的结果
+	getResult := h.analysisService.GetAnalysisResult
+	if !middleware.IsEditor(c) {
+		getResult = h.analysisService.GetVisibleAnalysis
+	}
+	result, err := getResult(id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, model.ApiResponse{
 			Code:      404,
@@ -79,13 +173,7 @@ func (h *AnalysisHandler) GetAnalysisResult(c *gin.Context) {
 		return
 	}
 
-	// 格式化分析结果字段
-	if result != nil {
-		result.CoreViewpoints = formatAnalysisText(result.CoreViewpoints)
-		result.FileStructure = formatAnalysisText(result.FileStructure)
-		result.AuthorThoughts = formatAnalysisText(result.AuthorThoughts)
-		result.RelatedMaterials = formatAnalysisText(result.RelatedMaterials)
-	}
+	formatLegacyAnalysis(result)
 
 	c.JSON(http.StatusOK, model.ApiResponse{
 		Code:      200,
@@ -95,6 +183,43 @@ func (h *AnalysisHandler) GetAnalysisResult(c *gin.Context) {
 	})
 }
 
+// EstimateAnalysis 预估文章分析的 token 用量与费用，查询参数与提交分析时相同
+func (h *AnalysisHandler) EstimateAnalysis(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   "文章ID格式错误",
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	opts, err := bindAnalyzeOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   "参数错误：" + err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	estimate, err := h.analysisService.EstimateAnalysis(id, opts)
+	if err != nil {
+		respondAnalysisError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "success",
+		Data:      estimate,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
 // GetAnalysisStatus 获取分析任务状态
 func (h *AnalysisHandler) GetAnalysisStatus(c *gin.Context) {
 	taskID := c.Param("task_id")
@@ -117,6 +242,156 @@ func (h *AnalysisHandler) GetAnalysisStatus(c *gin.Context) {
 	})
 }
 
+// CancelTask 取消分析任务
+func (h *AnalysisHandler) CancelTask(c *gin.Context) {
+	taskID := c.Param("id")
+
+	if err := h.analysisService.CancelTask(taskID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrTaskNotFound):
+			c.JSON(http.StatusNotFound, model.ApiResponse{
+				Code:      404,
+				Message:   err.Error(),
+				Timestamp: time.Now().Unix(),
+			})
+		case errors.Is(err, service.ErrTaskFinished):
+			c.JSON(http.StatusConflict, model.ApiResponse{
+				Code:      409,
+				Message:   err.Error(),
+				Timestamp: time.Now().Unix(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, model.ApiResponse{
+				Code:      500,
+				Message:   err.Error(),
+				Timestamp: time.Now().Unix(),
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:    200,
+		Message: "任务已取消",
+		Data: map[string]interface{}{
+			"task_id": taskID,
+			"status":  model.TaskStatusCancelled,
+		},
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// AnalyzeBatch 批量提交文章分析
+func (h *AnalysisHandler) AnalyzeBatch(c *gin.Context) {
+	var req struct {
+		Profile    string        `json:"profile"`
+		Prompt     string        `json:"prompt_version"`
+		Schema     string        `json:"schema"`
+		Genre      string        `json:"genre"`
+		Force      bool          `json:"force"`
+		ArticleIDs []json.Number `json:"article_ids"`
+		Filter     *struct {
+			Keyword        string `json:"keyword"`
+			Author         string `json:"author"`
+			OnlyUnanalyzed bool   `json:"only_unanalyzed"`
+		} `json:"filter"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   "参数错误：" + err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	if len(req.ArticleIDs) == 0 && req.Filter == nil {
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   "请提供文章ID列表或筛选条件",
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	batchReq := &service.BatchRequest{Options: service.AnalyzeOptions{
+		Profile:   strings.TrimSpace(req.Profile),
+		Requester: requesterFrom(c),
+		Force:     req.Force,
+		Prompt:    strings.TrimSpace(req.Prompt),
+		Schema:    strings.TrimSpace(req.Schema),
+		Genre:     strings.TrimSpace(req.Genre),
+	}}
+	if len(req.ArticleIDs) > 0 {
+		for _, raw := range req.ArticleIDs {
+			id, err := strconv.ParseUint(raw.String(), 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, model.ApiResponse{
+					Code:      400,
+					Message:   "文章ID格式错误",
+					Timestamp: time.Now().Unix(),
+				})
+				return
+			}
+			batchReq.ArticleIDs = append(batchReq.ArticleIDs, id)
+		}
+	} else {
+		batchReq.UseFilter = true
+		batchReq.Keyword = req.Filter.Keyword
+		batchReq.Author = req.Filter.Author
+		batchReq.OnlyUnanalyzed = req.Filter.OnlyUnanalyzed
+	}
+
+	result, err := h.analysisService.AnalyzeBatch(batchReq)
+	if err != nil {
+		if respondBudgetExhausted(c, err) {
+			return
+		}
+		respondAnalysisError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "批量分析任务已提交",
+		Data:      result,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// GetBatchStatus 获取批量分析进度
+func (h *AnalysisHandler) GetBatchStatus(c *gin.Context) {
+	batchID := c.Param("batch_id")
+
+	status, err := h.analysisService.GetBatchStatus(batchID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.ApiResponse{
+			Code:      404,
+			Message:   err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "success",
+		Data:      status,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// formatLegacyAnalysis 早期只有文本的分析结果按列表格式整理；结构化结果生成的文本已逐条换行
+func formatLegacyAnalysis(result *model.ArticleAnalysis) {
+	if result != nil && result.Result == nil {
+		result.CoreViewpoints = formatAnalysisText(result.CoreViewpoints)
+		result.FileStructure = formatAnalysisText(result.FileStructure)
+		result.AuthorThoughts = formatAnalysisText(result.AuthorThoughts)
+		result.RelatedMaterials = formatAnalysisText(result.RelatedMaterials)
+	}
+}
+
 // formatAnalysisText 格式化分析文本，将数字列表格式转换为分行展示
 func formatAnalysisText(text string) string {
 	if text == "" {
@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"article-analysis/internal/middleware"
+	"article-analysis/internal/model"
+	"article-analysis/internal/service"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requesterFrom 按已配置的 API Key 识别调用方，未携带有效密钥时视为匿名调用方
+func requesterFrom(c *gin.Context) string {
+	if key := middleware.APIKey(c); key != "" {
+		return service.RequesterForAPIKey(key)
+	}
+	return service.RequesterAnonymous
+}
+
+// respondBudgetExhausted 额度用尽时返回 429 及超出的额度信息，err 不是预算错误时返回 false
+func respondBudgetExhausted(c *gin.Context, err error) bool {
+	var budgetErr *service.BudgetError
+	if !errors.As(err, &budgetErr) {
+		return false
+	}
+
+	c.JSON(http.StatusTooManyRequests, model.ApiResponse{
+		Code:    429,
+		Message: err.Error(),
+		Data: map[string]interface{}{
+			"error_code": "BUDGET_EXHAUSTED",
+			"scope":      budgetErr.Scope,
+			"period":     budgetErr.Period,
+			"metric":     budgetErr.Metric,
+			"limit":      budgetErr.Limit,
+			"used":       budgetErr.Used,
+			"requested":  budgetErr.Requested,
+		},
+		Timestamp: time.Now().Unix(),
+	})
+	return true
+}
+
+// GetBudgetStatus 获取全局及当前调用方的预算使用情况
+func (h *AnalysisHandler) GetBudgetStatus(c *gin.Context) {
+	status, err := h.analysisService.GetBudgetStatus(requesterFrom(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ApiResponse{
+			Code:      500,
+			Message:   err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "success",
+		Data:      status,
+		Timestamp: time.Now().Unix(),
+	})
+}
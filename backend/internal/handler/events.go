@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"article-analysis/internal/middleware"
+	"article-analysis/internal/model"
+	"article-analysis/internal/service"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// taskEventsInterval 检查任务状态和发送心跳的间隔
+//
+// 任务可能在其他实例执行，本进程收不到它的事件，需从任务表读取状态；
+// 心跳同时防止代理因连接空闲而断开。
+const taskEventsInterval = 3 * time.Second
+
+// TaskEvents 以 Server-Sent Events 推送任务事件，任务结束后关闭连接
+//
+// 模型输出的增量内容未经审核，只推送给编辑，学生只能看到任务进度。
+// 收到 token 以外的事件（如 retrying）时，客户端应丢弃此前收到的增量内容。
+func (h *AnalysisHandler) TaskEvents(c *gin.Context) {
+	taskID := c.Param("id")
+
+	past, events, cancel, err := h.analysisService.SubscribeTaskEvents(taskID, middleware.IsEditor(c))
+	if err != nil {
+		if errors.Is(err, service.ErrTaskNotFound) {
+			c.JSON(http.StatusNotFound, model.ApiResponse{
+				Code:      404,
+				Message:   err.Error(),
+				Timestamp: time.Now().Unix(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, model.ApiResponse{
+			Code:      500,
+			Message:   err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+	defer cancel()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	for _, event := range past {
+		c.SSEvent(event.Type, event)
+	}
+	c.Writer.Flush()
+	if events == nil {
+		return
+	}
+
+	ticker := time.NewTicker(taskEventsInterval)
+	defer ticker.Stop()
+	lastStatus := past[len(past)-1].Status
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				// 任务已结束，或本连接处理过慢被关闭，由客户端重新连接
+				return false
+			}
+			if event.Status != "" {
+				lastStatus = event.Status
+			}
+			c.SSEvent(event.Type, event)
+			return !event.Terminal()
+		case <-ticker.C:
+			current, err := h.analysisService.GetTaskEvent(taskID)
+			if err == nil && (current.Terminal() || current.Status != lastStatus) {
+				lastStatus = current.Status
+				c.SSEvent(current.Type, current)
+				return !current.Terminal()
+			}
+			c.SSEvent("heartbeat", gin.H{"time": time.Now()})
+			return true
+		}
+	})
+}
@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"article-analysis/internal/middleware"
+	"article-analysis/internal/model"
+	"article-analysis/internal/service"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseAnalysisParams 解析路径中的文章ID和分析记录ID，格式错误时写出响应并返回 false
+func parseAnalysisParams(c *gin.Context) (articleID, analysisID uint64, ok bool) {
+	articleID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   "文章ID格式错误",
+			Timestamp: time.Now().Unix(),
+		})
+		return 0, 0, false
+	}
+	analysisID, err = strconv.ParseUint(c.Param("analysis_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   "分析记录ID格式错误",
+			Timestamp: time.Now().Unix(),
+		})
+		return 0, 0, false
+	}
+	return articleID, analysisID, true
+}
+
+// ListAnalyses 获取文章的历次分析记录
+func (h *AnalysisHandler) ListAnalyses(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   "文章ID格式错误",
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	analyses, err := h.analysisService.ListAnalyses(id)
+	if err != nil {
+		respondAnalysisError(c, err)
+		return
+	}
+	if !middleware.IsEditor(c) {
+		// 学生只能看到审核通过的结果
+		visible := analyses[:0]
+		for _, analysis := range analyses {
+			if analysis.AnalysisStatus == "completed" && service.VisibleToStudents(analysis.ReviewStatus) {
+				visible = append(visible, analysis)
+			}
+		}
+		analyses = visible
+	}
+	for i := range analyses {
+		formatLegacyAnalysis(&analyses[i])
+	}
+
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "success",
+		Data:      analyses,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// GetAnalysis 获取文章的某一次分析
+func (h *AnalysisHandler) GetAnalysis(c *gin.Context) {
+	articleID, analysisID, ok := parseAnalysisParams(c)
+	if !ok {
+		return
+	}
+
+	analysis, err := h.analysisService.GetAnalysis(articleID, analysisID)
+	if err == nil && !middleware.IsEditor(c) &&
+		(analysis.AnalysisStatus != "completed" || !service.VisibleToStudents(analysis.ReviewStatus)) {
+		err = service.ErrAnalysisNotFound
+	}
+	if err != nil {
+		respondAnalysisError(c, err)
+		return
+	}
+	formatLegacyAnalysis(analysis)
+
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "success",
+		Data:      analysis,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// SetCurrentAnalysis 将某次分析设为文章的当前结果
+func (h *AnalysisHandler) SetCurrentAnalysis(c *gin.Context) {
+	articleID, analysisID, ok := parseAnalysisParams(c)
+	if !ok {
+		return
+	}
+
+	analysis, err := h.analysisService.SetCurrentAnalysis(articleID, analysisID)
+	if err != nil {
+		respondAnalysisError(c, err)
+		return
+	}
+	formatLegacyAnalysis(analysis)
+
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "已设为当前结果",
+		Data:      analysis,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// respondAnalysisError 按错误类型返回分析记录接口的错误响应
+func respondAnalysisError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrAnalysisNotFound), errors.Is(err, service.ErrArticleNotFound):
+		c.JSON(http.StatusNotFound, model.ApiResponse{
+			Code:      404,
+			Message:   err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+	case errors.Is(err, service.ErrAnalysisNotCompleted), errors.Is(err, service.ErrCompareNotCompleted),
+		errors.Is(err, service.ErrAnalysisNotEditable), errors.Is(err, service.ErrReviewLocked),
+		errors.Is(err, service.ErrRevisionConflict), errors.Is(err, service.ErrTaskInProgress):
+		c.JSON(http.StatusConflict, model.ApiResponse{
+			Code:      409,
+			Message:   err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+	case errors.Is(err, service.ErrInvalidEdit), errors.Is(err, service.ErrInvalidReviewTransition),
+		errors.Is(err, service.ErrUnknownProfile), errors.Is(err, service.ErrUnknownSchema),
+		errors.Is(err, service.ErrUnknownGenre), errors.Is(err, service.ErrUnknownPrompt),
+		errors.Is(err, service.ErrPromptSchemaMismatch), errors.Is(err, service.ErrNoArticlesMatched),
+		errors.Is(err, service.ErrBatchTooLarge), errors.Is(err, service.ErrInvalidComparison):
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, model.ApiResponse{
+			Code:      500,
+			Message:   err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+	}
+}
+
+// CompareAnalyses 对比文章的两次分析，查询参数 a、b 为分析记录ID
+func (h *AnalysisHandler) CompareAnalyses(c *gin.Context) {
+	articleID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   "文章ID格式错误",
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+	a, errA := strconv.ParseUint(c.Query("a"), 10, 64)
+	b, errB := strconv.ParseUint(c.Query("b"), 10, 64)
+	if errA != nil || errB != nil {
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   "请通过参数 a、b 指定要对比的两次分析",
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	comparison, err := h.analysisService.CompareAnalyses(articleID, a, b)
+	if err != nil {
+		respondAnalysisError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "success",
+		Data:      comparison,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// AnalyzeWithProfiles 用两个模型配置同时分析文章，用于对比
+func (h *AnalysisHandler) AnalyzeWithProfiles(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   "文章ID格式错误",
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	var req struct {
+		Profiles []string `json:"profiles"`
+		Prompt   string   `json:"prompt_version"`
+		Schema   string   `json:"schema"`
+		Genre    string   `json:"genre"`
+		Force    bool     `json:"force"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   "参数错误：" + err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+	for i := range req.Profiles {
+		req.Profiles[i] = strings.TrimSpace(req.Profiles[i])
+	}
+
+	result, err := h.analysisService.AnalyzeWithProfiles(id, req.Profiles, service.AnalyzeOptions{
+		Requester: requesterFrom(c),
+		Force:     req.Force,
+		Prompt:    strings.TrimSpace(req.Prompt),
+		Schema:    strings.TrimSpace(req.Schema),
+		Genre:     strings.TrimSpace(req.Genre),
+	})
+	if err != nil {
+		if respondBudgetExhausted(c, err) {
+			return
+		}
+		respondAnalysisError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "对比分析任务已提交",
+		Data:      result,
+		Timestamp: time.Now().Unix(),
+	})
+}
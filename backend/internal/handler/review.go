@@ -0,0 +1,143 @@
+package handler
+
+import (
+	"article-analysis/internal/model"
+	"article-analysis/internal/service"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// editorNameFrom 请求头 X-User 提供的修改人显示名称，未经验证，只用于展示
+func editorNameFrom(c *gin.Context) string {
+	name := []rune(strings.TrimSpace(c.GetHeader("X-User")))
+	if len(name) > 100 {
+		name = name[:100]
+	}
+	return string(name)
+}
+
+// UpdateAnalysis 修改文章当前的分析结果
+//
+// 请求体 fields 按维度字段名给出修改后的值，revision 为编辑看到的修订号，用于发现并发修改。
+func (h *AnalysisHandler) UpdateAnalysis(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   "文章ID格式错误",
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	var req struct {
+		Fields   map[string]json.RawMessage `json:"fields"`
+		Revision *int                       `json:"revision"`
+		Comment  string                     `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   "参数错误：" + err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	analysis, err := h.analysisService.UpdateAnalysis(id, service.AnalysisEdit{
+		Editor:     requesterFrom(c),
+		EditorName: editorNameFrom(c),
+		Fields:     req.Fields,
+		Revision:   req.Revision,
+		Comment:    req.Comment,
+	})
+	if err != nil {
+		respondAnalysisError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "分析结果已修改",
+		Data:      analysis,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// SetReviewStatus 变更文章当前分析结果的审核状态：draft → reviewed → published，已审核或发布的可退回 draft
+func (h *AnalysisHandler) SetReviewStatus(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   "文章ID格式错误",
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	var req struct {
+		Status   string `json:"status" binding:"required"`
+		Revision *int   `json:"revision"`
+		Comment  string `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   "参数错误：" + err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	analysis, err := h.analysisService.SetReviewStatus(id, service.ReviewChange{
+		Editor:     requesterFrom(c),
+		EditorName: editorNameFrom(c),
+		Status:     strings.TrimSpace(req.Status),
+		Revision:   req.Revision,
+		Comment:    req.Comment,
+	})
+	if err != nil {
+		respondAnalysisError(c, err)
+		return
+	}
+	formatLegacyAnalysis(analysis)
+
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "审核状态已更新",
+		Data:      analysis,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// ListRevisions 获取文章当前分析结果的修改记录
+func (h *AnalysisHandler) ListRevisions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   "文章ID格式错误",
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	revisions, err := h.analysisService.ListRevisions(id)
+	if err != nil {
+		respondAnalysisError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "success",
+		Data:      revisions,
+		Timestamp: time.Now().Unix(),
+	})
+}
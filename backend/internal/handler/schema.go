@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"article-analysis/internal/model"
+	"article-analysis/internal/service"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// schemaRequest 新建或修改分析方案的请求体
+type schemaRequest struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Fields      model.SchemaFields `json:"fields"`
+}
+
+// ListSchemas 获取可用的分析方案
+func (h *AnalysisHandler) ListSchemas(c *gin.Context) {
+	schemas, err := h.analysisService.ListSchemas()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.ApiResponse{
+			Code:      500,
+			Message:   err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "success",
+		Data:      schemas,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// GetSchema 获取分析方案详情
+func (h *AnalysisHandler) GetSchema(c *gin.Context) {
+	schema, err := h.analysisService.GetSchema(c.Param("name"))
+	if err != nil {
+		respondSchemaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "success",
+		Data:      schema,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// CreateSchema 新建分析方案
+func (h *AnalysisHandler) CreateSchema(c *gin.Context) {
+	var req schemaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   "参数错误：" + err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	schema := &model.AnalysisSchema{Name: req.Name, Description: req.Description, Fields: req.Fields}
+	if err := h.analysisService.CreateSchema(schema); err != nil {
+		respondSchemaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "分析方案已创建",
+		Data:      schema,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// UpdateSchema 修改分析方案的说明和维度
+func (h *AnalysisHandler) UpdateSchema(c *gin.Context) {
+	var req schemaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   "参数错误：" + err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	schema, err := h.analysisService.UpdateSchema(c.Param("name"), &model.AnalysisSchema{
+		Description: req.Description,
+		Fields:      req.Fields,
+	})
+	if err != nil {
+		respondSchemaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "分析方案已修改",
+		Data:      schema,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// DeleteSchema 删除分析方案
+func (h *AnalysisHandler) DeleteSchema(c *gin.Context) {
+	if err := h.analysisService.DeleteSchema(c.Param("name")); err != nil {
+		respondSchemaError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "分析方案已删除",
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// respondSchemaError 按错误类型返回分析方案接口的错误响应
+func respondSchemaError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrUnknownSchema):
+		c.JSON(http.StatusNotFound, model.ApiResponse{
+			Code:      404,
+			Message:   err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+	case errors.Is(err, service.ErrSchemaExists):
+		c.JSON(http.StatusConflict, model.ApiResponse{
+			Code:      409,
+			Message:   err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+	case errors.Is(err, service.ErrInvalidSchema):
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, model.ApiResponse{
+			Code:      500,
+			Message:   err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"article-analysis/internal/model"
+	"article-analysis/internal/service"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetUsageReport 获取模型用量报表
+//
+// 查询参数：from、to 为 YYYY-MM-DD 格式的日期（含），group_by 为逗号分隔的 day、model、author、requester
+func (h *AnalysisHandler) GetUsageReport(c *gin.Context) {
+	var groupBy []string
+	for _, dim := range strings.Split(c.Query("group_by"), ",") {
+		if dim = strings.TrimSpace(dim); dim != "" {
+			groupBy = append(groupBy, dim)
+		}
+	}
+
+	report, err := h.analysisService.UsageReport(c.Query("from"), c.Query("to"), groupBy)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidUsageQuery) {
+			c.JSON(http.StatusBadRequest, model.ApiResponse{
+				Code:      400,
+				Message:   err.Error(),
+				Timestamp: time.Now().Unix(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, model.ApiResponse{
+			Code:      500,
+			Message:   err.Error(),
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.ApiResponse{
+		Code:      200,
+		Message:   "success",
+		Data:      report,
+		Timestamp: time.Now().Unix(),
+	})
+}
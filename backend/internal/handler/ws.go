@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"article-analysis/internal/model"
+	"article-analysis/internal/service"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = 30 * time.Second
+)
+
+// 与 CORS 中间件一致，接受任意来源的连接
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+type EventHandler struct {
+	bus *service.EventBus
+}
+
+func NewEventHandler(bus *service.EventBus) *EventHandler {
+	return &EventHandler{bus: bus}
+}
+
+// subscribeMessage 客户端发送的订阅消息，替换连接当前的过滤条件
+type subscribeMessage struct {
+	Action     string   `json:"action"` // 目前只支持 subscribe
+	Types      []string `json:"types"`
+	ArticleIDs []string `json:"article_ids"`
+	Authors    []string `json:"authors"`
+}
+
+// ServeWS 通过 WebSocket 推送领域事件
+//
+// 连接时可用查询参数 type、article_id、author 设置过滤条件（可重复或以逗号分隔），
+// 连接后可发送 {"action":"subscribe",...} 更换过滤条件。服务端处理不及时时会关闭连接，客户端应重新连接。
+// 文章ID格式错误时拒绝连接或以关闭帧断开，避免过滤条件被放宽为全部事件。
+func (h *EventHandler) ServeWS(c *gin.Context) {
+	articleIDs, err := parseIDs(queryList(c, "article_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ApiResponse{
+			Code:      400,
+			Message:   "文章ID格式错误",
+			Timestamp: time.Now().Unix(),
+		})
+		return
+	}
+	filter := service.EventFilter{
+		Types:      queryList(c, "type"),
+		ArticleIDs: articleIDs,
+		Authors:    queryList(c, "author"),
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade 已向客户端返回错误
+		return
+	}
+	defer conn.Close()
+
+	sub := h.bus.Subscribe(filter)
+	defer sub.Close()
+
+	// 读协程处理订阅消息和 pong，连接断开时结束
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		conn.SetReadLimit(4096)
+		conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+		})
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var msg subscribeMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				// 无法解析的消息忽略，不断开连接
+				continue
+			}
+			if msg.Action != "subscribe" {
+				continue
+			}
+			ids, err := parseIDs(msg.ArticleIDs)
+			if err != nil {
+				// WriteControl 可与写协程并发调用
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseInvalidFramePayloadData, "文章ID格式错误"),
+					time.Now().Add(wsWriteTimeout))
+				return
+			}
+			sub.SetFilter(service.EventFilter{Types: msg.Types, ArticleIDs: ids, Authors: msg.Authors})
+		}
+	}()
+
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-readDone:
+			return
+		case event, ok := <-sub.Events():
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "处理不及时，请重新连接"))
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// queryList 读取可重复、可逗号分隔的查询参数
+func queryList(c *gin.Context, key string) []string {
+	var values []string
+	for _, value := range c.QueryArray(key) {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				values = append(values, item)
+			}
+		}
+	}
+	return values
+}
+
+// parseIDs 解析文章ID，任一项格式错误时返回错误
+func parseIDs(values []string) ([]uint64, error) {
+	var ids []uint64
+	for _, value := range values {
+		id, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
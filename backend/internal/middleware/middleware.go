@@ -1,7 +1,10 @@
 package middleware
 
 import (
+	"article-analysis/internal/model"
 	"article-analysis/pkg/logger"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -42,7 +45,7 @@ func CORS() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-API-Key, X-User")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -52,4 +55,85 @@ func CORS() gin.HandlerFunc {
 
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+const (
+	editorKey = "editor"
+	apiKeyKey = "api_key"
+)
+
+// APIKeys 记录请求头 X-API-Key 中已配置的密钥，不在 keys 中的密钥视为未携带
+func APIKeys(keys []string) gin.HandlerFunc {
+	known := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			known[key] = true
+		}
+	}
+	return func(c *gin.Context) {
+		if key := strings.TrimSpace(c.GetHeader("X-API-Key")); known[key] {
+			c.Set(apiKeyKey, key)
+		}
+		c.Next()
+	}
+}
+
+// APIKey 请求携带的已配置密钥，需先经过 APIKeys 中间件，未携带或密钥无效时为空
+func APIKey(c *gin.Context) string {
+	return c.GetString(apiKeyKey)
+}
+
+// Editor 标记请求头 X-API-Key 在 keys 中的请求为编辑，keys 为空时所有请求都视为编辑
+func Editor(keys []string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			allowed[key] = true
+		}
+	}
+	return func(c *gin.Context) {
+		c.Set(editorKey, len(allowed) == 0 || allowed[strings.TrimSpace(c.GetHeader("X-API-Key"))])
+		c.Next()
+	}
+}
+
+// IsEditor 请求是否来自编辑，需先经过 Editor 中间件
+func IsEditor(c *gin.Context) bool {
+	return c.GetBool(editorKey)
+}
+
+// EditorOnly 只允许编辑的请求通过，需先经过 Editor 中间件
+func EditorOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !IsEditor(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, model.ApiResponse{
+				Code:      403,
+				Message:   "没有编辑权限",
+				Timestamp: time.Now().Unix(),
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// AdminOnly 只允许请求头 X-API-Key 在 keys 中的请求通过，keys 为空时不校验
+func AdminOnly(keys []string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" {
+			allowed[key] = true
+		}
+	}
+	return func(c *gin.Context) {
+		if len(allowed) > 0 && !allowed[strings.TrimSpace(c.GetHeader("X-API-Key"))] {
+			c.AbortWithStatusJSON(http.StatusForbidden, model.ApiResponse{
+				Code:      403,
+				Message:   "没有管理权限",
+				Timestamp: time.Now().Unix(),
+			})
+			return
+		}
+		c.Next()
+	}
+}
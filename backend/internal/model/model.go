@@ -1,6 +1,10 @@
 package model
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 )
 
@@ -18,20 +22,359 @@ type Article struct {
 
 type ArticleAnalysis struct {
 	ID               uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
-	ArticleID        uint64    `gorm:"not null;index" json:"article_id"`
+	ArticleID        uint64    `gorm:"not null;index;uniqueIndex:idx_article_version,priority:1" json:"article_id"`
+	Version          int       `gorm:"not null;default:0;uniqueIndex:idx_article_version,priority:2" json:"version"` // 文章的第几次分析，从 1 开始
+	IsCurrent        bool      `gorm:"not null;default:false;index" json:"is_current"` // 是否为文章的当前结果，每篇文章至多一条
 	CoreViewpoints   string    `gorm:"type:text" json:"core_viewpoints"`
 	FileStructure    string    `gorm:"type:text" json:"file_structure"`
 	AuthorThoughts   string    `gorm:"type:text" json:"author_thoughts"`
 	RelatedMaterials string    `gorm:"type:text" json:"related_materials"`
-	AnalysisStatus   string    `gorm:"type:enum('pending','processing','completed','failed');default:'pending'" json:"analysis_status"`
+	AnalysisStatus   string    `gorm:"type:enum('pending','processing','completed','failed','cancelled');default:'pending'" json:"analysis_status"`
 	AnalysisTime     *time.Time `json:"analysis_time"`
 	ErrorMessage     string    `gorm:"type:text" json:"error_message"`
+	Provider         string    `gorm:"type:varchar(50)" json:"provider"` // 实际完成分析的模型服务
+	Profile          string    `gorm:"type:varchar(100)" json:"profile"`
+	Model            string    `gorm:"type:varchar(100)" json:"model"`
+	PromptVersion    string    `gorm:"type:varchar(50)" json:"prompt_version"` // 生成结果所用的提示词版本
+	Schema           string    `gorm:"type:varchar(100)" json:"schema"`         // 使用的分析方案，为空或 default 表示内置的四个维度
+	Genre            string    `gorm:"type:varchar(20)" json:"genre"`           // 分析时采用的文章体裁
+	GenreSource      string    `gorm:"type:varchar(10)" json:"genre_source"`    // 体裁来源：auto 自动识别，user 提交时指定
+	Result           *AnalysisResult `gorm:"type:json" json:"result"` // 结构化结果，上面的文本字段由其生成；早期的分析记录为空
+	Fields           AnalysisFields  `gorm:"type:json" json:"fields,omitempty"` // 自定义分析方案的结果，使用内置方案时为空
+	SchemaFields     SchemaFields    `gorm:"type:json" json:"schema_fields,omitempty"` // 分析时自定义方案的维度快照，方案之后修改或删除不影响读取结果
+	ReviewStatus     string    `gorm:"type:varchar(20);not null;default:'draft';index" json:"review_status"` // 人工审核状态：draft/reviewed/published
+	Revision         int       `gorm:"not null;default:0" json:"revision"`                                  // 人工修改和审核的次数，用于检查并发修改
 	CreatedAt        time.Time `json:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at"`
 	
 	Article Article `gorm:"foreignKey:ArticleID" json:"article,omitempty"`
 }
 
+// AnalysisItem 分析结果中的一条要点
+type AnalysisItem struct {
+	Title       string `json:"title"`
+	Explanation string `json:"explanation"`
+	Quote       string `json:"quote"` // 原文中支撑该要点的引文
+
+	// 由服务端将引文与原文比对后填写：能在原文中找到时记录位置；
+	// 没有引文或找不到（可能是模型编造的引文）时 Verified 为 false，需要人工核实
+	Evidence *Evidence `json:"evidence,omitempty"`
+	Verified bool      `json:"verified"`
+}
+
+// Evidence 引文在文章内容中对应的片段
+type Evidence struct {
+	Start     int     `json:"start"`     // 在 Article.Content 中的起始位置，按字符（而非字节）计
+	End       int     `json:"end"`       // 结束位置（不含）
+	Paragraph int     `json:"paragraph"` // 所在段落序号，从 1 开始，空行不计
+	Text      string  `json:"text"`      // 原文中匹配到的片段
+	Score     float64 `json:"score"`     // 与引文的相似度，1 表示完全一致（忽略空白和标点）
+}
+
+// AnalysisResult 按维度列出要点的结构化分析结果，以 JSON 存储
+type AnalysisResult struct {
+	CoreViewpoints   []AnalysisItem `json:"core_viewpoints"`
+	FileStructure    []AnalysisItem `json:"file_structure"`
+	AuthorThoughts   []AnalysisItem `json:"author_thoughts"`
+	RelatedMaterials []AnalysisItem `json:"related_materials"`
+}
+
+// IsEmpty 是否没有任何要点
+func (r AnalysisResult) IsEmpty() bool {
+	return len(r.CoreViewpoints)+len(r.FileStructure)+len(r.AuthorThoughts)+len(r.RelatedMaterials) == 0
+}
+
+func (r AnalysisResult) Value() (driver.Value, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (r *AnalysisResult) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	default:
+		return fmt.Errorf("无法将 %T 解析为分析结果", value)
+	}
+}
+
+// AnalysisFields 按自定义分析方案保存的结果，键为维度的字段名，值的结构由维度类型决定
+type AnalysisFields map[string]json.RawMessage
+
+func (f AnalysisFields) Value() (driver.Value, error) {
+	if f == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (f *AnalysisFields) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*f = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, f)
+	case string:
+		return json.Unmarshal([]byte(v), f)
+	default:
+		return fmt.Errorf("无法将 %T 解析为分析结果", value)
+	}
+}
+
+// 分析维度的取值类型
+const (
+	FieldTypeItems = "items" // 要点列表，每条要点包含标题、说明和引文
+	FieldTypeText  = "text"  // 一段文字
+	FieldTypeList  = "list"  // 字符串列表，如关键词、适用的作文题目
+)
+
+// SchemaField 分析方案中的一个维度
+type SchemaField struct {
+	Key         string `json:"key"`         // 结果中的字段名，由小写字母、数字和下划线组成
+	Label       string `json:"label"`       // 显示名称，如“论证方法”
+	Description string `json:"description"` // 该维度的分析要求，写入提示词
+	Type        string `json:"type"`        // items / text / list，为空时为 items
+}
+
+// SchemaFields 分析方案的维度列表，以 JSON 存储
+type SchemaFields []SchemaField
+
+func (f SchemaFields) Value() (driver.Value, error) {
+	if f == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (f *SchemaFields) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*f = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, f)
+	case string:
+		return json.Unmarshal([]byte(v), f)
+	default:
+		return fmt.Errorf("无法将 %T 解析为维度列表", value)
+	}
+}
+
+// AnalysisSchema 管理员定义的分析方案，决定分析哪些维度，提示词和结果校验规则均由其生成
+type AnalysisSchema struct {
+	ID          uint64       `gorm:"primaryKey;autoIncrement" json:"-"`
+	Name        string       `gorm:"type:varchar(100);not null;uniqueIndex" json:"name"` // 提交分析时按名称选择
+	Description string       `gorm:"type:text" json:"description"`
+	Fields      SchemaFields `gorm:"type:json" json:"fields"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// AnalysisRevision 分析结果的一次人工修改或审核状态变更
+type AnalysisRevision struct {
+	ID         uint64       `gorm:"primaryKey;autoIncrement" json:"id"`
+	AnalysisID uint64       `gorm:"not null;index" json:"analysis_id"`
+	ArticleID  uint64       `gorm:"not null;index" json:"article_id"`
+	Revision   int          `gorm:"not null" json:"revision"` // 修改后分析记录的修订号，从 1 开始
+	Editor     string       `gorm:"type:varchar(100)" json:"editor"`      // 修改人，由 API Key 识别的调用方标识
+	EditorName string       `gorm:"type:varchar(100)" json:"editor_name"` // 请求头 X-User 提供的显示名称，未经验证
+	Action     string       `gorm:"type:varchar(20);not null" json:"action"` // edit 修改内容，review 变更审核状态
+	Changes    FieldChanges `gorm:"type:json" json:"changes"`
+	Comment    string       `gorm:"type:varchar(500)" json:"comment"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// FieldChange 一个字段修改前后的值，审核状态变更时字段为 review_status
+type FieldChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// FieldChanges 一次修改涉及的字段，以 JSON 存储
+type FieldChanges []FieldChange
+
+func (c FieldChanges) Value() (driver.Value, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (c *FieldChanges) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	default:
+		return fmt.Errorf("无法将 %T 解析为修改记录", value)
+	}
+}
+
+// FormatItems 将要点渲染为文本，供只读取文本字段的旧接口使用；多条要点时逐条编号
+func FormatItems(items []AnalysisItem) string {
+	lines := make([]string, 0, len(items))
+	for i, item := range items {
+		line := item.Title
+		if item.Explanation != "" {
+			if line != "" {
+				line += "："
+			}
+			line += item.Explanation
+		}
+		if item.Quote != "" {
+			line += "（原文：“" + item.Quote + "”）"
+		}
+		if len(items) > 1 {
+			line = fmt.Sprintf("%d. %s", i+1, line)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// 分析任务状态
+const (
+	TaskStatusQueued    = "queued"
+	TaskStatusRunning   = "running"
+	TaskStatusCompleted = "completed"
+	TaskStatusFailed    = "failed"
+	TaskStatusCancelled = "cancelled"
+)
+
+// AnalysisTask 持久化的分析任务，由工作池从任务表中认领执行
+type AnalysisTask struct {
+	ID           uint64     `gorm:"primaryKey;autoIncrement" json:"-"`
+	TaskID       string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"task_id"`
+	BatchID      string     `gorm:"type:varchar(64);index" json:"batch_id,omitempty"`
+	ArticleID    uint64     `gorm:"not null;index" json:"article_id"`
+	AnalysisID   uint64     `gorm:"not null;index" json:"analysis_id"`
+	Status       string     `gorm:"type:varchar(20);not null;default:'queued';index" json:"status"`
+	Progress     int        `gorm:"not null;default:0" json:"progress"`
+	Attempts     int        `gorm:"not null;default:0" json:"attempts"`
+	Profile      string     `gorm:"type:varchar(100)" json:"profile"`
+	Model        string     `gorm:"type:varchar(100)" json:"model"`
+	Requester    string     `gorm:"type:varchar(100);index" json:"requester,omitempty"` // 提交任务的调用方
+	ErrorMessage string     `gorm:"type:text" json:"error_message"`
+	WorkerID     string     `gorm:"type:varchar(200)" json:"-"`
+	LeaseUntil   *time.Time `gorm:"index" json:"-"`
+	StartedAt    *time.Time `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+
+	// 本次执行累计的模型用量，长文分段分析和重试的每次调用都计入
+	PromptTokens     int     `gorm:"not null;default:0" json:"prompt_tokens"`
+	CompletionTokens int     `gorm:"not null;default:0" json:"completion_tokens"`
+	LatencyMs        int64   `gorm:"not null;default:0" json:"latency_ms"` // 模型调用累计耗时
+	Cost             float64 `gorm:"type:decimal(14,6);not null;default:0" json:"cost"`
+
+	// 提交时的预估用量，任务执行完成前计入预算占用
+	EstimatedTokens int     `gorm:"not null;default:0" json:"estimated_tokens"`
+	EstimatedCost   float64 `gorm:"type:decimal(14,6);not null;default:0" json:"estimated_cost"`
+
+	PromptVersion string `gorm:"type:varchar(50)" json:"prompt_version"`      // 使用的提示词版本，为空表示提交时的默认版本
+	Schema        string `gorm:"type:varchar(100)" json:"schema"`             // 使用的分析方案，为空表示内置方案
+	Genre         string `gorm:"type:varchar(20)" json:"genre"`               // 文章体裁，提交时识别或由用户指定
+	GenreSource   string `gorm:"type:varchar(10)" json:"genre_source"`        // 体裁来源：auto 或 user
+	ForceRefresh  bool   `gorm:"not null;default:false" json:"force_refresh"` // 忽略缓存，重新调用模型分析
+	CacheHit      bool   `gorm:"not null;default:false" json:"cache_hit"`     // 结果来自缓存，未调用模型
+	Comparison    bool   `gorm:"not null;default:false" json:"comparison"`    // 多个模型配置对比分析的任务，完成后不自动成为当前结果
+
+	// 排队中或执行中的非对比任务填写文章ID，任务结束时清空；唯一索引保证每篇文章同时至多一个这样的任务
+	ActiveArticleID *uint64 `gorm:"uniqueIndex" json:"-"`
+}
+
+// AnalysisBatch 一次批量提交的分析任务集合
+type AnalysisBatch struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"-"`
+	BatchID   string    `gorm:"type:varchar(64);not null;uniqueIndex" json:"batch_id"`
+	Requested int       `gorm:"not null;default:0" json:"requested"`
+	Queued    int       `gorm:"not null;default:0" json:"queued"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AnalysisCache 模型分析结果缓存，以文章内容、提示词版本、模型及生成参数的 SHA-256 为键，
+// 内容相同的文章（如以不同标题重复上传）直接复用已有结果
+type AnalysisCache struct {
+	ID               uint64          `gorm:"primaryKey;autoIncrement" json:"-"`
+	CacheKey         string          `gorm:"type:char(64);not null;uniqueIndex" json:"cache_key"`
+	PromptVersion    string          `gorm:"type:varchar(50)" json:"prompt_version"`
+	Provider         string          `gorm:"type:varchar(50)" json:"provider"`
+	Profile          string          `gorm:"type:varchar(100)" json:"profile"`
+	Model            string          `gorm:"type:varchar(100)" json:"model"`
+	CoreViewpoints   string          `gorm:"type:text" json:"core_viewpoints"`
+	FileStructure    string          `gorm:"type:text" json:"file_structure"`
+	AuthorThoughts   string          `gorm:"type:text" json:"author_thoughts"`
+	RelatedMaterials string          `gorm:"type:text" json:"related_materials"`
+	Result           *AnalysisResult `gorm:"type:json" json:"result"`
+	Fields           AnalysisFields  `gorm:"type:json" json:"fields,omitempty"`
+	Hits             int             `gorm:"not null;default:0" json:"hits"`
+	LastHitAt        *time.Time      `json:"last_hit_at"`
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+}
+
+// 预算预警级别
+const (
+	BudgetAlertWarning   = "warning"
+	BudgetAlertExhausted = "exhausted"
+)
+
+// BudgetAlert 预算预警事件，同一额度在同一周期内每个级别只记录一次
+type BudgetAlert struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Scope     string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_budget_alert" json:"scope"`     // global 或调用方标识
+	Period    string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_budget_alert" json:"period"`     // daily / monthly
+	PeriodKey string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_budget_alert" json:"period_key"` // 如 2026-01-02、2026-01
+	Metric    string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_budget_alert" json:"metric"`     // tokens / cost
+	Level     string    `gorm:"type:varchar(20);not null;uniqueIndex:idx_budget_alert" json:"level"`
+	Used      float64   `json:"used"`
+	Limit     float64   `gorm:"column:limit_value" json:"limit"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// AnalysisTaskAttempt 任务执行过程中的一次模型调用尝试
+type AnalysisTaskAttempt struct {
+	ID           uint64 `gorm:"primaryKey;autoIncrement" json:"-"`
+	TaskID       uint64 `gorm:"not null;index" json:"-"`
+	Attempt      int    `gorm:"not null" json:"attempt"`
+	Profile      string `gorm:"type:varchar(100)" json:"profile"`
+	Model        string `gorm:"type:varchar(100)" json:"model"`
+	StatusCode   int    `json:"status_code"`
+	Retryable    bool   `json:"retryable"`
+	ErrorMessage string `gorm:"type:text" json:"error_message"`
+	DurationMs   int64  `json:"duration_ms"`
+	WaitMs       int64  `json:"wait_ms"`
+
+	PromptTokens     int     `gorm:"not null;default:0" json:"prompt_tokens"`
+	CompletionTokens int     `gorm:"not null;default:0" json:"completion_tokens"`
+	Cost             float64 `gorm:"type:decimal(14,6);not null;default:0" json:"cost"` // 按调用时的价格计算
+
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
 type PaginationRequest struct {
 	Page     int    `form:"page,default=1" binding:"min=1"`
 	PageSize int    `form:"page_size,default=10" binding:"min=1,max=100"`
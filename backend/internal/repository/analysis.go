@@ -19,20 +19,66 @@ func (r *AnalysisRepository) Create(analysis *model.ArticleAnalysis) error {
 	return r.db.Create(analysis).Error
 }
 
+// GetByArticleID 获取文章的当前分析结果，尚无当前结果时返回最近一次分析
 func (r *AnalysisRepository) GetByArticleID(articleID uint64) (*model.ArticleAnalysis, error) {
 	var analysis model.ArticleAnalysis
-	err := r.db.Preload("Article").Where("article_id = ?", articleID).First(&analysis).Error
+	err := r.db.Preload("Article").Where("article_id = ?", articleID).
+		Order("is_current DESC, id DESC").First(&analysis).Error
 	if err != nil {
 		return nil, err
 	}
 	return &analysis, nil
 }
 
+// ListByArticleID 按时间倒序列出文章的全部分析记录
+func (r *AnalysisRepository) ListByArticleID(articleID uint64) ([]model.ArticleAnalysis, error) {
+	var analyses []model.ArticleAnalysis
+	err := r.db.Where("article_id = ?", articleID).Order("id DESC").Find(&analyses).Error
+	return analyses, err
+}
+
+// NextVersion 返回文章下一次分析的版本号
+func (r *AnalysisRepository) NextVersion(articleID uint64) (int, error) {
+	var version int
+	err := r.db.Model(&model.ArticleAnalysis{}).
+		Where("article_id = ?", articleID).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&version).Error
+	return version + 1, err
+}
+
+// SetCurrent 将指定的分析记录设为文章的当前结果
+func (r *AnalysisRepository) SetCurrent(articleID, analysisID uint64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.ArticleAnalysis{}).
+			Where("article_id = ? AND id <> ? AND is_current = ?", articleID, analysisID, true).
+			Update("is_current", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.ArticleAnalysis{}).
+			Where("id = ? AND article_id = ?", analysisID, articleID).
+			Update("is_current", true).Error
+	})
+}
+
+// MigrateLegacy 为保留历史之前的分析记录补充版本号：当时每篇文章只有一条记录，
+// 版本号记为 1，已完成的作为当前结果
+func (r *AnalysisRepository) MigrateLegacy() (int64, error) {
+	result := r.db.Model(&model.ArticleAnalysis{}).
+		Where("version = ?", 0).
+		Updates(map[string]interface{}{
+			"version":    1,
+			"is_current": gorm.Expr("analysis_status = ?", "completed"),
+		})
+	return result.RowsAffected, result.Error
+}
+
 func (r *AnalysisRepository) Update(analysis *model.ArticleAnalysis) error {
 	return r.db.Save(analysis).Error
 }
 
-func (r *AnalysisRepository) UpdateStatus(articleID uint64, status string, errorMsg string) error {
+// UpdateStatus 更新一次分析的状态
+func (r *AnalysisRepository) UpdateStatus(analysisID uint64, status string, errorMsg string) error {
 	now := time.Now()
 	updates := map[string]interface{}{
 		"analysis_status": status,
@@ -40,15 +86,79 @@ func (r *AnalysisRepository) UpdateStatus(articleID uint64, status string, error
 		"updated_at":      now,
 	}
 	
-	if status == "completed" || status == "failed" {
+	if status == "completed" || status == "failed" || status == "cancelled" {
 		updates["analysis_time"] = &now
 	}
 	
 	return r.db.Model(&model.ArticleAnalysis{}).
-		Where("article_id = ?", articleID).
+		Where("id = ?", analysisID).
 		Updates(updates).Error
 }
 
+// FailOrphaned 将没有对应活动任务却停留在 pending/processing 的分析记录标记为失败
+func (r *AnalysisRepository) FailOrphaned(errorMsg string) (int64, error) {
+	now := time.Now()
+	active := r.db.Model(&model.AnalysisTask{}).
+		Select("analysis_id").
+		Where("status IN ?", []string{model.TaskStatusQueued, model.TaskStatusRunning})
+	result := r.db.Model(&model.ArticleAnalysis{}).
+		Where("analysis_status IN ?", []string{"pending", "processing"}).
+		Where("id NOT IN (?)", active).
+		Updates(map[string]interface{}{
+			"analysis_status": "failed",
+			"error_message":   errorMsg,
+			"analysis_time":   &now,
+			"updated_at":      now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// GetVisibleByArticleID 获取文章审核状态在 reviewStatuses 中的已完成分析，优先当前结果，其次最近一次
+func (r *AnalysisRepository) GetVisibleByArticleID(articleID uint64, reviewStatuses []string) (*model.ArticleAnalysis, error) {
+	var analysis model.ArticleAnalysis
+	err := r.db.Preload("Article").
+		Where("article_id = ? AND analysis_status = ? AND review_status IN ?", articleID, "completed", reviewStatuses).
+		Order("is_current DESC, id DESC").First(&analysis).Error
+	if err != nil {
+		return nil, err
+	}
+	return &analysis, nil
+}
+
+// SaveRevision 保存人工修改后的结果和审核状态并记录本次修改。revision.Revision 为修改后的修订号，
+// 分析记录的修订号已不是 revision.Revision-1（期间被他人修改）时不保存并返回 false
+func (r *AnalysisRepository) SaveRevision(analysis *model.ArticleAnalysis, revision *model.AnalysisRevision) (bool, error) {
+	saved := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.ArticleAnalysis{}).
+			Where("id = ? AND revision = ?", analysis.ID, revision.Revision-1).
+			Updates(map[string]interface{}{
+				"core_viewpoints":   analysis.CoreViewpoints,
+				"file_structure":    analysis.FileStructure,
+				"author_thoughts":   analysis.AuthorThoughts,
+				"related_materials": analysis.RelatedMaterials,
+				"result":            analysis.Result,
+				"fields":            analysis.Fields,
+				"review_status":     analysis.ReviewStatus,
+				"revision":          revision.Revision,
+				"updated_at":        time.Now(),
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		saved = true
+		return tx.Create(revision).Error
+	})
+	return saved, err
+}
+
+// ListRevisions 按时间倒序列出分析记录的修改
+func (r *AnalysisRepository) ListRevisions(analysisID uint64) ([]model.AnalysisRevision, error) {
+	var revisions []model.AnalysisRevision
+	err := r.db.Where("analysis_id = ?", analysisID).Order("revision DESC").Find(&revisions).Error
+	return revisions, err
+}
+
 func (r *AnalysisRepository) GetByID(id uint64) (*model.ArticleAnalysis, error) {
 	var analysis model.ArticleAnalysis
 	err := r.db.First(&analysis, id).Error
@@ -64,15 +174,29 @@ func (a *ArticleAnalysis) TableName() string {
 }
 
 type ArticleAnalysis struct {
-	ID               uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
-	ArticleID        uint64     `gorm:"not null;index" json:"article_id"`
-	CoreViewpoints   string     `gorm:"type:text" json:"core_viewpoints"`
-	FileStructure    string     `gorm:"type:text" json:"file_structure"`
-	AuthorThoughts   string     `gorm:"type:text" json:"author_thoughts"`
-	RelatedMaterials string     `gorm:"type:text" json:"related_materials"`
-	AnalysisStatus   string     `gorm:"default:'pending'" json:"analysis_status"`
-	AnalysisTime     *time.Time `json:"analysis_time"`
-	ErrorMessage     string     `gorm:"type:text" json:"error_message"`
-	CreatedAt        time.Time  `json:"created_at"`
-	UpdatedAt        time.Time  `json:"updated_at"`
+	ID               uint64                `gorm:"primaryKey;autoIncrement" json:"id"`
+	ArticleID        uint64                `gorm:"not null;index;uniqueIndex:idx_article_version,priority:1" json:"article_id"`
+	CoreViewpoints   string                `gorm:"type:text" json:"core_viewpoints"`
+	FileStructure    string                `gorm:"type:text" json:"file_structure"`
+	AuthorThoughts   string                `gorm:"type:text" json:"author_thoughts"`
+	RelatedMaterials string                `gorm:"type:text" json:"related_materials"`
+	AnalysisStatus   string                `gorm:"default:'pending'" json:"analysis_status"`
+	AnalysisTime     *time.Time            `json:"analysis_time"`
+	ErrorMessage     string                `gorm:"type:text" json:"error_message"`
+	Provider         string                `gorm:"type:varchar(50)" json:"provider"`
+	Profile          string                `gorm:"type:varchar(100)" json:"profile"`
+	Model            string                `gorm:"type:varchar(100)" json:"model"`
+	PromptVersion    string                `gorm:"type:varchar(50)" json:"prompt_version"`
+	Version          int                   `gorm:"not null;default:0;uniqueIndex:idx_article_version,priority:2" json:"version"`
+	IsCurrent        bool                  `gorm:"not null;default:false;index" json:"is_current"`
+	Schema           string                `gorm:"type:varchar(100)" json:"schema"`
+	Genre            string                `gorm:"type:varchar(20)" json:"genre"`
+	GenreSource      string                `gorm:"type:varchar(10)" json:"genre_source"`
+	Result           *model.AnalysisResult `gorm:"type:json" json:"result"`
+	Fields           model.AnalysisFields  `gorm:"type:json" json:"fields,omitempty"`
+	SchemaFields     model.SchemaFields    `gorm:"type:json" json:"schema_fields,omitempty"`
+	ReviewStatus     string                `gorm:"type:varchar(20);not null;default:'draft';index" json:"review_status"`
+	Revision         int                   `gorm:"not null;default:0" json:"revision"`
+	CreatedAt        time.Time             `json:"created_at"`
+	UpdatedAt        time.Time             `json:"updated_at"`
 }
\ No newline at end of file
@@ -104,7 +104,8 @@ func (r *ArticleRepository) GetListWithAnalysis(req *model.PaginationRequest) (*
             a.upload_time, a.created_at, 
             IFNULL(aa.analysis_status, 'none') as analysis_status,
             CASE WHEN aa.id IS NOT NULL THEN true ELSE false END as has_analysis`).
-		Joins("LEFT JOIN article_analyses aa ON a.id = aa.article_id")
+		// 与 AnalysisRepository.GetByArticleID 一致：优先取当前结果，没有时取最新一条
+		Joins("LEFT JOIN article_analyses aa ON aa.id = (SELECT id FROM article_analyses WHERE article_id = a.id ORDER BY is_current DESC, id DESC LIMIT 1)")
 
 	// 搜索条件
 	if req.Keyword != "" {
@@ -153,6 +154,30 @@ func (r *ArticleRepository) GetListWithAnalysis(req *model.PaginationRequest) (*
 	}, nil
 }
 
+// FindIDs 按搜索条件查询文章ID，onlyUnanalyzed 为 true 时排除已有完成分析的文章
+func (r *ArticleRepository) FindIDs(keyword, author string, onlyUnanalyzed bool, limit int) ([]uint64, error) {
+	query := r.db.Model(&model.Article{})
+
+	if keyword != "" {
+		escaped := escapeLike(keyword)
+		pattern := "%" + escaped + "%"
+		query = query.Where("(title LIKE ? ESCAPE '\\' OR content LIKE ? ESCAPE '\\' OR author LIKE ? ESCAPE '\\')",
+			pattern, pattern, pattern)
+	}
+
+	if author != "" {
+		query = query.Where("author = ?", author)
+	}
+
+	if onlyUnanalyzed {
+		query = query.Where("NOT EXISTS (SELECT 1 FROM article_analyses aa WHERE aa.article_id = articles.id AND aa.analysis_status = ?)", "completed")
+	}
+
+	var ids []uint64
+	err := query.Order("id ASC").Limit(limit).Pluck("id", &ids).Error
+	return ids, err
+}
+
 func (r *ArticleRepository) Update(article *model.Article) error {
 	return r.db.Save(article).Error
 }
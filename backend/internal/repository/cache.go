@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"article-analysis/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetCache 按缓存键查询分析结果缓存
+func (r *AnalysisRepository) GetCache(key string) (*model.AnalysisCache, error) {
+	var entry model.AnalysisCache
+	if err := r.db.Where("cache_key = ?", key).First(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// SaveCache 写入分析结果缓存，键已存在时（如强制重新分析）以新结果覆盖
+func (r *AnalysisRepository) SaveCache(entry *model.AnalysisCache) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "cache_key"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"prompt_version", "provider", "profile", "model",
+			"core_viewpoints", "file_structure", "author_thoughts", "related_materials", "result", "fields", "updated_at",
+		}),
+	}).Create(entry).Error
+}
+
+// RecordCacheHit 累计缓存命中次数
+func (r *AnalysisRepository) RecordCacheHit(id uint64) error {
+	now := time.Now()
+	return r.db.Model(&model.AnalysisCache{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"hits":        gorm.Expr("hits + 1"),
+			"last_hit_at": &now,
+		}).Error
+}
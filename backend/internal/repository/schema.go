@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"article-analysis/internal/model"
+)
+
+// ListSchemas 按名称列出全部分析方案
+func (r *AnalysisRepository) ListSchemas() ([]model.AnalysisSchema, error) {
+	var schemas []model.AnalysisSchema
+	err := r.db.Order("name ASC").Find(&schemas).Error
+	return schemas, err
+}
+
+// GetSchema 按名称查询分析方案
+func (r *AnalysisRepository) GetSchema(name string) (*model.AnalysisSchema, error) {
+	var schema model.AnalysisSchema
+	if err := r.db.Where("name = ?", name).First(&schema).Error; err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+func (r *AnalysisRepository) CreateSchema(schema *model.AnalysisSchema) error {
+	return r.db.Create(schema).Error
+}
+
+func (r *AnalysisRepository) UpdateSchema(schema *model.AnalysisSchema) error {
+	return r.db.Save(schema).Error
+}
+
+// DeleteSchema 删除分析方案，已保存的分析结果保留
+func (r *AnalysisRepository) DeleteSchema(name string) (bool, error) {
+	result := r.db.Where("name = ?", name).Delete(&model.AnalysisSchema{})
+	return result.RowsAffected > 0, result.Error
+}
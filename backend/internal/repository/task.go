@@ -0,0 +1,283 @@
+package repository
+
+import (
+	"article-analysis/internal/model"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type TaskRepository struct {
+	db *gorm.DB
+}
+
+func NewTaskRepository(db *gorm.DB) *TaskRepository {
+	return &TaskRepository{db: db}
+}
+
+func (r *TaskRepository) Create(task *model.AnalysisTask) error {
+	return r.db.Create(task).Error
+}
+
+// CreateWithAnalysis 在同一事务中为任务新建下一版本的分析记录并写入任务
+//
+// (article_id, version) 和 active_article_id 上的唯一索引保证并发提交同一文章时只有一个成功，
+// 失败的一方整体回滚
+func (r *TaskRepository) CreateWithAnalysis(analysis *model.ArticleAnalysis, task *model.AnalysisTask) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var version int
+		if err := tx.Model(&model.ArticleAnalysis{}).
+			Where("article_id = ?", analysis.ArticleID).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&version).Error; err != nil {
+			return err
+		}
+		analysis.Version = version + 1
+		if err := tx.Create(analysis).Error; err != nil {
+			return err
+		}
+		task.AnalysisID = analysis.ID
+		return tx.Create(task).Error
+	})
+}
+
+func (r *TaskRepository) GetByID(id uint64) (*model.AnalysisTask, error) {
+	var task model.AnalysisTask
+	err := r.db.First(&task, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// GetByTaskID 按对外暴露的任务ID查询
+func (r *TaskRepository) GetByTaskID(taskID string) (*model.AnalysisTask, error) {
+	var task model.AnalysisTask
+	err := r.db.Where("task_id = ?", taskID).First(&task).Error
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// HasActiveTask 判断文章是否存在排队中或执行中的任务
+func (r *TaskRepository) HasActiveTask(articleID uint64) (bool, error) {
+	var count int64
+	err := r.db.Model(&model.AnalysisTask{}).
+		Where("article_id = ? AND status IN ?", articleID, []string{model.TaskStatusQueued, model.TaskStatusRunning}).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// ClaimNext 认领最早入队的任务并取得租约，没有可认领的任务时返回 nil
+//
+// 认领通过带状态条件的 UPDATE 完成，多个工作协程（或多个实例）并发认领同一任务时只有一个会成功，
+// 失败的一方会重新查找下一个排队任务。
+func (r *TaskRepository) ClaimNext(workerID string, lease time.Duration) (*model.AnalysisTask, error) {
+	for {
+		var task model.AnalysisTask
+		err := r.db.Where("status = ?", model.TaskStatusQueued).Order("id ASC").First(&task).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		leaseUntil := now.Add(lease)
+		result := r.db.Model(&model.AnalysisTask{}).
+			Where("id = ? AND status = ?", task.ID, model.TaskStatusQueued).
+			Updates(map[string]interface{}{
+				"status":      model.TaskStatusRunning,
+				"attempts":    gorm.Expr("attempts + 1"),
+				"worker_id":   workerID,
+				"lease_until": &leaseUntil,
+				"started_at":  &now,
+				"updated_at":  now,
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			// 被其他工作协程抢先认领，继续尝试下一个
+			continue
+		}
+
+		task.Status = model.TaskStatusRunning
+		task.Attempts++
+		task.WorkerID = workerID
+		task.LeaseUntil = &leaseUntil
+		task.StartedAt = &now
+		return &task, nil
+	}
+}
+
+// Heartbeat 续约执行中的任务，返回 false 表示任务已不再由该执行者持有
+func (r *TaskRepository) Heartbeat(id uint64, workerID string, lease time.Duration) (bool, error) {
+	now := time.Now()
+	leaseUntil := now.Add(lease)
+	result := r.db.Model(&model.AnalysisTask{}).
+		Where("id = ? AND status = ? AND worker_id = ?", id, model.TaskStatusRunning, workerID).
+		Updates(map[string]interface{}{
+			"lease_until": &leaseUntil,
+			"updated_at":  now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ListExpired 查询租约已过期的执行中任务
+func (r *TaskRepository) ListExpired(now time.Time) ([]model.AnalysisTask, error) {
+	var tasks []model.AnalysisTask
+	err := r.db.Where("status = ? AND (lease_until IS NULL OR lease_until < ?)", model.TaskStatusRunning, now).
+		Order("id ASC").
+		Find(&tasks).Error
+	return tasks, err
+}
+
+// Requeue 将执行中的任务放回队列，refund 为 true 时不计入执行次数
+//
+// 只有仍由 workerID 持有（或 workerID 为空时任意持有者）的执行中任务会被放回，返回是否成功。
+func (r *TaskRepository) Requeue(id uint64, workerID string, refund bool) (bool, error) {
+	updates := map[string]interface{}{
+		"status":      model.TaskStatusQueued,
+		"progress":    0,
+		"worker_id":   "",
+		"lease_until": nil,
+		"started_at":  nil,
+		"updated_at":  time.Now(),
+	}
+	if refund {
+		updates["attempts"] = gorm.Expr("attempts - 1")
+	}
+
+	query := r.db.Model(&model.AnalysisTask{}).Where("id = ? AND status = ?", id, model.TaskStatusRunning)
+	if workerID != "" {
+		query = query.Where("worker_id = ?", workerID)
+	}
+	result := query.Updates(updates)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// UpdateProgress 更新任务进度（0-100）
+func (r *TaskRepository) UpdateProgress(id uint64, progress int) error {
+	return r.db.Model(&model.AnalysisTask{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"progress":   progress,
+			"updated_at": time.Now(),
+		}).Error
+}
+
+// SetModel 记录执行任务所用的模型
+func (r *TaskRepository) SetModel(id uint64, modelName string) error {
+	return r.db.Model(&model.AnalysisTask{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"model":      modelName,
+			"updated_at": time.Now(),
+		}).Error
+}
+
+// MarkCacheHit 标记任务结果来自缓存
+func (r *TaskRepository) MarkCacheHit(id uint64) error {
+	return r.db.Model(&model.AnalysisTask{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"cache_hit":  true,
+			"updated_at": time.Now(),
+		}).Error
+}
+
+func (r *TaskRepository) CreateBatch(batch *model.AnalysisBatch) error {
+	return r.db.Create(batch).Error
+}
+
+func (r *TaskRepository) UpdateBatch(batch *model.AnalysisBatch) error {
+	return r.db.Save(batch).Error
+}
+
+func (r *TaskRepository) GetBatch(batchID string) (*model.AnalysisBatch, error) {
+	var batch model.AnalysisBatch
+	err := r.db.Where("batch_id = ?", batchID).First(&batch).Error
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// ListByBatch 查询批次内的全部任务
+func (r *TaskRepository) ListByBatch(batchID string) ([]model.AnalysisTask, error) {
+	var tasks []model.AnalysisTask
+	err := r.db.Where("batch_id = ?", batchID).Order("id ASC").Find(&tasks).Error
+	return tasks, err
+}
+
+// AddAttempt 记录一次模型调用尝试
+func (r *TaskRepository) AddAttempt(attempt *model.AnalysisTaskAttempt) error {
+	return r.db.Create(attempt).Error
+}
+
+// ListAttempts 按时间顺序查询任务的模型调用尝试
+func (r *TaskRepository) ListAttempts(taskID uint64) ([]model.AnalysisTaskAttempt, error) {
+	var attempts []model.AnalysisTaskAttempt
+	err := r.db.Where("task_id = ?", taskID).Order("id ASC").Find(&attempts).Error
+	return attempts, err
+}
+
+// Cancel 取消排队中或执行中的任务，返回取消前的状态；任务已结束时返回空字符串
+func (r *TaskRepository) Cancel(id uint64) (string, error) {
+	for _, status := range []string{model.TaskStatusQueued, model.TaskStatusRunning} {
+		now := time.Now()
+		result := r.db.Model(&model.AnalysisTask{}).
+			Where("id = ? AND status = ?", id, status).
+			Updates(map[string]interface{}{
+				"status":            model.TaskStatusCancelled,
+				"error_message":     "任务已取消",
+				"lease_until":       nil,
+				"active_article_id": nil,
+				"finished_at":       &now,
+				"updated_at":        now,
+			})
+		if result.Error != nil {
+			return "", result.Error
+		}
+		if result.RowsAffected > 0 {
+			return status, nil
+		}
+	}
+	return "", nil
+}
+
+// Finish 将任务置为终态，workerID 非空时只更新仍由该执行者持有的执行中任务，返回是否更新了任务
+func (r *TaskRepository) Finish(id uint64, workerID string, status string, errorMsg string) (bool, error) {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":            status,
+		"error_message":     errorMsg,
+		"lease_until":       nil,
+		"active_article_id": nil,
+		"finished_at":       &now,
+		"updated_at":        now,
+	}
+	if status == model.TaskStatusCompleted {
+		updates["progress"] = 100
+	}
+
+	query := r.db.Model(&model.AnalysisTask{}).Where("id = ?", id)
+	if workerID != "" {
+		query = query.Where("worker_id = ? AND status = ?", workerID, model.TaskStatusRunning)
+	}
+	result := query.Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"article-analysis/internal/model"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 用量统计的分组维度
+const (
+	UsageGroupDay       = "day"
+	UsageGroupModel     = "model"
+	UsageGroupAuthor    = "author"
+	UsageGroupRequester = "requester"
+)
+
+// UsageQuery 用量统计条件，统计 [From, To) 内发生的模型调用
+type UsageQuery struct {
+	From    time.Time
+	To      time.Time
+	GroupBy []string
+}
+
+// UsageRow 一个分组的用量汇总
+type UsageRow struct {
+	Day              string  `json:"day,omitempty"`
+	Model            string  `json:"model,omitempty"`
+	Author           string  `json:"author,omitempty"`
+	Requester        string  `json:"requester,omitempty"`
+	Runs             int64   `json:"runs"`  // 涉及的分析任务数
+	Calls            int64   `json:"calls"` // 模型调用次数，含失败的调用
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// AddUsage 累加任务的模型用量
+func (r *TaskRepository) AddUsage(id uint64, promptTokens, completionTokens int, latencyMs int64, cost float64) error {
+	return r.db.Model(&model.AnalysisTask{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"prompt_tokens":     gorm.Expr("prompt_tokens + ?", promptTokens),
+			"completion_tokens": gorm.Expr("completion_tokens + ?", completionTokens),
+			"latency_ms":        gorm.Expr("latency_ms + ?", latencyMs),
+			"cost":              gorm.Expr("cost + ?", cost),
+			"updated_at":        time.Now(),
+		}).Error
+}
+
+// UsageReport 按天、模型、作者汇总模型调用用量
+//
+// 以每次调用为统计单位，发生降级或分段分析时同一任务的用量会按实际使用的模型分别计入；
+// 文章被删除后作者为空。
+func (r *TaskRepository) UsageReport(q UsageQuery) ([]UsageRow, error) {
+	// 按存储的本地时间取日期，避免 SQLite 日期函数换算到 UTC
+	dayExpr := "DATE_FORMAT(c.created_at, '%Y-%m-%d')"
+	if r.db.Dialector.Name() == "sqlite" {
+		dayExpr = "substr(c.created_at, 1, 10)"
+	}
+
+	columns := []string{
+		"COUNT(DISTINCT c.task_id) AS runs",
+		"COUNT(*) AS calls",
+		"IFNULL(SUM(c.prompt_tokens), 0) AS prompt_tokens",
+		"IFNULL(SUM(c.completion_tokens), 0) AS completion_tokens",
+		"IFNULL(SUM(c.cost), 0) AS cost",
+	}
+	var groups []string
+	for _, dim := range q.GroupBy {
+		switch dim {
+		case UsageGroupDay:
+			columns = append(columns, dayExpr+" AS day")
+			groups = append(groups, dayExpr)
+		case UsageGroupModel:
+			columns = append(columns, "IFNULL(c.model, '') AS model")
+			groups = append(groups, "IFNULL(c.model, '')")
+		case UsageGroupAuthor:
+			columns = append(columns, "IFNULL(a.author, '') AS author")
+			groups = append(groups, "IFNULL(a.author, '')")
+		case UsageGroupRequester:
+			columns = append(columns, "IFNULL(t.requester, '') AS requester")
+			groups = append(groups, "IFNULL(t.requester, '')")
+		}
+	}
+
+	query := r.db.Table("analysis_task_attempts c").
+		Select(strings.Join(columns, ", ")).
+		Joins("JOIN analysis_tasks t ON t.id = c.task_id").
+		Joins("LEFT JOIN articles a ON a.id = t.article_id").
+		Where("c.created_at >= ? AND c.created_at < ?", q.From, q.To)
+	for _, g := range groups {
+		query = query.Group(g).Order(g)
+	}
+
+	var rows []UsageRow
+	err := query.Scan(&rows).Error
+	return rows, err
+}
+
+// UsageTotals 一段时间内的用量合计
+type UsageTotals struct {
+	Tokens int64
+	Cost   float64
+}
+
+// SumUsage 统计 since 之后的已发生用量，加上排队中、执行中任务尚未用掉的预估用量；requester 为空时统计全部调用方
+//
+// 执行中任务的已发生用量已计入调用记录，预估只按剩余部分占用额度，实际用量超出预估后不再占用。
+func (r *TaskRepository) SumUsage(since time.Time, requester string) (UsageTotals, error) {
+	var used UsageTotals
+	query := r.db.Table("analysis_task_attempts c").
+		Select("IFNULL(SUM(c.prompt_tokens + c.completion_tokens), 0) AS tokens, IFNULL(SUM(c.cost), 0) AS cost").
+		Joins("JOIN analysis_tasks t ON t.id = c.task_id").
+		Where("c.created_at >= ?", since)
+	if requester != "" {
+		query = query.Where("t.requester = ?", requester)
+	}
+	if err := query.Scan(&used).Error; err != nil {
+		return used, err
+	}
+
+	var reserved UsageTotals
+	query = r.db.Model(&model.AnalysisTask{}).
+		Select("IFNULL(SUM(CASE WHEN estimated_tokens > prompt_tokens + completion_tokens "+
+			"THEN estimated_tokens - prompt_tokens - completion_tokens ELSE 0 END), 0) AS tokens, "+
+			"IFNULL(SUM(CASE WHEN estimated_cost > cost THEN estimated_cost - cost ELSE 0 END), 0) AS cost").
+		Where("status IN ? AND created_at >= ?", []string{model.TaskStatusQueued, model.TaskStatusRunning}, since)
+	if requester != "" {
+		query = query.Where("requester = ?", requester)
+	}
+	if err := query.Scan(&reserved).Error; err != nil {
+		return used, err
+	}
+
+	return UsageTotals{Tokens: used.Tokens + reserved.Tokens, Cost: used.Cost + reserved.Cost}, nil
+}
+
+// CreateBudgetAlert 记录预算预警，同一额度同一周期同一级别已存在时不重复记录，返回是否新建
+func (r *TaskRepository) CreateBudgetAlert(alert *model.BudgetAlert) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(alert)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListBudgetAlerts 按时间倒序查询最近的预算预警，scopes 为空时不限范围
+func (r *TaskRepository) ListBudgetAlerts(scopes []string, limit int) ([]model.BudgetAlert, error) {
+	var alerts []model.BudgetAlert
+	query := r.db.Order("id DESC").Limit(limit)
+	if len(scopes) > 0 {
+		query = query.Where("scope IN ?", scopes)
+	}
+	err := query.Find(&alerts).Error
+	return alerts, err
+}
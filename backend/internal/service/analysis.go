@@ -8,25 +8,299 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
 )
 
 type AnalysisService struct {
-	analysisRepo *repository.AnalysisRepository
-	articleRepo  *repository.ArticleRepository
-	openaiClient *OpenAIClient
-	log          *logger.Logger
+	analysisRepo   *repository.AnalysisRepository
+	articleRepo    *repository.ArticleRepository
+	taskRepo       *repository.TaskRepository
+	analyzers      map[string]Analyzer
+	profiles       []config.ModelProfile
+	defaultProfile string
+	pool           *WorkerPool
+	timeout        time.Duration
+	lease          time.Duration
+	maxAttempts    int
+	maxBatchSize   int
+	maxTokens      int     // 单次分析预估 token 上限
+	maxCost        float64 // 单次分析预估费用上限
+	currency       string
+	budget         config.BudgetConfig
+	budgetMu       sync.Mutex // 串行化额度检查与任务入库
+	prompts        *PromptRegistry
+	genrePrompts   map[string]string // 体裁对应的提示词版本
+	events         *taskEventHub
+	bus            *EventBus
+	log            *logger.Logger
 }
 
-func NewAnalysisService(analysisRepo *repository.AnalysisRepository, articleRepo *repository.ArticleRepository, cfg *config.Config, log *logger.Logger) *AnalysisService {
-	return &AnalysisService{
-		analysisRepo: analysisRepo,
-		articleRepo:  articleRepo,
-		openaiClient: NewOpenAIClient(cfg, log),
-		log:          log,
+// NewAnalysisService 为每个模型配置创建分析器，各分析器共享模型服务的熔断状态
+func NewAnalysisService(analysisRepo *repository.AnalysisRepository, articleRepo *repository.ArticleRepository, taskRepo *repository.TaskRepository, cfg *config.Config, log *logger.Logger) *AnalysisService {
+	registry := newBackendRegistry(cfg, log)
+	analyzers := make(map[string]Analyzer)
+	for _, profile := range cfg.OpenAI.ResolvedProfiles() {
+		analyzers[profile.Name] = newOpenAIClientFromRegistry(cfg, registry, profile.Name, log)
 	}
+	return NewAnalysisServiceWithAnalyzers(analysisRepo, articleRepo, taskRepo, analyzers, cfg, log)
+}
+
+// NewAnalysisServiceWithAnalyzer 使用指定的分析器作为默认模型配置创建服务，便于替换模型实现或在测试中注入
+func NewAnalysisServiceWithAnalyzer(analysisRepo *repository.AnalysisRepository, articleRepo *repository.ArticleRepository, taskRepo *repository.TaskRepository, analyzer Analyzer, cfg *config.Config, log *logger.Logger) *AnalysisService {
+	analyzers := map[string]Analyzer{cfg.OpenAI.DefaultProfileName(): analyzer}
+	return NewAnalysisServiceWithAnalyzers(analysisRepo, articleRepo, taskRepo, analyzers, cfg, log)
+}
+
+// NewAnalysisServiceWithAnalyzers 使用按模型配置名索引的分析器创建服务
+func NewAnalysisServiceWithAnalyzers(analysisRepo *repository.AnalysisRepository, articleRepo *repository.ArticleRepository, taskRepo *repository.TaskRepository, analyzers map[string]Analyzer, cfg *config.Config, log *logger.Logger) *AnalysisService {
+	s := &AnalysisService{
+		analysisRepo:   analysisRepo,
+		articleRepo:    articleRepo,
+		taskRepo:       taskRepo,
+		analyzers:      analyzers,
+		defaultProfile: cfg.OpenAI.DefaultProfileName(),
+		timeout:        time.Duration(cfg.Analysis.Timeout) * time.Second,
+		lease:          time.Duration(cfg.Analysis.LeaseDuration) * time.Second,
+		maxAttempts:    cfg.Analysis.MaxAttempts,
+		maxBatchSize:   cfg.Analysis.MaxBatchSize,
+		maxTokens:      cfg.Analysis.MaxEstimatedTokens,
+		maxCost:        cfg.Analysis.MaxEstimatedCost,
+		currency:       cfg.OpenAI.Currency,
+		budget:         cfg.Budget,
+		events:         newTaskEventHub(),
+		log:            log,
+	}
+	for _, profile := range cfg.OpenAI.ResolvedProfiles() {
+		if _, ok := analyzers[profile.Name]; ok {
+			s.profiles = append(s.profiles, profile)
+		}
+	}
+	prompts, err := NewPromptRegistry(cfg.Analysis.PromptDir, cfg.Analysis.DefaultPrompt)
+	if err != nil {
+		log.Error("加载提示词模板失败，仅使用内置提示词", err, zap.String("prompt_dir", cfg.Analysis.PromptDir))
+		prompts, _ = NewPromptRegistry("", "")
+	}
+	s.prompts = prompts
+	s.initGenrePrompts(cfg.Analysis.GenrePrompts)
+	if _, ok := analyzers[s.defaultProfile]; !ok {
+		log.Warn("默认模型配置不存在", zap.String("profile", s.defaultProfile))
+	}
+	if s.timeout <= 0 {
+		s.timeout = 120 * time.Second
+	}
+	if s.lease <= 0 {
+		s.lease = 60 * time.Second
+	}
+	if s.maxAttempts <= 0 {
+		s.maxAttempts = 3
+	}
+	if s.maxBatchSize <= 0 {
+		s.maxBatchSize = 500
+	}
+	s.pool = NewWorkerPool(taskRepo, cfg.Analysis, s.performAnalysis, log)
+	s.pool.OnStatusChange(s.onTaskStatus)
+	s.pool.OnRelease(s.onTaskReleased)
+	return s
+}
+
+// ProfileInfo 对外展示的模型配置（不含密钥）
+type ProfileInfo struct {
+	Name        string  `json:"name"`
+	Provider    string  `json:"provider"`
+	Model       string  `json:"model"`
+	Temperature float32 `json:"temperature"`
+	MaxTokens   int     `json:"max_tokens"`
+	Timeout     int     `json:"timeout"`
+	Default     bool    `json:"default"`
+}
+
+// ListProfiles 列出可用的模型配置
+func (s *AnalysisService) ListProfiles() []ProfileInfo {
+	list := make([]ProfileInfo, 0, len(s.profiles))
+	for _, p := range s.profiles {
+		provider := p.Provider
+		if provider == "" {
+			provider = ProviderOpenAI
+		}
+		list = append(list, ProfileInfo{
+			Name:        p.Name,
+			Provider:    provider,
+			Model:       p.Model,
+			Temperature: p.GetTemperature(),
+			MaxTokens:   p.MaxTokens,
+			Timeout:     p.Timeout,
+			Default:     p.Name == s.defaultProfile,
+		})
+	}
+	return list
+}
+
+// ListPrompts 列出可用的提示词版本
+func (s *AnalysisService) ListPrompts() []PromptInfo {
+	return s.prompts.List()
+}
+
+// resolveProfile 返回模型配置名对应的分析器，名称为空时使用默认配置
+func (s *AnalysisService) resolveProfile(name string) (string, Analyzer, error) {
+	if name == "" {
+		name = s.defaultProfile
+	}
+	analyzer, ok := s.analyzers[name]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %s", ErrUnknownProfile, name)
+	}
+	return name, analyzer, nil
+}
+
+// profileConfig 返回模型配置名对应的配置
+func (s *AnalysisService) profileConfig(name string) (config.ModelProfile, bool) {
+	for _, p := range s.profiles {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return config.ModelProfile{}, false
+}
+
+// profileTimeout 返回模型配置的分析超时，未单独配置时使用全局超时
+func (s *AnalysisService) profileTimeout(name string) time.Duration {
+	if p, ok := s.profileConfig(name); ok && p.Timeout > 0 {
+		return time.Duration(p.Timeout) * time.Second
+	}
+	return s.timeout
+}
+
+// Start 启动分析工作池及过期任务回收
+func (s *AnalysisService) Start(ctx context.Context) {
+	s.pool.Start(ctx)
+
+	go func() {
+		ticker := time.NewTicker(s.lease / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.RecoverStaleTasks(); err != nil {
+					s.log.Error("回收过期任务失败", err)
+				}
+			}
+		}
+	}()
+}
+
+// RecoverOnStartup 启动时的恢复流程：回收租约已过期的任务，为早期的分析记录补充版本号，
+// 并将没有任何活动任务对应、却停留在处理中的分析记录标记为失败（如升级前遗留的记录）
+func (s *AnalysisService) RecoverOnStartup() error {
+	if err := s.RecoverStaleTasks(); err != nil {
+		return err
+	}
+
+	if count, err := s.analysisRepo.MigrateLegacy(); err != nil {
+		return fmt.Errorf("补充分析版本号失败: %w", err)
+	} else if count > 0 {
+		s.log.Info("已为早期分析记录补充版本号", zap.Int64("count", count))
+	}
+
+	count, err := s.analysisRepo.FailOrphaned("分析任务已中断，请重新提交")
+	if err != nil {
+		return fmt.Errorf("清理遗留分析记录失败: %w", err)
+	}
+	if count > 0 {
+		s.log.Info("已清理遗留的处理中分析记录", zap.Int64("count", count))
+	}
+	return nil
+}
+
+// RecoverStaleTasks 处理租约已过期的执行中任务：
+// 未达到最大执行次数的重新排队，否则标记为失败
+func (s *AnalysisService) RecoverStaleTasks() error {
+	tasks, err := s.taskRepo.ListExpired(time.Now())
+	if err != nil {
+		return fmt.Errorf("查询过期任务失败: %w", err)
+	}
+
+	requeued := false
+	for _, task := range tasks {
+		if task.Attempts < s.maxAttempts {
+			ok, err := s.taskRepo.Requeue(task.ID, task.WorkerID, false)
+			if err != nil {
+				s.log.Error("过期任务重新排队失败", err, zap.String("task_id", task.TaskID))
+				continue
+			}
+			if ok {
+				s.analysisRepo.UpdateStatus(task.AnalysisID, "pending", "")
+				s.log.Warn("任务租约过期，已重新排队",
+					zap.String("task_id", task.TaskID),
+					zap.Int("attempts", task.Attempts))
+				requeued = true
+			}
+			continue
+		}
+
+		errorMsg := fmt.Sprintf("分析任务多次中断（%d次），已放弃", task.Attempts)
+		finished, err := s.taskRepo.Finish(task.ID, task.WorkerID, model.TaskStatusFailed, errorMsg)
+		if err != nil {
+			s.log.Error("标记过期任务失败", err, zap.String("task_id", task.TaskID))
+			continue
+		}
+		if !finished {
+			continue
+		}
+		s.analysisRepo.UpdateStatus(task.AnalysisID, "failed", errorMsg)
+		s.log.Warn("任务租约过期且超过最大执行次数，已标记失败", zap.String("task_id", task.TaskID))
+	}
+
+	if requeued {
+		s.pool.Notify()
+	}
+	return nil
+}
+
+// Wait 等待工作池中正在执行的任务结束
+func (s *AnalysisService) Wait() {
+	s.pool.Wait()
+}
+
+// structuredResult 返回需要保存的结构化结果，分析器只返回文本时为 nil
+func structuredResult(result *AnalysisResponse) *model.AnalysisResult {
+	if result.Result.IsEmpty() {
+		return nil
+	}
+	structured := result.Result
+	return &structured
+}
+
+// modelNamer 可报告所用模型的分析器
+type modelNamer interface {
+	getModel() string
+}
+
+// usageEstimator 可预估分析用量的分析器
+type usageEstimator interface {
+	estimateUsage(content string, prompt *PromptTemplate, schema *model.AnalysisSchema) UsageEstimate
+}
+
+var (
+	ErrArticleNotFound = errors.New("文章不存在")
+	ErrTaskNotFound    = errors.New("任务不存在")
+	ErrTaskFinished    = errors.New("任务已结束，无法取消")
+	ErrTaskInProgress  = errors.New("分析任务正在进行中")
+	ErrUnknownProfile  = errors.New("模型配置不存在")
+)
+
+// AnalyzeOptions 提交分析时的可选参数
+type AnalyzeOptions struct {
+	Profile   string // 模型配置名，为空时使用默认配置
+	Requester string // 调用方标识，用于按调用方统计用量和检查额度，为空时视为匿名调用方
+	Force     bool   // 忽略缓存，重新调用模型分析
+	Prompt    string // 提示词版本，为空时使用默认版本
+	Schema    string // 分析方案名，为空时使用内置方案
+	Genre     string // 文章体裁，为空时自动识别
 }
 
 type AnalysisTask struct {
@@ -35,85 +309,299 @@ type AnalysisTask struct {
 	Status    string
 }
 
-func (s *AnalysisService) AnalyzeArticle(articleID uint64) (*AnalysisTask, error) {
+func (s *AnalysisService) AnalyzeArticle(articleID uint64, opts AnalyzeOptions) (*AnalysisTask, error) {
 	// 检查文章是否存在
+	if _, err := s.articleRepo.GetByID(articleID); err != nil {
+		return nil, ErrArticleNotFound
+	}
+
+	task, err := s.enqueue(articleID, "", opts)
+	if err != nil {
+		return nil, err
+	}
+	s.pool.Notify()
+
+	return &AnalysisTask{
+		TaskID:    task.TaskID,
+		ArticleID: articleID,
+		Status:    "pending",
+	}, nil
+}
+
+// enqueue 为文章创建分析任务，等待工作池执行
+func (s *AnalysisService) enqueue(articleID uint64, batchID string, opts AnalyzeOptions) (*model.AnalysisTask, error) {
+	return s.createTask(articleID, batchID, opts, false)
+}
+
+// createTask 创建分析记录和任务。对比分析的多个任务同时提交，由调用方检查是否已有进行中的任务，
+// 其结果完成后不自动成为当前结果
+func (s *AnalysisService) createTask(articleID uint64, batchID string, opts AnalyzeOptions, comparison bool) (*model.AnalysisTask, error) {
+	profile, analyzer, err := s.resolveProfile(opts.Profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateGenre(opts.Genre); err != nil {
+		return nil, err
+	}
+	schema, err := s.resolveSchema(opts.Schema)
+	if err != nil {
+		return nil, err
+	}
+	opts.Requester = requesterOrAnonymous(opts.Requester)
+
+	if !comparison {
+		if err := s.checkNoActiveTask(articleID); err != nil {
+			return nil, err
+		}
+	}
+
 	article, err := s.articleRepo.GetByID(articleID)
 	if err != nil {
-		return nil, errors.New("文章不存在")
+		return nil, ErrArticleNotFound
 	}
-	
-	// 检查是否已有分析任务
-	existingAnalysis, err := s.analysisRepo.GetByArticleID(articleID)
-	if err == nil && existingAnalysis.AnalysisStatus == "processing" {
-		return nil, errors.New("分析任务正在进行中")
+	genre, genreSource, prompt, err := s.choosePrompt(article.Content, opts)
+	if err != nil {
+		return nil, err
 	}
-	
-	// 创建分析任务
-	taskID := fmt.Sprintf("task_%d_%d", articleID, time.Now().Unix())
-	analysis := &model.ArticleAnalysis{
-		ArticleID:      articleID,
-		AnalysisStatus: "processing", // 直接设置为处理中
+	if err := prompt.supports(schema); err != nil {
+		return nil, err
 	}
-	
-	if existingAnalysis == nil {
-		if err := s.analysisRepo.Create(analysis); err != nil {
-			return nil, errors.New("创建分析任务失败")
+	// 命中缓存时不会调用模型，无需预估用量和占用额度
+	var estimate *ModelEstimate
+	if opts.Force || s.lookupCache(analyzer, prompt, schema, article.Content) == nil {
+		if estimate, err = s.checkEstimate(article, profile, analyzer, prompt, schema); err != nil {
+			return nil, err
 		}
-	} else {
-		analysis = existingAnalysis
-		analysis.AnalysisStatus = "processing"
-		analysis.ErrorMessage = ""
-		if err := s.analysisRepo.Update(analysis); err != nil {
-			return nil, errors.New("更新分析任务失败")
+		// 从检查额度到任务入库（占用额度）期间加锁，避免并发提交同时通过检查而超出额度；
+		// 只在本进程内有效，多实例部署时仍可能略微超出
+		s.budgetMu.Lock()
+		defer s.budgetMu.Unlock()
+		if err := s.checkBudget(opts.Requester, estimate); err != nil {
+			return nil, err
 		}
 	}
-	
-	// 异步执行分析任务
-	go s.performAnalysis(articleID, article.Content)
-	
-	return &AnalysisTask{
-		TaskID:    taskID,
+
+	// 每次分析新建一条记录，完成后成为文章的当前结果，之前的结果保留
+	analysis := &model.ArticleAnalysis{
+		ArticleID:      articleID,
+		AnalysisStatus: "pending",
+		Profile:        profile,
+		PromptVersion:  prompt.Version,
+		Schema:         schema.Name,
+		Genre:          genre,
+		GenreSource:    genreSource,
+	}
+	task := &model.AnalysisTask{
+		TaskID:    newOpaqueID(),
+		BatchID:   batchID,
 		ArticleID: articleID,
-		Status:    "processing",
-	}, nil
+		Status:    model.TaskStatusQueued,
+		Profile:   profile,
+		Requester: opts.Requester,
+
+		PromptVersion: prompt.Version,
+		Schema:        schema.Name,
+		Genre:         genre,
+		GenreSource:   genreSource,
+		ForceRefresh:  opts.Force,
+		Comparison:    comparison,
+	}
+	if !comparison {
+		task.ActiveArticleID = &articleID
+	}
+	if estimate != nil {
+		task.EstimatedTokens = estimate.InputTokens + estimate.OutputTokens
+		task.EstimatedCost = estimate.Cost
+	}
+	if err := s.taskRepo.CreateWithAnalysis(analysis, task); err != nil {
+		// 并发提交同一文章时唯一索引冲突，此时另一次提交的任务已入队
+		if active, _ := s.taskRepo.HasActiveTask(articleID); active {
+			return nil, ErrTaskInProgress
+		}
+		s.log.Error("任务入队失败", err)
+		return nil, errors.New("创建分析任务失败")
+	}
+	s.publishTaskEvent(task, TaskEvent{Type: TaskEventQueued, Status: task.Status})
+	return task, nil
 }
 
-func (s *AnalysisService) performAnalysis(articleID uint64, content string) {
+// checkNoActiveTask 文章已有排队中或执行中的任务时返回 ErrTaskInProgress；
+// 只用于尽早拒绝，并发提交由任务表的唯一索引保证
+func (s *AnalysisService) checkNoActiveTask(articleID uint64) error {
+	active, err := s.taskRepo.HasActiveTask(articleID)
+	if err != nil {
+		s.log.Error("查询分析任务失败", err)
+		return errors.New("创建分析任务失败")
+	}
+	if active {
+		return ErrTaskInProgress
+	}
+	return nil
+}
+
+// performAnalysis 由工作池调用，执行一次已认领的分析任务
+func (s *AnalysisService) performAnalysis(ctx context.Context, task *model.AnalysisTask) error {
+	articleID := task.ArticleID
+
+	article, err := s.articleRepo.GetByID(articleID)
+	if err != nil {
+		s.analysisRepo.UpdateStatus(task.AnalysisID, "failed", "文章不存在")
+		return errors.New("文章不存在")
+	}
+
 	// 更新状态为处理中
-	if err := s.analysisRepo.UpdateStatus(articleID, "processing", ""); err != nil {
+	if err := s.analysisRepo.UpdateStatus(task.AnalysisID, "processing", ""); err != nil {
 		s.log.Error("更新分析状态失败", err)
-		return
+		return fmt.Errorf("更新分析状态失败: %w", err)
 	}
-	
-	// 使用OpenAI客户端进行分析
-	analysisResult, err := s.openaiClient.AnalyzeArticle(timeoutContext(), content)
+	s.setProgress(task, 10)
+	s.publishTaskEvent(task, TaskEvent{Type: TaskEventStarted, Status: model.TaskStatusRunning, Progress: 10, Attempt: task.Attempts})
+
+	profile, analyzer, err := s.resolveProfile(task.Profile)
 	if err != nil {
-		s.log.Error("AI分析失败", err)
-		s.analysisRepo.UpdateStatus(articleID, "failed", fmt.Sprintf("AI分析失败: %v", err))
-		return
+		s.analysisRepo.UpdateStatus(task.AnalysisID, "failed", err.Error())
+		return err
+	}
+	prompt, err := s.prompts.Get(task.PromptVersion)
+	if err != nil {
+		s.analysisRepo.UpdateStatus(task.AnalysisID, "failed", err.Error())
+		return err
+	}
+	schema, err := s.resolveSchema(task.Schema)
+	if err != nil {
+		s.analysisRepo.UpdateStatus(task.AnalysisID, "failed", err.Error())
+		return err
+	}
+	if named, ok := analyzer.(modelNamer); ok {
+		if err := s.taskRepo.SetModel(task.ID, named.getModel()); err != nil {
+			s.log.Warn("记录任务模型失败", zap.Error(err))
+		}
 	}
-	
+
+	taskCtx := ctx
+	analysisResult := s.cachedResult(task, analyzer, prompt, schema, article.Content)
+	if analysisResult == nil {
+		// 长文分段分析需要多次调用模型，超时按调用次数累计
+		timeout := s.profileTimeout(profile)
+		if estimator, ok := analyzer.(usageEstimator); ok {
+			if calls := estimator.estimateUsage(article.Content, prompt, schema).Calls; calls > 1 {
+				timeout *= time.Duration(calls)
+			}
+		}
+
+		runCtx, cancel := context.WithTimeout(taskCtx, timeout)
+		defer cancel()
+		runCtx = WithPromptTemplate(runCtx, prompt)
+		runCtx = WithAnalysisSchema(runCtx, schema)
+		// 一次模型调用结束后又开始输出（降级到其他服务、修复无效输出等）时，
+		// 先发布 retrying 事件让订阅者丢弃上一次调用的增量内容；观察者在同一协程中依次调用
+		var finished *LLMAttempt
+		runCtx = WithAttemptObserver(runCtx, func(attempt LLMAttempt) {
+			s.recordAttempt(task, attempt)
+			finished = nil
+			if attempt.Wait > 0 {
+				s.publishTaskEvent(task, retryingEvent(attempt))
+				return
+			}
+			finished = &attempt
+		})
+		runCtx = WithChunkObserver(runCtx, func(done, total int) {
+			finished = nil
+			progress := 10 + 80*done/total
+			s.setProgress(task, progress)
+			s.publishTaskEvent(task, TaskEvent{Type: TaskEventChunk, Progress: progress, Chunk: done, Total: total})
+		})
+		if s.events.watched(task.TaskID) {
+			// 只在有订阅者时使用流式调用，无人查看的任务（如批量分析）保持普通调用
+			runCtx = WithTokenObserver(runCtx, func(delta string) {
+				if finished != nil {
+					s.publishTaskEvent(task, retryingEvent(*finished))
+					finished = nil
+				}
+				s.publishTaskEvent(task, TaskEvent{Type: TaskEventToken, Delta: delta})
+			})
+		}
+
+		analysisResult, err = analyzer.AnalyzeArticle(runCtx, article.Content)
+		if err != nil && taskCtx.Err() != nil {
+			return s.taskStopped(taskCtx, task.AnalysisID)
+		}
+		if err != nil {
+			s.log.Error("AI分析失败", err)
+			errorMsg := fmt.Sprintf("AI分析失败: %v", err)
+			s.analysisRepo.UpdateStatus(task.AnalysisID, "failed", errorMsg)
+			return errors.New(errorMsg)
+		}
+
+		if taskCtx.Err() != nil {
+			return s.taskStopped(taskCtx, task.AnalysisID)
+		}
+		s.saveCache(analyzer, profile, prompt, schema, article.Content, analysisResult)
+	}
+	if analysisResult.Model != "" {
+		// 发生降级时以实际使用的模型为准
+		if err := s.taskRepo.SetModel(task.ID, analysisResult.Model); err != nil {
+			s.log.Warn("记录任务模型失败", zap.Error(err))
+		}
+	}
+	s.setProgress(task, 90)
+
 	// 保存分析结果
-	analysis, err := s.analysisRepo.GetByArticleID(articleID)
+	analysis, err := s.analysisRepo.GetByID(task.AnalysisID)
 	if err != nil {
 		s.log.Error("获取分析记录失败", err)
-		return
+		return fmt.Errorf("获取分析记录失败: %w", err)
 	}
-	
+
+	now := time.Now()
 	analysis.CoreViewpoints = analysisResult.CoreViewpoints
 	analysis.FileStructure = analysisResult.FileStructure
 	analysis.AuthorThoughts = analysisResult.AuthorThoughts
 	analysis.RelatedMaterials = analysisResult.RelatedMaterials
+	analysis.Result = structuredResult(analysisResult)
+	analysis.Fields = analysisResult.Fields
+	verified, total := groundResult(article.Content, analysis.Result)
+	fieldsVerified, fieldsTotal := groundFields(article.Content, schema, analysis.Fields)
+	if verified, total = verified+fieldsVerified, total+fieldsTotal; verified < total {
+		s.log.Warn("部分要点的引文无法在原文中找到", zap.Uint64("task_id", task.ID),
+			zap.Int("verified", verified), zap.Int("total", total))
+	}
 	analysis.AnalysisStatus = "completed"
+	analysis.AnalysisTime = &now
 	analysis.ErrorMessage = ""
-	
+	analysis.Provider = analysisResult.Provider
+	analysis.Profile = analysisResult.Profile
+	analysis.Model = analysisResult.Model
+	analysis.PromptVersion = prompt.Version
+	analysis.Schema = schema.Name
+	analysis.SchemaFields = nil
+	if !isDefaultSchema(schema) {
+		analysis.SchemaFields = schema.Fields
+	}
+	analysis.Genre = task.Genre
+	analysis.GenreSource = task.GenreSource
+
+	// 模型调用结束后任务可能已被取消或回收，此时不能覆盖取消状态或成为当前结果
+	if current, err := s.taskRepo.GetByID(task.ID); err == nil && current.Status != model.TaskStatusRunning {
+		if current.Status == model.TaskStatusCancelled {
+			return errTaskCancelled
+		}
+		return errLeaseLost
+	}
+
 	if err := s.analysisRepo.Update(analysis); err != nil {
 		s.log.Error("保存分析结果失败", err)
-		s.analysisRepo.UpdateStatus(articleID, "failed", "保存分析结果失败")
-		return
+		s.analysisRepo.UpdateStatus(task.AnalysisID, "failed", "保存分析结果失败")
+		return errors.New("保存分析结果失败")
+	}
+	if !task.Comparison {
+		if err := s.analysisRepo.SetCurrent(articleID, analysis.ID); err != nil {
+			s.log.Error("更新当前分析结果失败", err, zap.Uint64("analysis_id", analysis.ID))
+		}
 	}
-	
-	s.log.Info("文章分析完成", zap.Int("article_id", int(articleID)))
+
+	s.log.Info("文章分析完成", zap.Int("article_id", int(articleID)), zap.Uint64("task_id", task.ID))
+	return nil
 }
 
 func (s *AnalysisService) GetAnalysisResult(articleID uint64) (*model.ArticleAnalysis, error) {
@@ -124,46 +612,118 @@ func (s *AnalysisService) GetAnalysisResult(articleID uint64) (*model.ArticleAna
 	return analysis, nil
 }
 
+// taskStopped 任务上下文被外部取消时的处理：用户取消的任务状态已在取消时写入；
+// 失去租约时任务已由其他执行者接手，不能再改动分析记录；
+// 服务停止则不是分析本身失败，交由工作池重新排队
+func (s *AnalysisService) taskStopped(taskCtx context.Context, analysisID uint64) error {
+	switch cause := context.Cause(taskCtx); {
+	case errors.Is(cause, errTaskCancelled):
+		return errTaskCancelled
+	case errors.Is(cause, errLeaseLost):
+		return errLeaseLost
+	}
+	s.analysisRepo.UpdateStatus(analysisID, "pending", "")
+	return errTaskInterrupted
+}
+
+// CancelTask 取消排队中或执行中的任务
+//
+// 执行中的任务会取消传给模型调用的上下文，工作协程随即释放；
+// 若任务在其他实例上执行，则由该实例在下一次续约时发现并停止。
+func (s *AnalysisService) CancelTask(taskID string) error {
+	task, err := s.taskRepo.GetByTaskID(taskID)
+	if err != nil {
+		return ErrTaskNotFound
+	}
+
+	previous, err := s.taskRepo.Cancel(task.ID)
+	if err != nil {
+		s.log.Error("取消任务失败", err, zap.String("task_id", taskID))
+		return errors.New("取消任务失败")
+	}
+	if previous == "" {
+		return ErrTaskFinished
+	}
+
+	if err := s.analysisRepo.UpdateStatus(task.AnalysisID, "cancelled", "任务已取消"); err != nil {
+		s.log.Error("更新分析状态失败", err, zap.String("task_id", taskID))
+	}
+	if previous == model.TaskStatusRunning {
+		s.pool.Cancel(task.ID)
+	}
+	s.publishTaskEvent(task, TaskEvent{Type: TaskEventCancelled, Status: model.TaskStatusCancelled, Error: "任务已取消"})
+
+	s.log.Info("分析任务已取消", zap.String("task_id", taskID), zap.String("previous_status", previous))
+	return nil
+}
+
+// recordAttempt 将模型调用尝试记录到任务上
+func (s *AnalysisService) recordAttempt(task *model.AnalysisTask, attempt LLMAttempt) {
+	record := &model.AnalysisTaskAttempt{
+		TaskID:           task.ID,
+		Attempt:          attempt.Attempt,
+		Profile:          attempt.Profile,
+		Model:            attempt.Model,
+		StatusCode:       attempt.StatusCode,
+		Retryable:        attempt.Retryable,
+		DurationMs:       attempt.Duration.Milliseconds(),
+		WaitMs:           attempt.Wait.Milliseconds(),
+		PromptTokens:     attempt.PromptTokens,
+		CompletionTokens: attempt.CompletionTokens,
+		Cost:             s.callCost(attempt.Profile, attempt.PromptTokens, attempt.CompletionTokens),
+	}
+	if attempt.Err != nil {
+		record.ErrorMessage = attempt.Err.Error()
+	}
+	if err := s.taskRepo.AddAttempt(record); err != nil {
+		s.log.Warn("记录模型调用尝试失败", zap.String("task_id", task.TaskID), zap.Error(err))
+	}
+	if err := s.taskRepo.AddUsage(task.ID, record.PromptTokens, record.CompletionTokens, record.DurationMs, record.Cost); err != nil {
+		s.log.Warn("记录任务用量失败", zap.String("task_id", task.TaskID), zap.Error(err))
+	}
+}
+
+// setProgress 记录任务进度，失败只记日志不影响任务执行
+func (s *AnalysisService) setProgress(task *model.AnalysisTask, progress int) {
+	if err := s.taskRepo.UpdateProgress(task.ID, progress); err != nil {
+		s.log.Warn("更新任务进度失败", zap.String("task_id", task.TaskID), zap.Error(err))
+	}
+}
+
+// GetAnalysisStatus 查询任务状态，任务ID与一次分析执行一一对应
 func (s *AnalysisService) GetAnalysisStatus(taskID string) (map[string]interface{}, error) {
-	// 从任务ID中提取文章ID
-	var articleID uint64
-	fmt.Sscanf(taskID, "task_%d_", &articleID)
-	
-	if articleID == 0 {
-		return nil, errors.New("无效的任务ID")
-	}
-	
-	// 查询数据库获取实际状态
-	analysis, err := s.analysisRepo.GetByArticleID(articleID)
+	task, err := s.taskRepo.GetByTaskID(taskID)
 	if err != nil {
-		return map[string]interface{}{
-			"task_id":  taskID,
-			"status":   "pending",
-			"progress": 0,
-		}, nil
-	}
-	
-	progress := 0
-	switch analysis.AnalysisStatus {
-	case "completed":
-		progress = 100
-	case "processing":
-		progress = 50
-	case "failed":
-		progress = 0
-	default:
-		progress = 0
-	}
-	
+		return nil, ErrTaskNotFound
+	}
+
+	attempts, err := s.taskRepo.ListAttempts(task.ID)
+	if err != nil {
+		s.log.Warn("查询模型调用记录失败", zap.String("task_id", taskID), zap.Error(err))
+		attempts = []model.AnalysisTaskAttempt{}
+	}
+
 	return map[string]interface{}{
-		"task_id":  taskID,
-		"status":   analysis.AnalysisStatus,
-		"progress": progress,
-		"error":    analysis.ErrorMessage,
+		"task_id":     task.TaskID,
+		"article_id":  task.ArticleID,
+		"analysis_id": task.AnalysisID,
+		"status":      task.Status,
+		"progress":    task.Progress,
+		"profile":     task.Profile,
+		"cache_hit":   task.CacheHit,
+		"attempts":    task.Attempts,
+		"model":       task.Model,
+		"error":       task.ErrorMessage,
+		"created_at":  task.CreatedAt,
+		"started_at":  task.StartedAt,
+		"finished_at": task.FinishedAt,
+		"usage": map[string]interface{}{
+			"prompt_tokens":     task.PromptTokens,
+			"completion_tokens": task.CompletionTokens,
+			"latency_ms":        task.LatencyMs,
+			"cost":              task.Cost,
+			"currency":          s.currency,
+		},
+		"llm_calls": attempts,
 	}, nil
 }
-
-func timeoutContext() context.Context {
-	ctx, _ := context.WithTimeout(context.Background(), 120*time.Second)
-	return ctx
-}
\ No newline at end of file
@@ -0,0 +1,381 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"article-analysis/internal/config"
+	"article-analysis/internal/model"
+	"article-analysis/internal/repository"
+	"article-analysis/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func newTestAnalysisService(t *testing.T) (*AnalysisService, *repository.TaskRepository, *gorm.DB) {
+	return newTestAnalysisServiceWithAnalyzer(t, nil, &MockOpenAIClient{})
+}
+
+// newTestAnalysisServiceWithAnalyzer 创建使用内存数据库的服务；cfg 为 nil 时使用默认测试配置，
+// analyzer 为 nil 时按配置创建真实的模型客户端（配合 httptest 服务器使用）
+func newTestAnalysisServiceWithAnalyzer(t *testing.T, cfg *config.Config, analyzer Analyzer) (*AnalysisService, *repository.TaskRepository, *gorm.DB) {
+	if cfg == nil {
+		cfg = &config.Config{
+			OpenAI:   config.OpenAIConfig{APIKey: "test-api-key"},
+			Analysis: config.AnalysisConfig{MaxConcurrency: 1, PollInterval: 1, Timeout: 10},
+		}
+	}
+	if analyzer == nil {
+		return newTestAnalysisServiceWithAnalyzers(t, cfg, nil)
+	}
+	return newTestAnalysisServiceWithAnalyzers(t, cfg, map[string]Analyzer{cfg.OpenAI.DefaultProfileName(): analyzer})
+}
+
+// newTestAnalysisServiceWithAnalyzers 按模型配置名注入分析器，analyzers 为 nil 时按配置创建真实的模型客户端
+func newTestAnalysisServiceWithAnalyzers(t *testing.T, cfg *config.Config, analyzers map[string]Analyzer) (*AnalysisService, *repository.TaskRepository, *gorm.DB) {
+	db := newTestDB(t)
+	taskRepo := repository.NewTaskRepository(db)
+	var s *AnalysisService
+	if analyzers == nil {
+		s = NewAnalysisService(repository.NewAnalysisRepository(db), repository.NewArticleRepository(db), taskRepo, cfg, logger.NewLogger("error"))
+	} else {
+		s = NewAnalysisServiceWithAnalyzers(repository.NewAnalysisRepository(db), repository.NewArticleRepository(db), taskRepo, analyzers, cfg, logger.NewLogger("error"))
+	}
+	return s, taskRepo, db
+}
+
+// runUntilFinished 启动工作池并等待任务进入终态
+func runUntilFinished(t *testing.T, s *AnalysisService, taskRepo *repository.TaskRepository, taskID string) *model.AnalysisTask {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	defer func() {
+		cancel()
+		s.Wait()
+	}()
+
+	var task *model.AnalysisTask
+	require.Eventually(t, func() bool {
+		var err error
+		task, err = taskRepo.GetByTaskID(taskID)
+		return err == nil && task.Status != model.TaskStatusQueued && task.Status != model.TaskStatusRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	return task
+}
+
+func TestAnalysisService_PerformAnalysis_WithInjectedAnalyzer(t *testing.T) {
+	analyzer := &MockOpenAIClient{}
+	s, taskRepo, db := newTestAnalysisServiceWithAnalyzer(t, nil, analyzer)
+
+	article := &model.Article{Title: "文章", Author: "张三", Content: "测试文章内容", FilePath: "f.txt"}
+	require.NoError(t, repository.NewArticleRepository(db).Create(article))
+
+	analyzer.On("AnalyzeArticle", mock.Anything, "测试文章内容").Return(&AnalysisResponse{
+		CoreViewpoints:   "核心观点",
+		FileStructure:    "文件结构",
+		AuthorThoughts:   "作者思路",
+		RelatedMaterials: "相关素材",
+		Provider:         ProviderOllama,
+		Profile:          "local",
+		Model:            "qwen2",
+	}, nil).Once()
+
+	submitted, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{})
+	require.NoError(t, err)
+
+	task := runUntilFinished(t, s, taskRepo, submitted.TaskID)
+	assert.Equal(t, model.TaskStatusCompleted, task.Status)
+	assert.Equal(t, 100, task.Progress)
+
+	result, err := s.GetAnalysisResult(article.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "completed", result.AnalysisStatus)
+	assert.Equal(t, "核心观点", result.CoreViewpoints)
+	assert.Equal(t, "相关素材", result.RelatedMaterials)
+	assert.Nil(t, result.Result, "分析器只返回文本时没有结构化结果")
+	assert.Equal(t, ProviderOllama, result.Provider, "应记录实际完成分析的模型服务")
+	assert.Equal(t, "local", result.Profile)
+	assert.Equal(t, "qwen2", result.Model)
+	analyzer.AssertExpectations(t)
+}
+
+func TestAnalysisService_PerformAnalysis_AnalyzerError(t *testing.T) {
+	analyzer := &MockOpenAIClient{}
+	s, taskRepo, db := newTestAnalysisServiceWithAnalyzer(t, nil, analyzer)
+
+	article := &model.Article{Title: "文章", Author: "张三", Content: "内容", FilePath: "f.txt"}
+	require.NoError(t, repository.NewArticleRepository(db).Create(article))
+
+	analyzer.On("AnalyzeArticle", mock.Anything, "内容").Return(nil, errors.New("鉴权失败")).Once()
+
+	submitted, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{})
+	require.NoError(t, err)
+
+	task := runUntilFinished(t, s, taskRepo, submitted.TaskID)
+	assert.Equal(t, model.TaskStatusFailed, task.Status)
+	assert.Contains(t, task.ErrorMessage, "鉴权失败")
+
+	result, err := s.GetAnalysisResult(article.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "failed", result.AnalysisStatus)
+}
+
+func TestAnalysisService_GetAnalysisStatus_PerRun(t *testing.T) {
+	s, taskRepo, _ := newTestAnalysisService(t)
+
+	oldTask := &model.AnalysisTask{TaskID: newOpaqueID(), ArticleID: 1, Status: model.TaskStatusQueued}
+	require.NoError(t, taskRepo.Create(oldTask))
+	_, err := taskRepo.Finish(oldTask.ID, "", model.TaskStatusFailed, "AI分析失败: 超时")
+	require.NoError(t, err)
+
+	newTask := &model.AnalysisTask{TaskID: newOpaqueID(), ArticleID: 1, Status: model.TaskStatusQueued}
+	require.NoError(t, taskRepo.Create(newTask))
+	_, err = taskRepo.Finish(newTask.ID, "", model.TaskStatusCompleted, "")
+	require.NoError(t, err)
+
+	// 旧任务ID仍然报告它自己那次执行的结果
+	status, err := s.GetAnalysisStatus(oldTask.TaskID)
+	require.NoError(t, err)
+	assert.Equal(t, model.TaskStatusFailed, status["status"])
+	assert.Equal(t, "AI分析失败: 超时", status["error"])
+	assert.NotNil(t, status["finished_at"])
+
+	status, err = s.GetAnalysisStatus(newTask.TaskID)
+	require.NoError(t, err)
+	assert.Equal(t, model.TaskStatusCompleted, status["status"])
+	assert.Equal(t, 100, status["progress"])
+
+	_, err = s.GetAnalysisStatus("task_1_1700000000")
+	assert.Error(t, err)
+}
+
+func TestNewOpaqueID_Unique(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		id := newOpaqueID()
+		assert.Len(t, id, 32)
+		assert.False(t, seen[id])
+		seen[id] = true
+	}
+}
+
+func TestAnalysisService_RecoverOnStartup(t *testing.T) {
+	s, taskRepo, db := newTestAnalysisService(t)
+	analysisRepo := repository.NewAnalysisRepository(db)
+
+	expired := time.Now().Add(-time.Minute)
+	newRunning := func(articleID uint64, attempts int) (*model.AnalysisTask, *model.ArticleAnalysis) {
+		analysis := &model.ArticleAnalysis{ArticleID: articleID, AnalysisStatus: "processing"}
+		require.NoError(t, db.Table("article_analyses").Create(analysis).Error)
+		task := &model.AnalysisTask{
+			TaskID:     newOpaqueID(),
+			ArticleID:  articleID,
+			AnalysisID: analysis.ID,
+			Status:     model.TaskStatusRunning,
+			Attempts:   attempts,
+			WorkerID:   "old-instance/0",
+			LeaseUntil: &expired,
+		}
+		require.NoError(t, taskRepo.Create(task))
+		return task, analysis
+	}
+
+	retryTask, retryAnalysis := newRunning(1, 1)
+	exhaustedTask, exhaustedAnalysis := newRunning(2, 3)
+
+	// 升级前遗留、没有任何任务对应的处理中记录
+	orphan := &model.ArticleAnalysis{ArticleID: 3, AnalysisStatus: "processing"}
+	require.NoError(t, db.Table("article_analyses").Create(orphan).Error)
+
+	require.NoError(t, s.RecoverOnStartup())
+
+	got, err := taskRepo.GetByID(retryTask.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.TaskStatusQueued, got.Status)
+	assert.Empty(t, got.WorkerID)
+	analysis, err := analysisRepo.GetByID(retryAnalysis.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "pending", analysis.AnalysisStatus)
+
+	got, err = taskRepo.GetByID(exhaustedTask.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.TaskStatusFailed, got.Status)
+	assert.NotEmpty(t, got.ErrorMessage)
+	analysis, err = analysisRepo.GetByID(exhaustedAnalysis.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "failed", analysis.AnalysisStatus)
+
+	analysis, err = analysisRepo.GetByID(orphan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "failed", analysis.AnalysisStatus)
+}
+
+func TestAnalysisService_CancelTask(t *testing.T) {
+	s, taskRepo, db := newTestAnalysisService(t)
+	analysisRepo := repository.NewAnalysisRepository(db)
+
+	analysis := &model.ArticleAnalysis{ArticleID: 1, AnalysisStatus: "pending"}
+	require.NoError(t, db.Table("article_analyses").Create(analysis).Error)
+	task := &model.AnalysisTask{TaskID: newOpaqueID(), ArticleID: 1, AnalysisID: analysis.ID, Status: model.TaskStatusQueued}
+	require.NoError(t, taskRepo.Create(task))
+
+	require.NoError(t, s.CancelTask(task.TaskID))
+
+	got, err := taskRepo.GetByID(task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.TaskStatusCancelled, got.Status)
+	updated, err := analysisRepo.GetByID(analysis.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "cancelled", updated.AnalysisStatus)
+
+	// 已结束的任务不能再次取消
+	assert.ErrorIs(t, s.CancelTask(task.TaskID), ErrTaskFinished)
+	assert.ErrorIs(t, s.CancelTask("unknown"), ErrTaskNotFound)
+}
+
+func TestAnalysisService_TaskStopped(t *testing.T) {
+	s, _, db := newTestAnalysisService(t)
+	analysisRepo := repository.NewAnalysisRepository(db)
+
+	analysis := &model.ArticleAnalysis{ArticleID: 1, AnalysisStatus: "processing"}
+	require.NoError(t, db.Table("article_analyses").Create(analysis).Error)
+	stopped := func(cause error) error {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		cancel(cause)
+		return s.taskStopped(ctx, analysis.ID)
+	}
+
+	// 失去租约：任务已由其他执行者接手，分析记录保持原样
+	assert.ErrorIs(t, stopped(errLeaseLost), errLeaseLost)
+	got, err := analysisRepo.GetByID(analysis.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "processing", got.AnalysisStatus)
+
+	// 服务停止：分析记录回到排队状态，等待重新执行
+	assert.ErrorIs(t, stopped(context.Canceled), errTaskInterrupted)
+	got, err = analysisRepo.GetByID(analysis.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "pending", got.AnalysisStatus)
+}
+
+func TestAnalysisService_CancelledDuringAnalysisDoesNotBecomeCurrent(t *testing.T) {
+	analyzer := &MockOpenAIClient{}
+	s, taskRepo, db := newTestAnalysisServiceWithAnalyzer(t, nil, analyzer)
+
+	article := &model.Article{Title: "文章", Author: "张三", Content: "测试文章内容", FilePath: "f.txt"}
+	require.NoError(t, repository.NewArticleRepository(db).Create(article))
+
+	analyzer.On("AnalyzeArticle", mock.Anything, "测试文章内容").Return(&AnalysisResponse{CoreViewpoints: "第一次"}, nil).Once()
+	submitted, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{})
+	require.NoError(t, err)
+	runUntilFinished(t, s, taskRepo, submitted.TaskID)
+
+	// 在其他实例上取消：只改任务状态，本实例的模型调用照常返回
+	submitted, err = s.AnalyzeArticle(article.ID, AnalyzeOptions{})
+	require.NoError(t, err)
+	analyzer.On("AnalyzeArticle", mock.Anything, "测试文章内容").Run(func(mock.Arguments) {
+		task, err := taskRepo.GetByTaskID(submitted.TaskID)
+		require.NoError(t, err)
+		_, err = taskRepo.Cancel(task.ID)
+		require.NoError(t, err)
+	}).Return(&AnalysisResponse{CoreViewpoints: "第二次"}, nil).Once()
+
+	task := runUntilFinished(t, s, taskRepo, submitted.TaskID)
+	assert.Equal(t, model.TaskStatusCancelled, task.Status, "已取消的任务不会被改为完成")
+
+	current, err := s.GetAnalysisResult(article.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "第一次", current.CoreViewpoints, "已取消任务的结果不成为当前结果")
+	analyzer.AssertExpectations(t)
+}
+
+func TestAnalysisService_AnalyzeArticle_ConcurrentSubmits(t *testing.T) {
+	s, taskRepo, db := newTestAnalysisService(t)
+
+	article := &model.Article{Title: "文章", Author: "张三", Content: "测试文章内容", FilePath: "f.txt"}
+	require.NoError(t, repository.NewArticleRepository(db).Create(article))
+
+	const submits = 5
+	errs := make(chan error, submits)
+	var wg sync.WaitGroup
+	for i := 0; i < submits; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, ErrTaskInProgress)
+	}
+	assert.Equal(t, 1, succeeded, "同一文章同时只能有一个进行中的任务")
+
+	// 跳过预检直接写入：唯一索引拒绝第二个任务，分析记录随之回滚
+	articleID := article.ID
+	err := taskRepo.CreateWithAnalysis(&model.ArticleAnalysis{ArticleID: article.ID, AnalysisStatus: "pending"},
+		&model.AnalysisTask{TaskID: newOpaqueID(), ArticleID: article.ID, Status: model.TaskStatusQueued, ActiveArticleID: &articleID})
+	assert.Error(t, err)
+	var count int64
+	require.NoError(t, db.Model(&model.ArticleAnalysis{}).Where("article_id = ?", article.ID).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	// 分析记录的版本号在文章内唯一
+	assert.Error(t, db.Create(&model.ArticleAnalysis{ArticleID: article.ID, Version: 1, AnalysisStatus: "pending"}).Error)
+}
+
+func TestAnalysisService_AnalyzeArticle_Profile(t *testing.T) {
+	db := newTestDB(t)
+	taskRepo := repository.NewTaskRepository(db)
+	cfg := &config.Config{
+		OpenAI: config.OpenAIConfig{
+			APIKey:         "test-api-key",
+			DefaultProfile: "fast",
+			Profiles: []config.ModelProfile{
+				{Name: "fast", Model: "small-model"},
+				{Name: "deep", Model: "large-model", Timeout: 30},
+			},
+		},
+		Analysis: config.AnalysisConfig{MaxConcurrency: 1, PollInterval: 1, Timeout: 10},
+	}
+	fast, deep := &MockOpenAIClient{}, &MockOpenAIClient{}
+	s := NewAnalysisServiceWithAnalyzers(repository.NewAnalysisRepository(db), repository.NewArticleRepository(db), taskRepo,
+		map[string]Analyzer{"fast": fast, "deep": deep}, cfg, logger.NewLogger("error"))
+
+	article := &model.Article{Title: "文章", Author: "张三", Content: "内容", FilePath: "f.txt"}
+	require.NoError(t, repository.NewArticleRepository(db).Create(article))
+
+	_, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{Profile: "unknown"})
+	assert.ErrorIs(t, err, ErrUnknownProfile)
+
+	deep.On("AnalyzeArticle", mock.Anything, "内容").Return(&AnalysisResponse{CoreViewpoints: "深度观点"}, nil).Once()
+
+	submitted, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{Profile: "deep"})
+	require.NoError(t, err)
+
+	task := runUntilFinished(t, s, taskRepo, submitted.TaskID)
+	assert.Equal(t, model.TaskStatusCompleted, task.Status)
+	assert.Equal(t, "deep", task.Profile)
+	deep.AssertExpectations(t)
+	fast.AssertNotCalled(t, "AnalyzeArticle", mock.Anything, mock.Anything)
+
+	profiles := s.ListProfiles()
+	require.Len(t, profiles, 2)
+	assert.True(t, profiles[0].Default)
+	assert.Equal(t, "large-model", profiles[1].Model)
+	assert.Equal(t, 30*time.Second, s.profileTimeout("deep"))
+	assert.Equal(t, 10*time.Second, s.profileTimeout("fast"))
+}
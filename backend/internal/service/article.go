@@ -17,6 +17,7 @@ import (
 
 type ArticleService struct {
 	repo *repository.ArticleRepository
+	bus  *EventBus
 	log  *logger.Logger
 }
 
@@ -27,6 +28,18 @@ func NewArticleService(repo *repository.ArticleRepository, log *logger.Logger) *
 	}
 }
 
+// SetEventBus 设置发布文章创建、删除事件的事件总线，未设置时不发布
+func (s *ArticleService) SetEventBus(bus *EventBus) {
+	s.bus = bus
+}
+
+// publishArticle 发布文章事件，事件中不含正文
+func (s *ArticleService) publishArticle(eventType string, article *model.Article) {
+	summary := *article
+	summary.Content = ""
+	s.bus.Publish(DomainEvent{Type: eventType, ArticleID: article.ID, Author: article.Author, Data: &summary})
+}
+
 func (s *ArticleService) UploadArticle(file *multipart.FileHeader, title, author string) (*model.Article, error) {
 	// 验证文件类型
 	if !strings.HasSuffix(strings.ToLower(file.Filename), ".txt") {
@@ -119,6 +132,7 @@ func (s *ArticleService) UploadArticle(file *multipart.FileHeader, title, author
 		zap.String("title", title),
 		zap.String("author", author),
 		zap.Int("size", int(file.Size)))
+	s.publishArticle(EventArticleCreated, article)
 
 	return article, nil
 }
@@ -212,6 +226,7 @@ func (s *ArticleService) CreateArticle(title, author, content string) (*model.Ar
 		zap.String("title", title),
 		zap.String("author", author),
 		zap.Int("size", len(content)))
+	s.publishArticle(EventArticleCreated, article)
 
 	return article, nil
 }
@@ -238,6 +253,7 @@ func (s *ArticleService) DeleteArticle(id uint64) error {
 	}
 
 	s.log.Info("文章删除成功", zap.Uint64("id", id), zap.String("title", article.Title))
+	s.publishArticle(EventArticleDeleted, article)
 	return nil
 }
 
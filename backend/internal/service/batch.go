@@ -0,0 +1,185 @@
+package service
+
+import (
+	"article-analysis/internal/model"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+var (
+	ErrNoArticlesMatched = errors.New("没有符合条件的文章")
+	ErrBatchTooLarge     = errors.New("单次提交的文章过多")
+)
+
+// BatchRequest 批量分析请求：指定文章ID列表，或按筛选条件（与文章列表相同的字段）选取文章
+type BatchRequest struct {
+	Options        AnalyzeOptions
+	ArticleIDs     []uint64
+	UseFilter      bool
+	Keyword        string
+	Author         string
+	OnlyUnanalyzed bool
+}
+
+// BatchSkipped 批量提交时未入队的文章及原因
+type BatchSkipped struct {
+	ArticleID uint64 `json:"article_id,string"`
+	Reason    string `json:"reason"`
+}
+
+// BatchSubmission 批量提交结果
+type BatchSubmission struct {
+	BatchID   string         `json:"batch_id"`
+	Requested int            `json:"requested"`
+	Queued    int            `json:"queued"`
+	TaskIDs   []string       `json:"task_ids"`
+	Skipped   []BatchSkipped `json:"skipped"`
+}
+
+// AnalyzeBatch 批量提交分析任务，已有进行中任务或不存在的文章会被跳过
+func (s *AnalysisService) AnalyzeBatch(req *BatchRequest) (*BatchSubmission, error) {
+	if _, _, err := s.resolveProfile(req.Options.Profile); err != nil {
+		return nil, err
+	}
+	if err := validateGenre(req.Options.Genre); err != nil {
+		return nil, err
+	}
+	prompt, err := s.prompts.Get(req.Options.Prompt)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := s.resolveSchema(req.Options.Schema)
+	if err != nil {
+		return nil, err
+	}
+	if err := prompt.supports(schema); err != nil {
+		return nil, err
+	}
+	// 额度已经用尽时整批拒绝，未用尽时逐篇检查，超出的文章计入跳过列表
+	if err := s.checkBudget(req.Options.Requester, nil); err != nil {
+		return nil, err
+	}
+
+	articleIDs := req.ArticleIDs
+	if req.UseFilter {
+		ids, err := s.articleRepo.FindIDs(req.Keyword, req.Author, req.OnlyUnanalyzed, s.maxBatchSize+1)
+		if err != nil {
+			s.log.Error("按条件查询文章失败", err)
+			return nil, errors.New("查询文章失败")
+		}
+		articleIDs = ids
+	}
+	if len(articleIDs) == 0 {
+		return nil, ErrNoArticlesMatched
+	}
+	if len(articleIDs) > s.maxBatchSize {
+		return nil, fmt.Errorf("%w，最多%d篇", ErrBatchTooLarge, s.maxBatchSize)
+	}
+
+	batch := &model.AnalysisBatch{
+		BatchID:   newOpaqueID(),
+		Requested: len(articleIDs),
+	}
+	if err := s.taskRepo.CreateBatch(batch); err != nil {
+		s.log.Error("创建批次失败", err)
+		return nil, errors.New("创建批次失败")
+	}
+
+	result := &BatchSubmission{
+		BatchID:   batch.BatchID,
+		Requested: len(articleIDs),
+		TaskIDs:   []string{},
+		Skipped:   []BatchSkipped{},
+	}
+	seen := make(map[uint64]bool, len(articleIDs))
+	for _, articleID := range articleIDs {
+		if seen[articleID] {
+			continue
+		}
+		seen[articleID] = true
+
+		if _, err := s.articleRepo.GetByID(articleID); err != nil {
+			result.Skipped = append(result.Skipped, BatchSkipped{ArticleID: articleID, Reason: "文章不存在"})
+			continue
+		}
+		task, err := s.enqueue(articleID, batch.BatchID, req.Options)
+		if err != nil {
+			result.Skipped = append(result.Skipped, BatchSkipped{ArticleID: articleID, Reason: err.Error()})
+			continue
+		}
+		result.TaskIDs = append(result.TaskIDs, task.TaskID)
+	}
+	result.Queued = len(result.TaskIDs)
+
+	batch.Queued = result.Queued
+	if err := s.taskRepo.UpdateBatch(batch); err != nil {
+		s.log.Warn("更新批次信息失败", zap.String("batch_id", batch.BatchID), zap.Error(err))
+	}
+	s.pool.Notify()
+
+	s.log.Info("批量分析已提交",
+		zap.String("batch_id", batch.BatchID),
+		zap.Int("requested", result.Requested),
+		zap.Int("queued", result.Queued))
+	return result, nil
+}
+
+// GetBatchStatus 汇总批次内各任务的状态和整体进度
+func (s *AnalysisService) GetBatchStatus(batchID string) (map[string]interface{}, error) {
+	batch, err := s.taskRepo.GetBatch(batchID)
+	if err != nil {
+		return nil, errors.New("批次不存在")
+	}
+
+	tasks, err := s.taskRepo.ListByBatch(batchID)
+	if err != nil {
+		s.log.Error("查询批次任务失败", err)
+		return nil, errors.New("查询批次任务失败")
+	}
+
+	counts := map[string]int{
+		model.TaskStatusQueued:    0,
+		model.TaskStatusRunning:   0,
+		model.TaskStatusCompleted: 0,
+		model.TaskStatusFailed:    0,
+		model.TaskStatusCancelled: 0,
+	}
+	items := make([]map[string]interface{}, 0, len(tasks))
+	totalProgress := 0
+	for _, task := range tasks {
+		counts[task.Status]++
+		switch task.Status {
+		case model.TaskStatusQueued, model.TaskStatusRunning:
+			totalProgress += task.Progress
+		default:
+			// 已结束的任务无论成败都计为完成
+			totalProgress += 100
+		}
+		items = append(items, map[string]interface{}{
+			"task_id":    task.TaskID,
+			"article_id": task.ArticleID,
+			"status":     task.Status,
+			"progress":   task.Progress,
+			"error":      task.ErrorMessage,
+		})
+	}
+
+	progress := 100
+	if len(tasks) > 0 {
+		progress = totalProgress / len(tasks)
+	}
+	finished := counts[model.TaskStatusQueued] == 0 && counts[model.TaskStatusRunning] == 0
+
+	return map[string]interface{}{
+		"batch_id":   batch.BatchID,
+		"requested":  batch.Requested,
+		"queued":     batch.Queued,
+		"counts":     counts,
+		"progress":   progress,
+		"finished":   finished,
+		"created_at": batch.CreatedAt,
+		"tasks":      items,
+	}, nil
+}
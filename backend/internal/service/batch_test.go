@@ -0,0 +1,73 @@
+package service
+
+import (
+	"testing"
+
+	"article-analysis/internal/model"
+	"article-analysis/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalysisService_AnalyzeBatch_Filter(t *testing.T) {
+	s, taskRepo, db := newTestAnalysisService(t)
+	articleRepo := repository.NewArticleRepository(db)
+
+	newArticle := func(title, author string) *model.Article {
+		article := &model.Article{Title: title, Author: author, Content: "内容", FilePath: "f.txt"}
+		require.NoError(t, articleRepo.Create(article))
+		return article
+	}
+	analyzed := newArticle("已分析", "张三")
+	fresh := newArticle("未分析", "张三")
+	busy := newArticle("分析中", "张三")
+	newArticle("其他作者", "李四")
+
+	require.NoError(t, db.Table("article_analyses").Create(&model.ArticleAnalysis{ArticleID: analyzed.ID, AnalysisStatus: "completed"}).Error)
+	_, err := s.enqueue(busy.ID, "", AnalyzeOptions{})
+	require.NoError(t, err)
+
+	result, err := s.AnalyzeBatch(&BatchRequest{UseFilter: true, Author: "张三", OnlyUnanalyzed: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, result.Requested)
+	assert.Equal(t, 1, result.Queued)
+	require.Len(t, result.Skipped, 1)
+	assert.Equal(t, busy.ID, result.Skipped[0].ArticleID)
+	assert.Equal(t, ErrTaskInProgress.Error(), result.Skipped[0].Reason)
+
+	task, err := taskRepo.GetByTaskID(result.TaskIDs[0])
+	require.NoError(t, err)
+	assert.Equal(t, fresh.ID, task.ArticleID)
+	assert.Equal(t, result.BatchID, task.BatchID)
+
+	status, err := s.GetBatchStatus(result.BatchID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, status["counts"].(map[string]int)[model.TaskStatusQueued])
+	assert.Equal(t, false, status["finished"])
+
+	_, err = taskRepo.Finish(task.ID, "", model.TaskStatusCompleted, "")
+	require.NoError(t, err)
+	status, err = s.GetBatchStatus(result.BatchID)
+	require.NoError(t, err)
+	assert.Equal(t, 100, status["progress"])
+	assert.Equal(t, true, status["finished"])
+}
+
+func TestAnalysisService_AnalyzeBatch_IDs(t *testing.T) {
+	s, _, db := newTestAnalysisService(t)
+	articleRepo := repository.NewArticleRepository(db)
+
+	article := &model.Article{Title: "文章", Author: "张三", Content: "内容", FilePath: "f.txt"}
+	require.NoError(t, articleRepo.Create(article))
+
+	result, err := s.AnalyzeBatch(&BatchRequest{ArticleIDs: []uint64{article.ID, article.ID, 9999}})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Queued)
+	require.Len(t, result.Skipped, 1)
+	assert.Equal(t, uint64(9999), result.Skipped[0].ArticleID)
+
+	_, err = s.AnalyzeBatch(&BatchRequest{UseFilter: true, Author: "不存在"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"article-analysis/internal/config"
+	"article-analysis/internal/model"
+
+	"go.uber.org/zap"
+)
+
+// ErrBudgetExhausted 模型用量预算已用尽
+var ErrBudgetExhausted = errors.New("模型用量预算已用尽")
+
+// BudgetScopeGlobal 全局额度的范围标识
+const BudgetScopeGlobal = "global"
+
+// 预算统计周期与额度类型
+const (
+	budgetDaily   = "daily"
+	budgetMonthly = "monthly"
+	budgetTokens  = "tokens"
+	budgetCost    = "cost"
+)
+
+// BudgetError 提交会超出某项额度时返回的错误
+type BudgetError struct {
+	Scope     string  `json:"scope"`
+	Period    string  `json:"period"`
+	Metric    string  `json:"metric"`
+	Limit     float64 `json:"limit"`
+	Used      float64 `json:"used"`
+	Requested float64 `json:"requested"` // 本次提交的预估用量
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("%s：%s %s 额度 %.4g，已用 %.4g，本次预计 %.4g",
+		ErrBudgetExhausted.Error(), e.Scope, budgetLabel(e.Period, e.Metric), e.Limit, e.Used, e.Requested)
+}
+
+func (e *BudgetError) Unwrap() error {
+	return ErrBudgetExhausted
+}
+
+func budgetLabel(period, metric string) string {
+	label := "每日"
+	if period == budgetMonthly {
+		label = "每月"
+	}
+	if metric == budgetCost {
+		return label + "费用"
+	}
+	return label + "token"
+}
+
+// RequesterForAPIKey 由 API Key 得到调用方标识，只保留摘要，不保存原始密钥
+func RequesterForAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "key:" + hex.EncodeToString(sum[:8])
+}
+
+// RequesterAnonymous 未携带有效 API Key 的调用方，共用一份 per_requester 额度
+const RequesterAnonymous = "anonymous"
+
+// budgetScope 一组需要检查的额度
+type budgetScope struct {
+	scope     string
+	requester string // 为空表示统计全部调用方
+	limits    config.BudgetLimits
+}
+
+// requesterOrAnonymous 未识别出调用方时视为匿名调用方
+func requesterOrAnonymous(requester string) string {
+	if requester == "" {
+		return RequesterAnonymous
+	}
+	return requester
+}
+
+// budgetScopes 返回提交任务时需要检查的额度：全局额度和调用方自身的额度
+func (s *AnalysisService) budgetScopes(requester string) []budgetScope {
+	requester = requesterOrAnonymous(requester)
+	return []budgetScope{
+		{scope: BudgetScopeGlobal, limits: s.budget.Global},
+		{scope: requester, requester: requester, limits: s.requesterLimits(requester)},
+	}
+}
+
+func (s *AnalysisService) requesterLimits(requester string) config.BudgetLimits {
+	for _, rb := range s.budget.Requesters {
+		if rb.APIKey != "" && RequesterForAPIKey(rb.APIKey) == requester {
+			return rb.BudgetLimits
+		}
+	}
+	return s.budget.PerRequester
+}
+
+// budgetPeriod 统计周期的起点与标识
+func budgetPeriod(period string, now time.Time) (time.Time, string) {
+	if period == budgetMonthly {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()), now.Format("2006-01")
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()), now.Format("2006-01-02")
+}
+
+// checkBudget 检查加上本次预估用量后是否超出额度，超出时拒绝；达到预警比例时记录预警
+func (s *AnalysisService) checkBudget(requester string, estimate *ModelEstimate) error {
+	usages, err := s.budgetUsages(requester)
+	if err != nil {
+		return err
+	}
+
+	for _, usage := range usages {
+		var requested float64
+		if estimate != nil {
+			requested = float64(estimate.InputTokens + estimate.OutputTokens)
+			if usage.Metric == budgetCost {
+				requested = estimate.Cost
+			}
+		}
+
+		alert := &model.BudgetAlert{
+			Scope:     usage.Scope,
+			Period:    usage.Period,
+			PeriodKey: usage.PeriodKey,
+			Metric:    usage.Metric,
+			Used:      usage.Used + requested,
+			Limit:     usage.Limit,
+		}
+		if usage.Used+requested > usage.Limit {
+			alert.Level = model.BudgetAlertExhausted
+			s.recordBudgetAlert(alert)
+			return &BudgetError{
+				Scope:     usage.Scope,
+				Period:    usage.Period,
+				Metric:    usage.Metric,
+				Limit:     usage.Limit,
+				Used:      usage.Used,
+				Requested: requested,
+			}
+		}
+		if s.budget.WarningThreshold > 0 && usage.Used+requested >= usage.Limit*s.budget.WarningThreshold {
+			alert.Level = model.BudgetAlertWarning
+			s.recordBudgetAlert(alert)
+		}
+	}
+	return nil
+}
+
+// recordBudgetAlert 记录预算预警，同一周期内重复触发只记录一次
+func (s *AnalysisService) recordBudgetAlert(alert *model.BudgetAlert) {
+	created, err := s.taskRepo.CreateBudgetAlert(alert)
+	if err != nil {
+		s.log.Warn("记录预算预警失败", zap.Error(err))
+		return
+	}
+	if created {
+		s.log.Warn("模型用量预算预警",
+			zap.String("scope", alert.Scope),
+			zap.String("period", alert.PeriodKey),
+			zap.String("metric", alert.Metric),
+			zap.String("level", alert.Level),
+			zap.Float64("used", alert.Used),
+			zap.Float64("limit", alert.Limit))
+	}
+}
+
+// BudgetUsage 一项额度的使用情况
+type BudgetUsage struct {
+	Scope     string  `json:"scope"`
+	Period    string  `json:"period"`
+	PeriodKey string  `json:"period_key"`
+	Metric    string  `json:"metric"`
+	Limit     float64 `json:"limit"`
+	Used      float64 `json:"used"` // 含排队中、执行中任务的预估用量
+	Ratio     float64 `json:"ratio"`
+}
+
+// BudgetStatus 预算使用情况
+type BudgetStatus struct {
+	Requester        string              `json:"requester,omitempty"`
+	WarningThreshold float64             `json:"warning_threshold"`
+	Currency         string              `json:"currency"`
+	Budgets          []BudgetUsage       `json:"budgets"`
+	Alerts           []model.BudgetAlert `json:"alerts"`
+}
+
+// budgetUsages 统计调用方需要遵守的各项已配置额度的当前用量
+func (s *AnalysisService) budgetUsages(requester string) ([]BudgetUsage, error) {
+	now := time.Now()
+	usages := []BudgetUsage{}
+	for _, scope := range s.budgetScopes(requester) {
+		for _, period := range []string{budgetDaily, budgetMonthly} {
+			tokenLimit, costLimit := float64(scope.limits.DailyTokens), scope.limits.DailyCost
+			if period == budgetMonthly {
+				tokenLimit, costLimit = float64(scope.limits.MonthlyTokens), scope.limits.MonthlyCost
+			}
+			if tokenLimit <= 0 && costLimit <= 0 {
+				continue
+			}
+
+			since, periodKey := budgetPeriod(period, now)
+			used, err := s.taskRepo.SumUsage(since, scope.requester)
+			if err != nil {
+				s.log.Error("统计预算用量失败", err)
+				return nil, errors.New("统计预算用量失败")
+			}
+			if tokenLimit > 0 {
+				usages = append(usages, BudgetUsage{
+					Scope: scope.scope, Period: period, PeriodKey: periodKey, Metric: budgetTokens,
+					Limit: tokenLimit, Used: float64(used.Tokens), Ratio: float64(used.Tokens) / tokenLimit,
+				})
+			}
+			if costLimit > 0 {
+				usages = append(usages, BudgetUsage{
+					Scope: scope.scope, Period: period, PeriodKey: periodKey, Metric: budgetCost,
+					Limit: costLimit, Used: used.Cost, Ratio: used.Cost / costLimit,
+				})
+			}
+		}
+	}
+	return usages, nil
+}
+
+// GetBudgetStatus 查询全局及调用方自身已配置额度的使用情况和最近的预警
+func (s *AnalysisService) GetBudgetStatus(requester string) (*BudgetStatus, error) {
+	usages, err := s.budgetUsages(requester)
+	if err != nil {
+		return nil, err
+	}
+
+	requester = requesterOrAnonymous(requester)
+	scopes := []string{BudgetScopeGlobal, requester}
+	alerts, err := s.taskRepo.ListBudgetAlerts(scopes, 20)
+	if err != nil {
+		s.log.Error("查询预算预警失败", err)
+		return nil, errors.New("查询预算预警失败")
+	}
+
+	return &BudgetStatus{
+		Requester:        requester,
+		WarningThreshold: s.budget.WarningThreshold,
+		Currency:         s.currency,
+		Budgets:          usages,
+		Alerts:           alerts,
+	}, nil
+}
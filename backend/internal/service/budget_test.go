@@ -0,0 +1,118 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"article-analysis/internal/config"
+	"article-analysis/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalysisService_RequesterBudget(t *testing.T) {
+	s, articleRepo := newEstimateTestService(t, config.AnalysisConfig{})
+
+	var ids []uint64
+	for i := 0; i < 4; i++ {
+		article := &model.Article{Title: "文章", Author: "张三", Content: "测试内容", FilePath: "f.txt"}
+		require.NoError(t, articleRepo.Create(article))
+		ids = append(ids, article.ID)
+	}
+	estimate, err := s.EstimateAnalysis(ids[0], AnalyzeOptions{})
+	require.NoError(t, err)
+	perRun := int64(estimate.Estimates[0].InputTokens + estimate.Estimates[0].OutputTokens)
+
+	// 每个调用方每日只够提交一次，排队中的任务按预估用量占用额度
+	s.budget = config.BudgetConfig{
+		WarningThreshold: 0.5,
+		PerRequester:     config.BudgetLimits{DailyTokens: perRun*2 - 1},
+	}
+	alice, bob := RequesterForAPIKey("alice-key"), RequesterForAPIKey("secret")
+
+	_, err = s.AnalyzeArticle(ids[0], AnalyzeOptions{Requester: alice})
+	require.NoError(t, err)
+
+	_, err = s.AnalyzeArticle(ids[1], AnalyzeOptions{Requester: alice})
+	require.ErrorIs(t, err, ErrBudgetExhausted)
+	var budgetErr *BudgetError
+	require.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, alice, budgetErr.Scope)
+	assert.Equal(t, "daily", budgetErr.Period)
+	assert.Equal(t, "tokens", budgetErr.Metric)
+	assert.Equal(t, float64(perRun), budgetErr.Used)
+
+	batch, err := s.AnalyzeBatch(&BatchRequest{Options: AnalyzeOptions{Requester: alice}, ArticleIDs: ids[1:3]})
+	require.NoError(t, err)
+	assert.Equal(t, 0, batch.Queued)
+	require.Len(t, batch.Skipped, 2)
+	assert.Contains(t, batch.Skipped[0].Reason, ErrBudgetExhausted.Error())
+
+	_, err = s.AnalyzeArticle(ids[1], AnalyzeOptions{Requester: bob})
+	require.NoError(t, err, "其他调用方的额度不受影响")
+
+	_, err = s.AnalyzeArticle(ids[2], AnalyzeOptions{})
+	require.NoError(t, err)
+	_, err = s.AnalyzeArticle(ids[3], AnalyzeOptions{})
+	require.True(t, errors.As(err, &budgetErr), "匿名调用方共用一份额度")
+	assert.Equal(t, RequesterAnonymous, budgetErr.Scope)
+
+	status, err := s.GetBudgetStatus(alice)
+	require.NoError(t, err)
+	require.Len(t, status.Budgets, 1)
+	assert.InDelta(t, float64(perRun)/float64(perRun*2-1), status.Budgets[0].Ratio, 1e-9)
+
+	levels := map[string]int{}
+	for _, alert := range status.Alerts {
+		assert.Equal(t, alice, alert.Scope)
+		levels[alert.Level]++
+	}
+	assert.Equal(t, map[string]int{model.BudgetAlertWarning: 1, model.BudgetAlertExhausted: 1}, levels,
+		"同一周期内重复触发只记录一次")
+}
+
+func TestAnalysisService_GlobalBudget(t *testing.T) {
+	s, articleRepo := newEstimateTestService(t, config.AnalysisConfig{})
+
+	article := &model.Article{Title: "文章", Author: "张三", Content: "测试内容", FilePath: "f.txt"}
+	require.NoError(t, articleRepo.Create(article))
+
+	s.budget = config.BudgetConfig{
+		Global:     config.BudgetLimits{MonthlyCost: 0.0001},
+		Requesters: []config.RequesterBudget{{User: "vip", APIKey: "vip-key", BudgetLimits: config.BudgetLimits{DailyTokens: 1 << 30}}},
+	}
+
+	_, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{Requester: RequesterForAPIKey("vip-key")})
+	var budgetErr *BudgetError
+	require.True(t, errors.As(err, &budgetErr), "全局额度对所有调用方生效")
+	assert.Equal(t, BudgetScopeGlobal, budgetErr.Scope)
+	assert.Equal(t, "monthly", budgetErr.Period)
+	assert.Equal(t, "cost", budgetErr.Metric)
+
+	s.budget.Global = config.BudgetLimits{}
+	_, err = s.AnalyzeArticle(article.ID, AnalyzeOptions{Requester: RequesterForAPIKey("vip-key")})
+	require.NoError(t, err)
+}
+
+func TestAnalysisService_BudgetCountsUnspentReservation(t *testing.T) {
+	s, taskRepo, _ := newTestAnalysisService(t)
+	since := time.Now().Add(-time.Hour)
+
+	task := &model.AnalysisTask{TaskID: "running", ArticleID: 1, Status: model.TaskStatusRunning, Requester: "key:a", EstimatedTokens: 100}
+	require.NoError(t, taskRepo.Create(task))
+	totals, err := taskRepo.SumUsage(since, "key:a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), totals.Tokens)
+
+	s.recordAttempt(task, LLMAttempt{Attempt: 1, PromptTokens: 20, CompletionTokens: 10})
+	totals, err = taskRepo.SumUsage(since, "key:a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), totals.Tokens, "已发生的用量从预估中扣除，不重复计入")
+
+	s.recordAttempt(task, LLMAttempt{Attempt: 2, PromptTokens: 60, CompletionTokens: 30})
+	totals, err = taskRepo.SumUsage(since, "key:a")
+	require.NoError(t, err)
+	assert.Equal(t, int64(120), totals.Tokens, "超出预估后只按实际用量计入")
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"errors"
+
+	"article-analysis/internal/model"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// cacheableAnalyzer 分析结果可以缓存的分析器
+type cacheableAnalyzer interface {
+	cacheKey(content string, prompt *PromptTemplate, schema *model.AnalysisSchema) string
+}
+
+// lookupCache 查询文章内容对应的缓存结果，分析器不支持缓存或未命中时返回 nil
+func (s *AnalysisService) lookupCache(analyzer Analyzer, prompt *PromptTemplate, schema *model.AnalysisSchema, content string) *model.AnalysisCache {
+	cacheable, ok := analyzer.(cacheableAnalyzer)
+	if !ok {
+		return nil
+	}
+	entry, err := s.analysisRepo.GetCache(cacheable.cacheKey(content, prompt, schema))
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.log.Warn("查询分析结果缓存失败", zap.Error(err))
+		}
+		return nil
+	}
+	return entry
+}
+
+// cachedResult 执行任务时使用缓存结果，命中时记录命中并标记任务
+func (s *AnalysisService) cachedResult(task *model.AnalysisTask, analyzer Analyzer, prompt *PromptTemplate, schema *model.AnalysisSchema, content string) *AnalysisResponse {
+	if task.ForceRefresh {
+		return nil
+	}
+	entry := s.lookupCache(analyzer, prompt, schema, content)
+	if entry == nil {
+		return nil
+	}
+
+	if err := s.analysisRepo.RecordCacheHit(entry.ID); err != nil {
+		s.log.Warn("记录缓存命中失败", zap.Error(err))
+	}
+	if err := s.taskRepo.MarkCacheHit(task.ID); err != nil {
+		s.log.Warn("标记任务缓存命中失败", zap.Error(err))
+	}
+	s.log.Info("命中分析结果缓存", zap.Uint64("article_id", task.ArticleID), zap.String("task_id", task.TaskID))
+
+	response := &AnalysisResponse{
+		CoreViewpoints:   entry.CoreViewpoints,
+		FileStructure:    entry.FileStructure,
+		AuthorThoughts:   entry.AuthorThoughts,
+		RelatedMaterials: entry.RelatedMaterials,
+	}
+	if entry.Result != nil {
+		response = newAnalysisResponse(*entry.Result)
+	}
+	response.Fields = entry.Fields
+	response.Provider = entry.Provider
+	response.Profile = entry.Profile
+	response.Model = entry.Model
+	return response
+}
+
+// saveCache 缓存模型分析结果；发生降级时结果来自其他模型，不写入请求模型的缓存
+func (s *AnalysisService) saveCache(analyzer Analyzer, profile string, prompt *PromptTemplate, schema *model.AnalysisSchema, content string, result *AnalysisResponse) {
+	cacheable, ok := analyzer.(cacheableAnalyzer)
+	if !ok || (result.Profile != "" && result.Profile != profile) {
+		return
+	}
+
+	entry := &model.AnalysisCache{
+		CacheKey:         cacheable.cacheKey(content, prompt, schema),
+		PromptVersion:    prompt.Version,
+		Provider:         result.Provider,
+		Profile:          profile,
+		Model:            result.Model,
+		CoreViewpoints:   result.CoreViewpoints,
+		FileStructure:    result.FileStructure,
+		AuthorThoughts:   result.AuthorThoughts,
+		RelatedMaterials: result.RelatedMaterials,
+		Result:           structuredResult(result),
+		Fields:           result.Fields,
+	}
+	if err := s.analysisRepo.SaveCache(entry); err != nil {
+		s.log.Warn("写入分析结果缓存失败", zap.Error(err))
+	}
+}
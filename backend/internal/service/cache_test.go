@@ -0,0 +1,100 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"article-analysis/internal/config"
+	"article-analysis/internal/model"
+	"article-analysis/internal/repository"
+	"article-analysis/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalysisService_CachesDuplicateContent(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		writeChatCompletion(w, testAnalysisJSON)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		OpenAI:   config.OpenAIConfig{APIKey: "test-api-key", APIBase: server.URL, Model: "test-model"},
+		Analysis: config.AnalysisConfig{MaxConcurrency: 1, PollInterval: 1, Timeout: 10},
+	}
+	s, taskRepo, db := newTestAnalysisServiceWithAnalyzer(t, cfg, nil)
+	articleRepo := repository.NewArticleRepository(db)
+
+	analyze := func(title string, opts AnalyzeOptions) *model.AnalysisTask {
+		article := &model.Article{Title: title, Author: "张三", Content: "同一篇作文", FilePath: "f.txt"}
+		require.NoError(t, articleRepo.Create(article))
+		submitted, err := s.AnalyzeArticle(article.ID, opts)
+		require.NoError(t, err)
+		task := runUntilFinished(t, s, taskRepo, submitted.TaskID)
+		require.Equal(t, model.TaskStatusCompleted, task.Status)
+
+		analysis, err := s.GetAnalysisResult(article.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "观点", analysis.CoreViewpoints)
+		require.NotNil(t, analysis.Result, "缓存命中时同样保存结构化结果")
+		require.Len(t, analysis.Result.RelatedMaterials, 1)
+		assert.Equal(t, "素材", analysis.Result.RelatedMaterials[0].Title)
+		return task
+	}
+
+	first := analyze("原标题", AnalyzeOptions{})
+	assert.False(t, first.CacheHit)
+	assert.Equal(t, int32(1), calls.Load())
+
+	second := analyze("换了个标题", AnalyzeOptions{})
+	assert.True(t, second.CacheHit, "内容相同的文章复用缓存结果")
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, 0, second.PromptTokens)
+	assert.Zero(t, second.EstimatedTokens, "命中缓存不占用预算")
+
+	forced := analyze("强制重新分析", AnalyzeOptions{Force: true})
+	assert.False(t, forced.CacheHit)
+	assert.Equal(t, int32(2), calls.Load())
+
+	var entry model.AnalysisCache
+	require.NoError(t, db.First(&entry).Error)
+	assert.Equal(t, 1, entry.Hits)
+	assert.Equal(t, builtinPromptVersion, entry.PromptVersion)
+}
+
+func TestOpenAIClient_CacheKey(t *testing.T) {
+	cfg := &config.Config{
+		OpenAI: config.OpenAIConfig{
+			APIKey: "test-api-key",
+			Profiles: []config.ModelProfile{
+				{Name: "fast", Model: "small-model"},
+				{Name: "deep", Model: "large-model"},
+			},
+		},
+	}
+	profiles := cfg.OpenAI.ResolvedProfiles()
+	fast := NewOpenAIClientForProfile(cfg, profiles[0], logger.NewLogger("error"))
+	deep := NewOpenAIClientForProfile(cfg, profiles[1], logger.NewLogger("error"))
+
+	prompt := defaultPromptTemplate()
+	key := fast.cacheKey("内容", prompt, defaultAnalysisSchema)
+	assert.Len(t, key, 64)
+	assert.Equal(t, key, fast.cacheKey("内容", prompt, defaultAnalysisSchema))
+	assert.NotEqual(t, key, fast.cacheKey("内容。", prompt, defaultAnalysisSchema))
+	assert.NotEqual(t, key, deep.cacheKey("内容", prompt, defaultAnalysisSchema), "不同模型的结果分开缓存")
+
+	edited := *prompt
+	edited.Digest = "edited"
+	assert.NotEqual(t, key, fast.cacheKey("内容", &edited, defaultAnalysisSchema), "提示词内容修改后不复用旧结果")
+
+	schema := &model.AnalysisSchema{Name: "rhetoric", Fields: model.SchemaFields{{Key: "devices", Label: "修辞手法", Type: model.FieldTypeItems}}}
+	custom := fast.cacheKey("内容", prompt, schema)
+	assert.NotEqual(t, key, custom, "不同分析方案的结果分开缓存")
+	schema.Fields[0].Description = "列出比喻、排比等修辞"
+	assert.NotEqual(t, custom, fast.cacheKey("内容", prompt, schema), "分析方案修改后不复用旧结果")
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+)
+
+// defaultChunkTokens 未配置分段上限时单段文章内容的 token 数
+const defaultChunkTokens = 12000
+
+// chapterHeading 匹配常见的章节标题行，分段时优先在章节处断开
+var chapterHeading = regexp.MustCompile(`^\s*(第[0-9零一二三四五六七八九十百千万两]+[章节回卷部篇集]|#{1,6}\s|(?i:chapter)\s+\d+|序章|序言|楔子|引言|前言|尾声|后记)`)
+
+// splitIntoChunks 按段落将文章切分为不超过 maxTokens 的若干段
+//
+// 段落依次装入当前分段，放不下时另起一段；遇到章节标题且当前分段已过半时提前断开，
+// 尽量让每段对应完整的章节。单个段落超过上限时再按句子切分。
+func splitIntoChunks(content string, maxTokens int) []string {
+	if maxTokens <= 0 {
+		maxTokens = defaultChunkTokens
+	}
+
+	var chunks []string
+	var current []string
+	currentTokens := 0
+	flush := func() {
+		if len(current) > 0 {
+			chunks = append(chunks, strings.Join(current, "\n"))
+			current = nil
+			currentTokens = 0
+		}
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		paragraph := strings.TrimSpace(line)
+		if paragraph == "" {
+			continue
+		}
+
+		pieces := []string{paragraph}
+		if estimateTokens(paragraph) > maxTokens {
+			pieces = splitLongParagraph(paragraph, maxTokens)
+		}
+
+		for _, piece := range pieces {
+			tokens := estimateTokens(piece) + 1
+			heading := chapterHeading.MatchString(piece)
+			if currentTokens+tokens > maxTokens || (heading && currentTokens >= maxTokens/2) {
+				flush()
+			}
+			current = append(current, piece)
+			currentTokens += tokens
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+// splitLongParagraph 将超长段落按句末标点切分，单句仍超长时按字数硬切
+func splitLongParagraph(paragraph string, maxTokens int) []string {
+	var sentences []string
+	start := 0
+	for i, r := range paragraph {
+		if strings.ContainsRune("。！？!?；;", r) {
+			end := i + len(string(r))
+			sentences = append(sentences, paragraph[start:end])
+			start = end
+		}
+	}
+	if start < len(paragraph) {
+		sentences = append(sentences, paragraph[start:])
+	}
+
+	var pieces []string
+	var builder strings.Builder
+	tokens := 0
+	for _, sentence := range sentences {
+		sentenceTokens := estimateTokens(sentence)
+		if tokens+sentenceTokens > maxTokens && builder.Len() > 0 {
+			pieces = append(pieces, builder.String())
+			builder.Reset()
+			tokens = 0
+		}
+		if sentenceTokens > maxTokens {
+			// 每个字符至多 1 个 token，按 maxTokens 个字符切分不会超限
+			runes := []rune(sentence)
+			for len(runes) > maxTokens {
+				pieces = append(pieces, string(runes[:maxTokens]))
+				runes = runes[maxTokens:]
+			}
+			sentence = string(runes)
+			sentenceTokens = estimateTokens(sentence)
+		}
+		builder.WriteString(sentence)
+		tokens += sentenceTokens
+	}
+	if builder.Len() > 0 {
+		pieces = append(pieces, builder.String())
+	}
+	return pieces
+}
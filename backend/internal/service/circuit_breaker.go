@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 模型服务处于熔断状态，请求未发出
+var ErrCircuitOpen = errors.New("模型服务熔断中")
+
+type circuitState int
+
+const (
+	circuitClosed   circuitState = iota // 正常放行
+	circuitOpen                         // 熔断，直接拒绝
+	circuitHalfOpen                     // 熔断到期，放行一次探测请求
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// circuitBreaker 模型服务熔断器
+//
+// 连续 threshold 次可用性失败（超时、5xx、限流、网络错误）后熔断，熔断期间拒绝请求；
+// 经过 cooldown 后放行一次探测请求，成功则恢复，失败则重新熔断。
+// 鉴权失败、参数错误等说明服务本身可达，不计入失败。
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     circuitState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	now       func() time.Time
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		threshold = 5
+	}
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// allow 判断是否可以发出请求，半开状态下同一时间只放行一个探测请求
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = circuitHalfOpen
+		b.probing = true
+		return true
+	case circuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record 记录一次请求的结果，每次 allow 返回 true 后都应调用
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if err != nil && errors.Is(err, context.Canceled) {
+		// 调用方主动放弃，无法判断服务状态
+		return
+	}
+	if retryable, _ := classifyError(err); err == nil || !retryable {
+		b.state = circuitClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.threshold {
+		b.state = circuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+func (b *circuitBreaker) currentState() circuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
@@ -0,0 +1,417 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"article-analysis/internal/model"
+
+	"go.uber.org/zap"
+)
+
+// 对比两次分析时要点的变化
+const (
+	DiffUnchanged = "unchanged"
+	DiffChanged   = "changed"
+	DiffAdded     = "added"
+	DiffRemoved   = "removed"
+)
+
+const (
+	// itemMatchThreshold 标题和说明的相似度不低于该值的两条要点视为同一要点
+	itemMatchThreshold = 0.5
+	// itemSameThreshold 同一要点的相似度不低于该值时视为没有变化
+	itemSameThreshold = 0.95
+)
+
+var (
+	ErrCompareNotCompleted = errors.New("只能对比已完成的分析")
+	ErrInvalidComparison   = errors.New("对比分析需要两个不同的模型配置")
+)
+
+// AnalysisRun 参与对比的一次分析
+type AnalysisRun struct {
+	ID            uint64     `json:"id"`
+	Version       int        `json:"version"`
+	Provider      string     `json:"provider"`
+	Profile       string     `json:"profile"`
+	Model         string     `json:"model"`
+	PromptVersion string     `json:"prompt_version"`
+	Schema        string     `json:"schema"`
+	Genre         string     `json:"genre"`
+	AnalysisTime  *time.Time `json:"analysis_time"`
+}
+
+// ItemDiff 一条要点的变化，A、B 分别为两次分析中的要点
+type ItemDiff struct {
+	Status     string              `json:"status"`
+	A          *model.AnalysisItem `json:"a,omitempty"`
+	B          *model.AnalysisItem `json:"b,omitempty"`
+	Similarity float64             `json:"similarity"`
+}
+
+// FieldDiff 一个分析维度的对比结果。要点列表逐条对比，词语列表给出增减的词语，文字直接给出两段文字
+type FieldDiff struct {
+	Key        string     `json:"key"`
+	Label      string     `json:"label"`
+	Type       string     `json:"type"`
+	Similarity float64    `json:"similarity"`
+	Items      []ItemDiff `json:"items,omitempty"`
+	Added      []string   `json:"added,omitempty"`
+	Removed    []string   `json:"removed,omitempty"`
+	Common     []string   `json:"common,omitempty"`
+	A          string     `json:"a,omitempty"`
+	B          string     `json:"b,omitempty"`
+}
+
+// AnalysisComparison 同一篇文章两次分析的对比
+type AnalysisComparison struct {
+	ArticleID  uint64      `json:"article_id,string"`
+	A          AnalysisRun `json:"a"`
+	B          AnalysisRun `json:"b"`
+	Similarity float64     `json:"similarity"` // 各维度相似度的平均值
+	Fields     []FieldDiff `json:"fields"`
+}
+
+// CompareAnalyses 逐个维度、逐条要点对比文章的两次分析
+func (s *AnalysisService) CompareAnalyses(articleID, a, b uint64) (*AnalysisComparison, error) {
+	analysisA, err := s.GetAnalysis(articleID, a)
+	if err != nil {
+		return nil, err
+	}
+	analysisB, err := s.GetAnalysis(articleID, b)
+	if err != nil {
+		return nil, err
+	}
+	if analysisA.AnalysisStatus != "completed" || analysisB.AnalysisStatus != "completed" {
+		return nil, ErrCompareNotCompleted
+	}
+
+	fieldsA, valuesA := s.analysisValues(analysisA)
+	fieldsB, valuesB := s.analysisValues(analysisB)
+
+	// 维度按 A 的顺序排列，B 中独有的维度排在后面
+	typesB := make(map[string]model.SchemaField, len(fieldsB))
+	for _, field := range fieldsB {
+		typesB[field.Key] = field
+	}
+	fields := append([]model.SchemaField{}, fieldsA...)
+	seen := make(map[string]bool, len(fieldsA))
+	for _, field := range fieldsA {
+		seen[field.Key] = true
+	}
+	for _, field := range fieldsB {
+		if !seen[field.Key] {
+			fields = append(fields, field)
+		}
+	}
+
+	result := &AnalysisComparison{
+		ArticleID: articleID,
+		A:         analysisRun(analysisA),
+		B:         analysisRun(analysisB),
+		Fields:    make([]FieldDiff, 0, len(fields)),
+	}
+	total := 0.0
+	for _, field := range fields {
+		fieldB, ok := typesB[field.Key]
+		if !ok {
+			fieldB = field
+		}
+		diff := compareField(field, fieldB, valuesA[field.Key], valuesB[field.Key])
+		total += diff.Similarity
+		result.Fields = append(result.Fields, diff)
+	}
+	result.Similarity = 1
+	if len(fields) > 0 {
+		result.Similarity = roundScore(total / float64(len(fields)))
+	}
+	return result, nil
+}
+
+func analysisRun(a *model.ArticleAnalysis) AnalysisRun {
+	return AnalysisRun{
+		ID:            a.ID,
+		Version:       a.Version,
+		Provider:      a.Provider,
+		Profile:       a.Profile,
+		Model:         a.Model,
+		PromptVersion: a.PromptVersion,
+		Schema:        a.Schema,
+		Genre:         a.Genre,
+		AnalysisTime:  a.AnalysisTime,
+	}
+}
+
+// analysisValues 返回一次分析的维度及各维度的结果：自定义方案取 Fields，内置方案取结构化结果，
+// 早期只有文本的结果按文字对比
+func (s *AnalysisService) analysisValues(analysis *model.ArticleAnalysis) ([]model.SchemaField, map[string]json.RawMessage) {
+	if analysis.Fields != nil {
+		schema, err := s.analysisSchema(analysis)
+		if err != nil {
+			s.log.Warn("读取分析方案失败，按结果推断维度", zap.String("schema", analysis.Schema), zap.Error(err))
+			return inferFields(analysis.Fields), analysis.Fields
+		}
+		return schema.Fields, analysis.Fields
+	}
+
+	values := make(map[string]json.RawMessage)
+	if analysis.Result != nil {
+		data, _ := json.Marshal(analysis.Result)
+		json.Unmarshal(data, &values)
+		return defaultAnalysisSchema.Fields, values
+	}
+
+	texts := map[string]string{
+		"core_viewpoints":   analysis.CoreViewpoints,
+		"file_structure":    analysis.FileStructure,
+		"author_thoughts":   analysis.AuthorThoughts,
+		"related_materials": analysis.RelatedMaterials,
+	}
+	fields := make([]model.SchemaField, 0, len(defaultAnalysisSchema.Fields))
+	for _, field := range defaultAnalysisSchema.Fields {
+		field.Type = model.FieldTypeText
+		fields = append(fields, field)
+		values[field.Key], _ = json.Marshal(texts[field.Key])
+	}
+	return fields, values
+}
+
+// inferFields 分析方案已被删除时，按结果推断维度及其类型
+func inferFields(values model.AnalysisFields) []model.SchemaField {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fields := make([]model.SchemaField, 0, len(keys))
+	for _, key := range keys {
+		field := model.SchemaField{Key: key, Label: key, Type: model.FieldTypeText}
+		var list []json.RawMessage
+		if json.Unmarshal(values[key], &list) == nil {
+			field.Type = model.FieldTypeItems
+			if len(list) > 0 && strings.HasPrefix(strings.TrimSpace(string(list[0])), `"`) {
+				field.Type = model.FieldTypeList
+			}
+		}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// compareField 对比一个维度，两次分析中类型不同时（如分析方案被修改过）按文字对比
+func compareField(fieldA, fieldB model.SchemaField, a, b json.RawMessage) FieldDiff {
+	diff := FieldDiff{Key: fieldA.Key, Label: fieldA.Label, Type: fieldA.Type}
+	if fieldA.Type != fieldB.Type {
+		diff.Type = model.FieldTypeText
+	}
+
+	switch diff.Type {
+	case model.FieldTypeItems:
+		var itemsA, itemsB []model.AnalysisItem
+		json.Unmarshal(a, &itemsA)
+		json.Unmarshal(b, &itemsB)
+		diff.Items, diff.Similarity = diffItems(itemsA, itemsB)
+	case model.FieldTypeList:
+		var listA, listB []string
+		json.Unmarshal(a, &listA)
+		json.Unmarshal(b, &listB)
+		diff.Added, diff.Removed, diff.Common, diff.Similarity = diffList(listA, listB)
+	default:
+		diff.A = fieldText(fieldA, a)
+		diff.B = fieldText(fieldB, b)
+		diff.Similarity = roundScore(textSimilarity(diff.A, diff.B))
+	}
+	return diff
+}
+
+// diffItems 按标题和说明的相似度将两组要点一一配对，相似度高的优先；
+// 结果按 A 中的顺序排列，B 中新增的要点排在最后
+func diffItems(a, b []model.AnalysisItem) ([]ItemDiff, float64) {
+	type pair struct {
+		i, j  int
+		score float64
+	}
+	var pairs []pair
+	for i := range a {
+		for j := range b {
+			if score := textSimilarity(itemText(a[i]), itemText(b[j])); score >= itemMatchThreshold {
+				pairs = append(pairs, pair{i, j, score})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(x, y int) bool { return pairs[x].score > pairs[y].score })
+
+	matchA := make(map[int]pair)
+	usedB := make(map[int]bool)
+	for _, p := range pairs {
+		if _, ok := matchA[p.i]; ok || usedB[p.j] {
+			continue
+		}
+		matchA[p.i] = p
+		usedB[p.j] = true
+	}
+
+	diffs := make([]ItemDiff, 0, len(a)+len(b))
+	total := 0.0
+	for i := range a {
+		p, ok := matchA[i]
+		if !ok {
+			diffs = append(diffs, ItemDiff{Status: DiffRemoved, A: &a[i]})
+			continue
+		}
+		status := DiffChanged
+		if p.score >= itemSameThreshold {
+			status = DiffUnchanged
+		}
+		total += p.score
+		diffs = append(diffs, ItemDiff{Status: status, A: &a[i], B: &b[p.j], Similarity: roundScore(p.score)})
+	}
+	for j := range b {
+		if !usedB[j] {
+			diffs = append(diffs, ItemDiff{Status: DiffAdded, B: &b[j]})
+		}
+	}
+
+	if len(a)+len(b) == 0 {
+		return diffs, 1
+	}
+	return diffs, roundScore(2 * total / float64(len(a)+len(b)))
+}
+
+// diffList 对比两组词语，相似度为交集与并集之比
+func diffList(a, b []string) (added, removed, common []string, similarity float64) {
+	inA := make(map[string]bool, len(a))
+	for _, v := range a {
+		inA[strings.TrimSpace(v)] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, v := range b {
+		v = strings.TrimSpace(v)
+		if inB[v] {
+			continue
+		}
+		inB[v] = true
+		if inA[v] {
+			common = append(common, v)
+		} else {
+			added = append(added, v)
+		}
+	}
+	for _, v := range a {
+		if v = strings.TrimSpace(v); !inB[v] {
+			removed = append(removed, v)
+		}
+	}
+
+	union := len(common) + len(added) + len(removed)
+	if union == 0 {
+		return nil, nil, nil, 1
+	}
+	return added, removed, common, roundScore(float64(len(common)) / float64(union))
+}
+
+func itemText(item model.AnalysisItem) string {
+	return item.Title + item.Explanation
+}
+
+// textSimilarity 两段文字的相似度：去掉空白和标点后按相邻两字计算 Dice 系数
+func textSimilarity(a, b string) float64 {
+	ra, rb := newMatchText(a).runes, newMatchText(b).runes
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+	if len(ra) < 2 || len(rb) < 2 {
+		if string(ra) == string(rb) {
+			return 1
+		}
+		return 0
+	}
+
+	bigrams := make(map[[2]rune]int)
+	for i := 0; i+1 < len(ra); i++ {
+		bigrams[[2]rune{ra[i], ra[i+1]}]++
+	}
+	shared := 0
+	for i := 0; i+1 < len(rb); i++ {
+		key := [2]rune{rb[i], rb[i+1]}
+		if bigrams[key] > 0 {
+			bigrams[key]--
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(ra)-1+len(rb)-1)
+}
+
+func roundScore(score float64) float64 {
+	return math.Round(score*1000) / 1000
+}
+
+// ComparisonRun 对比分析中一个模型配置的任务
+type ComparisonRun struct {
+	Profile    string `json:"profile"`
+	TaskID     string `json:"task_id"`
+	AnalysisID uint64 `json:"analysis_id"`
+}
+
+// ComparisonSubmission 对比分析的提交结果，全部任务完成后可用两次分析的ID调用对比接口
+type ComparisonSubmission struct {
+	BatchID   string          `json:"batch_id"`
+	ArticleID uint64          `json:"article_id,string"`
+	Runs      []ComparisonRun `json:"runs"`
+}
+
+// AnalyzeWithProfiles 用两个模型配置同时分析同一篇文章以便对比，
+// 两个任务属于同一批次，可通过批次接口查看进度；结果不会自动成为当前结果
+func (s *AnalysisService) AnalyzeWithProfiles(articleID uint64, profiles []string, opts AnalyzeOptions) (*ComparisonSubmission, error) {
+	if len(profiles) != 2 || profiles[0] == profiles[1] {
+		return nil, ErrInvalidComparison
+	}
+	for _, profile := range profiles {
+		if _, _, err := s.resolveProfile(profile); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := s.articleRepo.GetByID(articleID); err != nil {
+		return nil, ErrArticleNotFound
+	}
+	if err := s.checkNoActiveTask(articleID); err != nil {
+		return nil, err
+	}
+
+	batch := &model.AnalysisBatch{BatchID: newOpaqueID(), Requested: len(profiles)}
+	if err := s.taskRepo.CreateBatch(batch); err != nil {
+		s.log.Error("创建批次失败", err)
+		return nil, errors.New("创建批次失败")
+	}
+
+	result := &ComparisonSubmission{BatchID: batch.BatchID, ArticleID: articleID}
+	for _, profile := range profiles {
+		runOpts := opts
+		runOpts.Profile = profile
+		task, err := s.createTask(articleID, batch.BatchID, runOpts, true)
+		if err != nil {
+			// 已入队的任务一并取消，避免只剩一个模型的结果
+			for _, run := range result.Runs {
+				if cancelErr := s.CancelTask(run.TaskID); cancelErr != nil {
+					s.log.Warn("取消对比分析任务失败", zap.String("task_id", run.TaskID), zap.Error(cancelErr))
+				}
+			}
+			return nil, fmt.Errorf("模型配置 %s: %w", profile, err)
+		}
+		result.Runs = append(result.Runs, ComparisonRun{Profile: profile, TaskID: task.TaskID, AnalysisID: task.AnalysisID})
+	}
+
+	batch.Queued = len(result.Runs)
+	if err := s.taskRepo.UpdateBatch(batch); err != nil {
+		s.log.Warn("更新批次信息失败", zap.String("batch_id", batch.BatchID), zap.Error(err))
+	}
+	s.pool.Notify()
+	return result, nil
+}
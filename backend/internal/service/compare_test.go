@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"article-analysis/internal/config"
+	"article-analysis/internal/model"
+	"article-analysis/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffItems(t *testing.T) {
+	a := []model.AnalysisItem{
+		{Title: "教育应培养独立思考的人", Explanation: "作者认为教育的根本目的是培养独立思考能力"},
+		{Title: "考试不是唯一标准"},
+		{Title: "阅读的重要性", Explanation: "阅读能拓宽视野"},
+	}
+	b := []model.AnalysisItem{
+		{Title: "阅读的重要性", Explanation: "阅读能拓宽视野"},
+		{Title: "教育应当培养独立思考的人", Explanation: "作者认为教育的根本目的在于培养独立思考能力"},
+		{Title: "家庭教育同样关键"},
+	}
+
+	diffs, similarity := diffItems(a, b)
+	require.Len(t, diffs, 4)
+	assert.Equal(t, DiffChanged, diffs[0].Status)
+	assert.Same(t, &b[1], diffs[0].B, "改写过的要点与原要点配对")
+	assert.Greater(t, diffs[0].Similarity, itemMatchThreshold)
+	assert.Less(t, diffs[0].Similarity, itemSameThreshold)
+	assert.Equal(t, DiffRemoved, diffs[1].Status)
+	assert.Nil(t, diffs[1].B)
+	assert.Equal(t, DiffUnchanged, diffs[2].Status)
+	assert.Equal(t, 1.0, diffs[2].Similarity)
+	assert.Equal(t, DiffAdded, diffs[3].Status)
+	assert.Equal(t, "家庭教育同样关键", diffs[3].B.Title)
+	assert.InDelta(t, 2*(1+diffs[0].Similarity)/6, similarity, 0.001)
+
+	_, similarity = diffItems(nil, nil)
+	assert.Equal(t, 1.0, similarity)
+}
+
+func TestDiffList(t *testing.T) {
+	added, removed, common, similarity := diffList([]string{"成长", "亲情", "时间"}, []string{"时间", "成长", "梦想"})
+	assert.Equal(t, []string{"梦想"}, added)
+	assert.Equal(t, []string{"亲情"}, removed)
+	assert.Equal(t, []string{"时间", "成长"}, common)
+	assert.Equal(t, 0.5, similarity)
+}
+
+func TestAnalysisService_CompareProfiles(t *testing.T) {
+	cfg := &config.Config{
+		OpenAI: config.OpenAIConfig{
+			APIKey:         "test-api-key",
+			DefaultProfile: "fast",
+			Profiles:       []config.ModelProfile{{Name: "fast", Model: "small-model"}, {Name: "deep", Model: "large-model"}},
+		},
+		Analysis: config.AnalysisConfig{MaxConcurrency: 2, PollInterval: 1, Timeout: 10},
+	}
+	fast, deep := &MockOpenAIClient{}, &MockOpenAIClient{}
+	s, _, db := newTestAnalysisServiceWithAnalyzers(t, cfg, map[string]Analyzer{"fast": fast, "deep": deep})
+
+	article := &model.Article{Title: "文章", Author: "张三", Content: "内容", FilePath: "f.txt"}
+	require.NoError(t, repository.NewArticleRepository(db).Create(article))
+
+	fast.On("AnalyzeArticle", mock.Anything, "内容").Return(&AnalysisResponse{Model: "small-model", Result: model.AnalysisResult{
+		CoreViewpoints: []model.AnalysisItem{{Title: "珍惜时间"}},
+	}}, nil).Once()
+	deep.On("AnalyzeArticle", mock.Anything, "内容").Return(&AnalysisResponse{Model: "large-model", Result: model.AnalysisResult{
+		CoreViewpoints: []model.AnalysisItem{{Title: "珍惜时间"}, {Title: "把握当下"}},
+	}}, nil).Once()
+
+	_, err := s.AnalyzeWithProfiles(article.ID, []string{"fast", "fast"}, AnalyzeOptions{})
+	assert.ErrorIs(t, err, ErrInvalidComparison)
+	_, err = s.AnalyzeWithProfiles(article.ID, []string{"fast", "unknown"}, AnalyzeOptions{})
+	assert.ErrorIs(t, err, ErrUnknownProfile)
+	_, err = s.AnalyzeWithProfiles(article.ID+1, []string{"fast", "deep"}, AnalyzeOptions{})
+	assert.ErrorIs(t, err, ErrArticleNotFound)
+
+	submitted, err := s.AnalyzeWithProfiles(article.ID, []string{"fast", "deep"}, AnalyzeOptions{})
+	require.NoError(t, err)
+	require.Len(t, submitted.Runs, 2)
+
+	_, err = s.CompareAnalyses(article.ID, submitted.Runs[0].AnalysisID, submitted.Runs[1].AnalysisID)
+	assert.ErrorIs(t, err, ErrCompareNotCompleted)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	require.Eventually(t, func() bool {
+		status, err := s.GetBatchStatus(submitted.BatchID)
+		return err == nil && status["finished"] == true
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	s.Wait()
+
+	comparison, err := s.CompareAnalyses(article.ID, submitted.Runs[0].AnalysisID, submitted.Runs[1].AnalysisID)
+	require.NoError(t, err)
+	assert.Equal(t, "small-model", comparison.A.Model)
+	assert.Equal(t, "large-model", comparison.B.Model)
+	require.Len(t, comparison.Fields, 4)
+	viewpoints := comparison.Fields[0]
+	assert.Equal(t, "core_viewpoints", viewpoints.Key)
+	require.Len(t, viewpoints.Items, 2)
+	assert.Equal(t, DiffUnchanged, viewpoints.Items[0].Status)
+	assert.Equal(t, DiffAdded, viewpoints.Items[1].Status)
+	assert.InDelta(t, 2.0/3, viewpoints.Similarity, 0.001)
+	assert.Equal(t, 1.0, comparison.Fields[1].Similarity, "两边都为空的维度视为相同")
+
+	analyses, err := s.ListAnalyses(article.ID)
+	require.NoError(t, err)
+	for _, analysis := range analyses {
+		assert.False(t, analysis.IsCurrent, "对比分析的结果不自动成为当前结果")
+	}
+	fast.AssertExpectations(t)
+	deep.AssertExpectations(t)
+}
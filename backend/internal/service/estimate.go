@@ -0,0 +1,121 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"unicode/utf8"
+
+	"article-analysis/internal/model"
+
+	"go.uber.org/zap"
+)
+
+// ErrEstimateExceeded 预估用量超过配置的单次分析上限
+var ErrEstimateExceeded = errors.New("预估用量超过单次分析上限")
+
+// ModelEstimate 使用某个模型配置分析文章的预估用量与费用
+type ModelEstimate struct {
+	Profile  string `json:"profile"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	UsageEstimate
+	Cost         float64 `json:"cost"` // 未配置价格时为 0
+	Currency     string  `json:"currency"`
+	Default      bool    `json:"default"`
+	ExceedsLimit bool    `json:"exceeds_limit"`
+}
+
+// AnalysisEstimate 文章分析的预估结果
+type AnalysisEstimate struct {
+	ArticleID     uint64          `json:"article_id,string"`
+	Characters    int             `json:"characters"`
+	ContentTokens int             `json:"content_tokens"`
+	MaxTokens     int             `json:"max_tokens"` // 单次分析 token 上限，0 表示不限制
+	MaxCost       float64         `json:"max_cost"`   // 单次分析费用上限，0 表示不限制
+	Estimates     []ModelEstimate `json:"estimates"`
+}
+
+// EstimateAnalysis 估算用每个模型配置分析文章的 token 数与费用
+//
+// opts 与提交分析时相同：按指定的分析方案、体裁和提示词版本估算，指定模型配置时只估算该配置。
+func (s *AnalysisService) EstimateAnalysis(articleID uint64, opts AnalyzeOptions) (*AnalysisEstimate, error) {
+	article, err := s.articleRepo.GetByID(articleID)
+	if err != nil {
+		return nil, ErrArticleNotFound
+	}
+	if opts.Profile != "" {
+		if _, _, err := s.resolveProfile(opts.Profile); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateGenre(opts.Genre); err != nil {
+		return nil, err
+	}
+	schema, err := s.resolveSchema(opts.Schema)
+	if err != nil {
+		return nil, err
+	}
+	// 与提交时一样按指定或识别出的体裁选择提示词
+	_, _, prompt, err := s.choosePrompt(article.Content, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := prompt.supports(schema); err != nil {
+		return nil, err
+	}
+
+	result := &AnalysisEstimate{
+		ArticleID:     articleID,
+		Characters:    utf8.RuneCountInString(article.Content),
+		ContentTokens: estimateTokens(article.Content),
+		MaxTokens:     s.maxTokens,
+		MaxCost:       s.maxCost,
+		Estimates:     []ModelEstimate{},
+	}
+	for _, info := range s.ListProfiles() {
+		if opts.Profile != "" && info.Name != opts.Profile {
+			continue
+		}
+		estimate, ok := s.estimateFor(info.Name, s.analyzers[info.Name], prompt, schema, article.Content)
+		if !ok {
+			continue
+		}
+		estimate.Provider = info.Provider
+		estimate.Model = info.Model
+		estimate.Default = info.Default
+		result.Estimates = append(result.Estimates, *estimate)
+	}
+	return result, nil
+}
+
+// estimateFor 估算指定模型配置的用量与费用，分析器不支持预估时返回 false
+func (s *AnalysisService) estimateFor(profile string, analyzer Analyzer, prompt *PromptTemplate, schema *model.AnalysisSchema, content string) (*ModelEstimate, bool) {
+	estimator, ok := analyzer.(usageEstimator)
+	if !ok {
+		return nil, false
+	}
+
+	usage := estimator.estimateUsage(content, prompt, schema)
+	estimate := &ModelEstimate{Profile: profile, UsageEstimate: usage, Currency: s.currency}
+	estimate.Cost = math.Round(s.callCost(profile, usage.InputTokens, usage.OutputTokens)*1e4) / 1e4
+	estimate.ExceedsLimit = (s.maxTokens > 0 && usage.InputTokens+usage.OutputTokens > s.maxTokens) ||
+		(s.maxCost > 0 && estimate.Cost > s.maxCost)
+	return estimate, true
+}
+
+// checkEstimate 提交前估算用量，超过上限时拒绝；分析器不支持预估时返回 nil
+func (s *AnalysisService) checkEstimate(article *model.Article, profile string, analyzer Analyzer, prompt *PromptTemplate, schema *model.AnalysisSchema) (*ModelEstimate, error) {
+	estimate, ok := s.estimateFor(profile, analyzer, prompt, schema, article.Content)
+	if !ok || !estimate.ExceedsLimit {
+		return estimate, nil
+	}
+
+	s.log.Warn("预估用量超过上限，拒绝提交",
+		zap.Uint64("article_id", article.ID),
+		zap.String("profile", profile),
+		zap.Int("tokens", estimate.InputTokens+estimate.OutputTokens),
+		zap.Float64("cost", estimate.Cost))
+	return nil, fmt.Errorf("%w：预计消耗 %d tokens，约 %.2f %s",
+		ErrEstimateExceeded, estimate.InputTokens+estimate.OutputTokens, estimate.Cost, estimate.Currency)
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"article-analysis/internal/config"
+	"article-analysis/internal/model"
+	"article-analysis/internal/repository"
+	"article-analysis/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	assert.Equal(t, 4, estimateTokens("文章分析"))
+	assert.Equal(t, 5, estimateTokens("你好，世界"))
+	assert.Equal(t, 3, estimateTokens("hello world!"))
+	assert.Equal(t, 6, estimateTokens("GPT-4 模型"))
+	assert.Equal(t, 4, estimateTokens("internationalization"))
+	assert.Equal(t, 0, estimateTokens(""))
+}
+
+func TestPromptTemplate_AnalysisPromptCountsCharacters(t *testing.T) {
+	prompt, err := defaultPromptTemplate().analysisPrompt(defaultAnalysisSchema, "测试文章")
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "文章内容长度：4字符")
+}
+
+func newEstimateTestService(t *testing.T, analysis config.AnalysisConfig) (*AnalysisService, *repository.ArticleRepository) {
+	db := newTestDB(t)
+	cfg := &config.Config{
+		OpenAI: config.OpenAIConfig{
+			APIKey:         "test-api-key",
+			APIBase:        "http://localhost",
+			Currency:       "CNY",
+			DefaultProfile: "fast",
+			Profiles: []config.ModelProfile{
+				{Name: "fast", Model: "small-model", MaxTokens: 500, InputPrice: 1, OutputPrice: 2},
+				{Name: "deep", Model: "large-model", InputPrice: 10, OutputPrice: 30},
+			},
+		},
+		Analysis: analysis,
+	}
+	articleRepo := repository.NewArticleRepository(db)
+	s := NewAnalysisService(repository.NewAnalysisRepository(db), articleRepo, repository.NewTaskRepository(db), cfg, logger.NewLogger("error"))
+	return s, articleRepo
+}
+
+func TestAnalysisService_EstimateAnalysis(t *testing.T) {
+	s, articleRepo := newEstimateTestService(t, config.AnalysisConfig{ChunkTokens: 1000})
+
+	article := &model.Article{Title: "长文", Author: "张三", Content: strings.Repeat(strings.Repeat("字", 300)+"\n", 10), FilePath: "f.txt"}
+	require.NoError(t, articleRepo.Create(article))
+
+	estimate, err := s.EstimateAnalysis(article.ID, AnalyzeOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 3010, estimate.Characters)
+	require.Len(t, estimate.Estimates, 2)
+
+	fast := estimate.Estimates[0]
+	assert.Equal(t, "fast", fast.Profile)
+	assert.True(t, fast.Default)
+	assert.Equal(t, 5, fast.Calls, "分4段分析加1次合并")
+	assert.Greater(t, fast.InputTokens, 3000)
+	assert.Equal(t, 5*500, fast.OutputTokens, "输出按 max_tokens 估算")
+	assert.Equal(t, "CNY", fast.Currency)
+
+	deep := estimate.Estimates[1]
+	assert.Equal(t, 5*defaultOutputTokens, deep.OutputTokens)
+	assert.Greater(t, deep.Cost, fast.Cost)
+	assert.False(t, deep.ExceedsLimit, "未配置上限时不超限")
+
+	deepOnly, err := s.EstimateAnalysis(article.ID, AnalyzeOptions{Profile: "deep"})
+	require.NoError(t, err)
+	require.Len(t, deepOnly.Estimates, 1, "指定模型配置时只估算该配置")
+	assert.Equal(t, deep, deepOnly.Estimates[0])
+
+	_, err = s.EstimateAnalysis(999, AnalyzeOptions{})
+	assert.ErrorIs(t, err, ErrArticleNotFound)
+	_, err = s.EstimateAnalysis(article.ID, AnalyzeOptions{Profile: "missing"})
+	assert.ErrorIs(t, err, ErrUnknownProfile)
+	_, err = s.EstimateAnalysis(article.ID, AnalyzeOptions{Schema: "missing"})
+	assert.ErrorIs(t, err, ErrUnknownSchema)
+	_, err = s.EstimateAnalysis(article.ID, AnalyzeOptions{Genre: "missing"})
+	assert.ErrorIs(t, err, ErrUnknownGenre)
+	_, err = s.EstimateAnalysis(article.ID, AnalyzeOptions{Prompt: "missing"})
+	assert.ErrorIs(t, err, ErrUnknownPrompt)
+}
+
+func TestAnalysisService_RejectsAnalysisOverCeiling(t *testing.T) {
+	s, articleRepo := newEstimateTestService(t, config.AnalysisConfig{MaxEstimatedCost: 0.05})
+
+	article := &model.Article{Title: "文章", Author: "张三", Content: strings.Repeat("字", 2000), FilePath: "f.txt"}
+	require.NoError(t, articleRepo.Create(article))
+
+	_, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{Profile: "deep"})
+	assert.ErrorIs(t, err, ErrEstimateExceeded)
+
+	submitted, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{Profile: "fast"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, submitted.TaskID)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// 领域事件类型
+const (
+	EventArticleCreated = "article.created"
+	EventArticleDeleted = "article.deleted"
+	EventAnalysisStatus = "analysis.status" // 分析记录状态变化：pending/processing/completed/failed/cancelled
+)
+
+// DomainEvent 服务发布到事件总线的事件
+type DomainEvent struct {
+	Type      string      `json:"type"`
+	ArticleID uint64      `json:"article_id,string"`
+	Author    string      `json:"author"`
+	Data      interface{} `json:"data,omitempty"`
+	Time      time.Time   `json:"time"`
+}
+
+// AnalysisStatusData analysis.status 事件的内容
+type AnalysisStatusData struct {
+	AnalysisID uint64 `json:"analysis_id,string"`
+	TaskID     string `json:"task_id"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+}
+
+// EventFilter 订阅过滤条件，各条件之间为且的关系，为空的条件不限制
+type EventFilter struct {
+	Types      []string
+	ArticleIDs []uint64
+	Authors    []string
+}
+
+// Match 事件是否满足过滤条件
+func (f EventFilter) Match(event DomainEvent) bool {
+	if len(f.Types) > 0 && !containsString(f.Types, event.Type) {
+		return false
+	}
+	if len(f.Authors) > 0 && !containsString(f.Authors, event.Author) {
+		return false
+	}
+	if len(f.ArticleIDs) > 0 {
+		for _, id := range f.ArticleIDs {
+			if id == event.ArticleID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// eventSubscriptionBuffer 每个订阅缓存的事件数，超出时关闭该订阅
+const eventSubscriptionBuffer = 64
+
+// EventBus 进程内的领域事件总线，发布不阻塞：订阅者处理不及时时关闭其订阅，由客户端重新订阅。
+// 多实例部署时各实例只能收到本实例发布的事件
+type EventBus struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscription 一个事件订阅，通过 Events 接收事件，不再需要时调用 Close
+type Subscription struct {
+	bus    *EventBus
+	ch     chan DomainEvent
+	filter EventFilter
+	closed bool
+}
+
+// Subscribe 按过滤条件订阅事件
+func (b *EventBus) Subscribe(filter EventFilter) *Subscription {
+	sub := &Subscription{bus: b, ch: make(chan DomainEvent, eventSubscriptionBuffer), filter: filter}
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+	return sub
+}
+
+// Publish 发布事件，总线为 nil 时忽略
+func (b *EventBus) Publish(event DomainEvent) {
+	if b == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		if !sub.filter.Match(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			sub.closeLocked()
+		}
+	}
+}
+
+// Events 返回事件通道，订阅关闭后通道关闭
+func (s *Subscription) Events() <-chan DomainEvent {
+	return s.ch
+}
+
+// SetFilter 更换过滤条件，之后发布的事件按新条件过滤
+func (s *Subscription) SetFilter(filter EventFilter) {
+	s.bus.mu.Lock()
+	s.filter = filter
+	s.bus.mu.Unlock()
+}
+
+// Close 取消订阅，可重复调用
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	s.closeLocked()
+	s.bus.mu.Unlock()
+}
+
+func (s *Subscription) closeLocked() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(s.bus.subs, s)
+	close(s.ch)
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"article-analysis/internal/model"
+	"article-analysis/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventFilter_Match(t *testing.T) {
+	event := DomainEvent{Type: EventArticleCreated, ArticleID: 7, Author: "张三"}
+	assert.True(t, EventFilter{}.Match(event))
+	assert.True(t, EventFilter{ArticleIDs: []uint64{3, 7}}.Match(event))
+	assert.False(t, EventFilter{ArticleIDs: []uint64{3}}.Match(event))
+	assert.True(t, EventFilter{Authors: []string{"张三"}, Types: []string{EventArticleCreated}}.Match(event))
+	assert.False(t, EventFilter{Authors: []string{"张三"}, Types: []string{EventArticleDeleted}}.Match(event), "条件之间为且的关系")
+}
+
+func TestEventBus_ClosesSlowSubscriber(t *testing.T) {
+	bus := NewEventBus()
+	slow := bus.Subscribe(EventFilter{})
+	other := bus.Subscribe(EventFilter{Authors: []string{"李四"}})
+	defer other.Close()
+
+	for i := 0; i <= eventSubscriptionBuffer; i++ {
+		bus.Publish(DomainEvent{Type: EventArticleCreated, ArticleID: uint64(i), Author: "张三"})
+	}
+	received := 0
+	for range slow.Events() {
+		received++
+	}
+	assert.Equal(t, eventSubscriptionBuffer, received, "缓存满后关闭订阅，发布不阻塞")
+	slow.Close()
+
+	bus.Publish(DomainEvent{Type: EventArticleDeleted, ArticleID: 1, Author: "李四"})
+	event := <-other.Events()
+	assert.Equal(t, EventArticleDeleted, event.Type, "其他订阅不受影响")
+}
+
+func TestAnalysisService_PublishesAnalysisStatus(t *testing.T) {
+	analyzer := &MockOpenAIClient{}
+	s, taskRepo, db := newTestAnalysisServiceWithAnalyzer(t, nil, analyzer)
+	bus := NewEventBus()
+	s.SetEventBus(bus)
+
+	articleRepo := repository.NewArticleRepository(db)
+	watched := &model.Article{Title: "文章", Author: "张三", Content: "测试文章内容", FilePath: "a.txt"}
+	other := &model.Article{Title: "另一篇", Author: "李四", Content: "另一篇内容", FilePath: "b.txt"}
+	require.NoError(t, articleRepo.Create(watched))
+	require.NoError(t, articleRepo.Create(other))
+
+	byAuthor := bus.Subscribe(EventFilter{Authors: []string{"张三"}})
+	defer byAuthor.Close()
+	byArticle := bus.Subscribe(EventFilter{ArticleIDs: []uint64{other.ID}})
+	defer byArticle.Close()
+
+	analyzer.On("AnalyzeArticle", mock.Anything, "测试文章内容").Return(&AnalysisResponse{CoreViewpoints: "观点"}, nil)
+	analyzer.On("AnalyzeArticle", mock.Anything, "另一篇内容").Return(nil, errors.New("模型不可用"))
+
+	for _, article := range []*model.Article{watched, other} {
+		submitted, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{})
+		require.NoError(t, err)
+		runUntilFinished(t, s, taskRepo, submitted.TaskID)
+	}
+
+	statuses := func(sub *Subscription, articleID uint64) []string {
+		var list []string
+		for len(sub.Events()) > 0 {
+			event := <-sub.Events()
+			assert.Equal(t, EventAnalysisStatus, event.Type)
+			assert.Equal(t, articleID, event.ArticleID)
+			list = append(list, event.Data.(AnalysisStatusData).Status)
+		}
+		return list
+	}
+	assert.Equal(t, []string{"pending", "processing", "completed"}, statuses(byAuthor, watched.ID))
+	assert.Equal(t, []string{"pending", "processing", "failed"}, statuses(byArticle, other.ID))
+}
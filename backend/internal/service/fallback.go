@@ -0,0 +1,73 @@
+package service
+
+import (
+	"time"
+
+	"article-analysis/internal/config"
+	"article-analysis/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// llmBackend 降级链中的一个模型服务及其熔断器
+type llmBackend struct {
+	profile  config.ModelProfile
+	provider LLMProvider
+	breaker  *circuitBreaker
+}
+
+func newLLMBackend(cfg *config.Config, profile config.ModelProfile, log *logger.Logger) *llmBackend {
+	provider, err := NewProvider(profile)
+	if err != nil {
+		log.Error("模型服务配置错误，使用OpenAI兼容接口", err, zap.String("profile", profile.Name))
+		provider = newOpenAIProvider(profile.APIKey, profile.APIBase)
+	}
+	mode, ok := normalizeJSONMode(profile.JSONMode)
+	if !ok {
+		log.Warn("不支持的结构化输出方式，使用 auto", zap.String("profile", profile.Name), zap.String("json_mode", profile.JSONMode))
+	}
+	profile.JSONMode = mode
+	return &llmBackend{profile: profile, provider: provider, breaker: newBreakerFromConfig(cfg)}
+}
+
+func newBreakerFromConfig(cfg *config.Config) *circuitBreaker {
+	breakerCfg := cfg.OpenAI.CircuitBreaker
+	return newCircuitBreaker(breakerCfg.FailureThreshold, time.Duration(breakerCfg.OpenDuration)*time.Second)
+}
+
+// backendRegistry 按模型配置名索引的模型服务
+//
+// 同一模型配置无论作为主服务还是降级服务都使用同一个实例，熔断状态在所有分析器之间共享。
+type backendRegistry struct {
+	cfg      *config.Config
+	backends map[string]*llmBackend
+	log      *logger.Logger
+}
+
+func newBackendRegistry(cfg *config.Config, log *logger.Logger) *backendRegistry {
+	r := &backendRegistry{cfg: cfg, backends: make(map[string]*llmBackend), log: log}
+	for _, profile := range cfg.OpenAI.ResolvedProfiles() {
+		r.backends[profile.Name] = newLLMBackend(cfg, profile, log)
+	}
+	return r
+}
+
+// chain 返回模型配置的完整调用链，第一个为该配置本身
+func (r *backendRegistry) chain(name string) []*llmBackend {
+	chain := []*llmBackend{r.backends[name]}
+	return append(chain, r.fallbacks(name)...)
+}
+
+// fallbacks 返回模型配置的降级服务，忽略不存在的配置名
+func (r *backendRegistry) fallbacks(name string) []*llmBackend {
+	var fallbacks []*llmBackend
+	for _, fallback := range r.cfg.OpenAI.FallbackChain(name)[1:] {
+		backend, ok := r.backends[fallback]
+		if !ok {
+			r.log.Warn("降级模型配置不存在", zap.String("profile", name), zap.String("fallback", fallback))
+			continue
+		}
+		fallbacks = append(fallbacks, backend)
+	}
+	return fallbacks
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"article-analysis/internal/config"
+	"article-analysis/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// outageServer 模拟可随时宕机的OpenAI兼容服务
+type outageServer struct {
+	*httptest.Server
+	down  atomic.Bool
+	calls atomic.Int32
+}
+
+func newOutageServer(t *testing.T) *outageServer {
+	s := &outageServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.calls.Add(1)
+		if s.down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"error":{"message":"service unavailable","type":"server_error"}}`)
+			return
+		}
+		writeChatCompletion(w, testAnalysisJSON)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newFallbackTestClient(primaryURL, backupURL string, threshold int) *OpenAIClient {
+	cfg := &config.Config{
+		OpenAI: config.OpenAIConfig{
+			APIKey:         "test-api-key",
+			RetryBaseDelay: 1,
+			RetryMaxDelay:  10,
+			Profiles: []config.ModelProfile{
+				{Name: "primary", APIBase: primaryURL, Model: "primary-model"},
+				{Name: "backup", APIBase: backupURL, Model: "backup-model"},
+			},
+			Fallbacks:      []string{"backup"},
+			CircuitBreaker: config.CircuitBreakerConfig{FailureThreshold: threshold, OpenDuration: 60},
+		},
+	}
+	return NewOpenAIClientForProfile(cfg, cfg.OpenAI.ResolvedProfiles()[0], logger.NewLogger("error"))
+}
+
+func TestOpenAIClient_FallsBackWhenPrimaryDown(t *testing.T) {
+	primary, backup := newOutageServer(t), newOutageServer(t)
+	primary.down.Store(true)
+
+	client := newFallbackTestClient(primary.URL, backup.URL, 5)
+
+	var attempts []LLMAttempt
+	ctx := WithAttemptObserver(context.Background(), func(a LLMAttempt) {
+		attempts = append(attempts, a)
+	})
+
+	result, err := client.AnalyzeArticle(ctx, "测试文章")
+	require.NoError(t, err)
+	assert.Equal(t, "观点", result.CoreViewpoints)
+	assert.Equal(t, "backup", result.Profile)
+	assert.Equal(t, "test", result.Model, "以服务端返回的模型名为准")
+	assert.Equal(t, ProviderOpenAI, result.Provider)
+
+	require.Len(t, attempts, 2)
+	assert.Equal(t, "primary", attempts[0].Profile)
+	assert.Equal(t, http.StatusServiceUnavailable, attempts[0].StatusCode)
+	assert.Equal(t, "backup", attempts[1].Profile)
+	assert.NoError(t, attempts[1].Err)
+}
+
+func TestOpenAIClient_CircuitBreakerSkipsAndProbes(t *testing.T) {
+	primary, backup := newOutageServer(t), newOutageServer(t)
+	primary.down.Store(true)
+
+	client := newFallbackTestClient(primary.URL, backup.URL, 2)
+	breaker := client.backends[0].breaker
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		_, err := client.AnalyzeArticle(context.Background(), "测试文章")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), primary.calls.Load())
+	assert.Equal(t, circuitOpen, breaker.currentState())
+
+	// 熔断期间不再请求主服务
+	result, err := client.AnalyzeArticle(context.Background(), "测试文章")
+	require.NoError(t, err)
+	assert.Equal(t, "backup", result.Profile)
+	assert.Equal(t, int32(2), primary.calls.Load())
+
+	// 熔断到期后放行探测请求，主服务恢复即闭合
+	primary.down.Store(false)
+	now = now.Add(61 * time.Second)
+	result, err = client.AnalyzeArticle(context.Background(), "测试文章")
+	require.NoError(t, err)
+	assert.Equal(t, "primary", result.Profile)
+	assert.Equal(t, int32(3), primary.calls.Load())
+	assert.Equal(t, circuitClosed, breaker.currentState())
+}
+
+func TestOpenAIClient_AllBackendsDown(t *testing.T) {
+	primary, backup := newOutageServer(t), newOutageServer(t)
+	primary.down.Store(true)
+	backup.down.Store(true)
+
+	client := newFallbackTestClient(primary.URL, backup.URL, 1)
+
+	_, err := client.AnalyzeArticle(context.Background(), "测试文章")
+	require.Error(t, err)
+
+	// 两个服务都已熔断，不再发出请求
+	_, err = client.AnalyzeArticle(context.Background(), "测试文章")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(1), primary.calls.Load())
+	assert.Equal(t, int32(1), backup.calls.Load())
+}
+
+func TestAnalysisService_AnalyzersShareCircuitBreakers(t *testing.T) {
+	primary, backup := newOutageServer(t), newOutageServer(t)
+	primary.down.Store(true)
+
+	cfg := &config.Config{
+		OpenAI: config.OpenAIConfig{
+			APIKey:         "test-api-key",
+			RetryBaseDelay: 1,
+			RetryMaxDelay:  10,
+			Profiles: []config.ModelProfile{
+				{Name: "primary", APIBase: primary.URL, Model: "primary-model"},
+				{Name: "backup", APIBase: backup.URL, Model: "backup-model", Fallbacks: []string{"primary"}},
+			},
+			Fallbacks:      []string{"backup"},
+			CircuitBreaker: config.CircuitBreakerConfig{FailureThreshold: 1, OpenDuration: 60},
+		},
+	}
+	s := NewAnalysisService(nil, nil, nil, cfg, logger.NewLogger("error"))
+	primaryClient := s.analyzers["primary"].(*OpenAIClient)
+	backupClient := s.analyzers["backup"].(*OpenAIClient)
+	require.Same(t, primaryClient.backends[0], backupClient.backends[1], "同一模型配置只有一个实例")
+
+	_, err := primaryClient.AnalyzeArticle(context.Background(), "测试文章")
+	require.NoError(t, err)
+	assert.Equal(t, circuitOpen, backupClient.backends[1].breaker.currentState(), "作为降级服务时看到同一熔断状态")
+
+	// 备用服务宕机时不再降级到已熔断的主服务
+	backup.down.Store(true)
+	_, err = backupClient.AnalyzeArticle(context.Background(), "测试文章")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, int32(1), primary.calls.Load())
+}
+
+func TestCircuitBreaker_HalfOpenFailureReopens(t *testing.T) {
+	breaker := newCircuitBreaker(1, time.Minute)
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+
+	unavailable := &ProviderError{StatusCode: http.StatusBadGateway}
+	require.True(t, breaker.allow())
+	breaker.record(unavailable)
+	assert.False(t, breaker.allow())
+
+	now = now.Add(time.Minute)
+	assert.True(t, breaker.allow(), "到期后放行探测请求")
+	assert.False(t, breaker.allow(), "探测期间只放行一个请求")
+	breaker.record(unavailable)
+	assert.Equal(t, circuitOpen, breaker.currentState())
+
+	// 鉴权失败说明服务可达，不计入失败
+	now = now.Add(time.Minute)
+	require.True(t, breaker.allow())
+	breaker.record(&ProviderError{StatusCode: http.StatusUnauthorized})
+	assert.Equal(t, circuitClosed, breaker.currentState())
+
+	// 调用方取消不改变状态
+	breaker.record(fmt.Errorf("请求中断: %w", context.Canceled))
+	assert.Equal(t, circuitClosed, breaker.currentState())
+}
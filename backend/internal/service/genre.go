@@ -0,0 +1,199 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+// 文章体裁
+const (
+	GenreArgumentative = "argumentative" // 议论文
+	GenreNarrative     = "narrative"     // 记叙文、散文
+	GenreNews          = "news"          // 新闻报道
+	GenreTechnical     = "technical"     // 科技论文、说明文
+	GenrePoetry        = "poetry"        // 诗歌
+	GenreGeneral       = "general"       // 无法判断时使用默认提示词
+)
+
+// 体裁的来源
+const (
+	GenreSourceAuto = "auto" // 提交时自动识别
+	GenreSourceUser = "user" // 提交时指定
+)
+
+var ErrUnknownGenre = errors.New("文章体裁不存在")
+
+// genres 可选的体裁，按展示顺序排列
+var genres = []struct {
+	Name  string
+	Label string
+}{
+	{GenreArgumentative, "议论文"},
+	{GenreNarrative, "记叙文"},
+	{GenreNews, "新闻报道"},
+	{GenreTechnical, "科技论文"},
+	{GenrePoetry, "诗歌"},
+	{GenreGeneral, "通用"},
+}
+
+// builtinGenrePrompts 各体裁默认使用的内置提示词，未列出的体裁使用默认提示词
+var builtinGenrePrompts = map[string]string{
+	GenreNarrative: "narrative-v1",
+	GenreNews:      "news-v1",
+	GenreTechnical: "technical-v1",
+	GenrePoetry:    "poetry-v1",
+}
+
+func isKnownGenre(genre string) bool {
+	for _, g := range genres {
+		if g.Name == genre {
+			return true
+		}
+	}
+	return false
+}
+
+// validateGenre 检查提交时指定的体裁，为空表示自动识别
+func validateGenre(genre string) error {
+	if genre != "" && !isKnownGenre(genre) {
+		return fmt.Errorf("%w: %s", ErrUnknownGenre, genre)
+	}
+	return nil
+}
+
+// genreRule 体裁识别规则：每个关键词或模式出现一次得 weight 分
+type genreRule struct {
+	genre    string
+	keywords []string
+	patterns []*regexp.Regexp
+	weight   float64
+}
+
+var genreRules = []genreRule{
+	{genre: GenreTechnical, weight: 3, keywords: []string{"摘要", "关键词", "参考文献", "Abstract", "本文提出"}},
+	{genre: GenreTechnical, weight: 1, keywords: []string{"实验", "算法", "数据", "模型", "结果表明", "研究表明", "方法"}},
+	{genre: GenreNews, weight: 3, keywords: []string{"记者", "新华社", "据悉", "日电", "日讯", "通讯员"},
+		patterns: []*regexp.Regexp{regexp.MustCompile(`（记者[^）]*）`)}},
+	{genre: GenreNews, weight: 1, keywords: []string{"报道", "发布会", "截至", "消息", "表示"},
+		patterns: []*regexp.Regexp{regexp.MustCompile(`\d{1,2}月\d{1,2}日`)}},
+	{genre: GenreArgumentative, weight: 2, keywords: []string{"论点", "论证", "由此可见", "综上所述", "我认为", "总而言之"}},
+	{genre: GenreArgumentative, weight: 1, keywords: []string{"因此", "所以", "总之", "应该", "必须", "难道", "不是", "而是"}},
+	{genre: GenreNarrative, weight: 2, keywords: []string{"那天", "那年", "记得", "后来", "小时候"}},
+	{genre: GenreNarrative, weight: 1, keywords: []string{"“", "我们", "他说", "她说", "笑着"}},
+}
+
+// minGenreScore 得分最高的体裁低于该值时视为无法判断
+const minGenreScore = 3
+
+// classifyGenre 按规则识别文章体裁：分行短句为主的短文视为诗歌，
+// 其余按各体裁特征词的出现次数计分，得分最高者胜出
+func classifyGenre(content string) string {
+	if isPoem(content) {
+		return GenrePoetry
+	}
+
+	scores := make(map[string]float64)
+	for _, rule := range genreRules {
+		for _, keyword := range rule.keywords {
+			scores[rule.genre] += rule.weight * float64(strings.Count(content, keyword))
+		}
+		for _, pattern := range rule.patterns {
+			scores[rule.genre] += rule.weight * float64(len(pattern.FindAllStringIndex(content, -1)))
+		}
+	}
+
+	best, bestScore := GenreGeneral, 0.0
+	for _, g := range genres {
+		if score := scores[g.Name]; score > bestScore {
+			best, bestScore = g.Name, score
+		}
+	}
+	if bestScore < minGenreScore {
+		return GenreGeneral
+	}
+	return best
+}
+
+// isPoem 判断文章是否为诗歌：不少于四行、总长不超过 1500 字，且八成以上的行不超过 20 字
+func isPoem(content string) bool {
+	if utf8.RuneCountInString(content) > 1500 {
+		return false
+	}
+	lines, short := 0, 0
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		lines++
+		if utf8.RuneCountInString(line) <= 20 {
+			short++
+		}
+	}
+	return lines >= 4 && short*5 >= lines*4
+}
+
+// GenreInfo 对外展示的体裁及其使用的提示词版本
+type GenreInfo struct {
+	Genre         string `json:"genre"`
+	Label         string `json:"label"`
+	PromptVersion string `json:"prompt_version"`
+}
+
+// ListGenres 列出可选的体裁
+func (s *AnalysisService) ListGenres() []GenreInfo {
+	list := make([]GenreInfo, 0, len(genres))
+	for _, g := range genres {
+		list = append(list, GenreInfo{Genre: g.Name, Label: g.Label, PromptVersion: s.genrePrompt(g.Name).Version})
+	}
+	return list
+}
+
+// initGenrePrompts 合并内置和配置的体裁提示词，引用不存在的提示词版本时忽略该项
+func (s *AnalysisService) initGenrePrompts(configured map[string]string) {
+	s.genrePrompts = make(map[string]string)
+	for genre, version := range builtinGenrePrompts {
+		s.genrePrompts[genre] = version
+	}
+	for genre, version := range configured {
+		if !isKnownGenre(genre) {
+			s.log.Warn("体裁提示词配置中的体裁不存在，已忽略", zap.String("genre", genre))
+			continue
+		}
+		if _, err := s.prompts.Get(version); err != nil {
+			s.log.Warn("体裁提示词配置中的提示词版本不存在，已忽略",
+				zap.String("genre", genre), zap.String("prompt_version", version))
+			continue
+		}
+		s.genrePrompts[genre] = version
+	}
+}
+
+// genrePrompt 返回体裁使用的提示词，未单独配置时使用默认提示词
+func (s *AnalysisService) genrePrompt(genre string) *PromptTemplate {
+	if version, ok := s.genrePrompts[genre]; ok {
+		if prompt, err := s.prompts.Get(version); err == nil {
+			return prompt
+		}
+	}
+	return s.prompts.Default()
+}
+
+// choosePrompt 确定文章的体裁及分析使用的提示词：未指定体裁时按规则识别，
+// 指定了提示词版本时优先使用，否则使用体裁对应的提示词
+func (s *AnalysisService) choosePrompt(content string, opts AnalyzeOptions) (genre, source string, prompt *PromptTemplate, err error) {
+	genre, source = opts.Genre, GenreSourceUser
+	if genre == "" {
+		genre, source = classifyGenre(content), GenreSourceAuto
+	}
+	if opts.Prompt != "" {
+		prompt, err = s.prompts.Get(opts.Prompt)
+		return genre, source, prompt, err
+	}
+	return genre, source, s.genrePrompt(genre), nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"article-analysis/internal/model"
+	"article-analysis/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testPoem = "静夜思\n床前明月光，\n疑是地上霜。\n举头望明月，\n低头思故乡。"
+
+func TestClassifyGenre(t *testing.T) {
+	cases := map[string]string{
+		GenrePoetry: testPoem,
+		GenreNews: "本报讯（记者 王五）3月15日，市教育局召开新闻发布会。据悉，截至目前全市已有120所学校完成改造。" +
+			"负责人表示，下一步将继续推进。",
+		GenreTechnical: "摘要：本文提出一种基于注意力机制的文本分类算法。关键词：文本分类；注意力机制\n" +
+			"实验结果表明，该方法在三个数据集上均优于基线模型。\n参考文献\n[1] 张三. 文本分类综述.",
+		GenreArgumentative: "我认为，读书是成长的阶梯。有人说读书无用，难道真是如此吗？古往今来，成大事者无不勤于读书。" +
+			"由此可见，读书不是可有可无的消遣，而是人生的必修课。因此，我们应该多读书、读好书。",
+		GenreNarrative: "记得那年夏天，我和外婆坐在院子里乘凉。外婆笑着说：“你小时候最怕打雷了。”" +
+			"后来我去城里上学，再也没有那样的夏夜。那天离家时，外婆站在村口，一直没有回去。",
+		GenreGeneral: "今天天气很好。",
+	}
+	for want, content := range cases {
+		assert.Equal(t, want, classifyGenre(content), content)
+	}
+
+	// 分行但句子较长的散文不算诗歌
+	prose := strings.Repeat("这是一段很长很长的句子，用来说明分行的散文并不因为分行就成为诗歌。\n", 5)
+	assert.NotEqual(t, GenrePoetry, classifyGenre(prose))
+}
+
+func TestAnalysisService_ChoosesPromptByGenre(t *testing.T) {
+	analyzer := &MockOpenAIClient{}
+	s, taskRepo, db := newTestAnalysisServiceWithAnalyzer(t, nil, analyzer)
+
+	article := &model.Article{Title: "静夜思", Author: "李白", Content: testPoem, FilePath: "f.txt"}
+	require.NoError(t, repository.NewArticleRepository(db).Create(article))
+
+	var versions []string
+	analyzer.On("AnalyzeArticle", mock.Anything, testPoem).Run(func(args mock.Arguments) {
+		versions = append(versions, promptFromContext(args.Get(0).(context.Context)).Version)
+	}).Return(&AnalysisResponse{CoreViewpoints: "思乡"}, nil)
+
+	_, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{Genre: "drama"})
+	require.ErrorIs(t, err, ErrUnknownGenre)
+
+	cases := []struct {
+		opts        AnalyzeOptions
+		genre       string
+		genreSource string
+		prompt      string
+	}{
+		{AnalyzeOptions{}, GenrePoetry, GenreSourceAuto, "poetry-v1"},
+		{AnalyzeOptions{Genre: GenreArgumentative}, GenreArgumentative, GenreSourceUser, builtinPromptVersion},
+		{AnalyzeOptions{Genre: GenreNews, Prompt: "v2"}, GenreNews, GenreSourceUser, "v2"},
+	}
+	for _, c := range cases {
+		submitted, err := s.AnalyzeArticle(article.ID, c.opts)
+		require.NoError(t, err)
+		task := runUntilFinished(t, s, taskRepo, submitted.TaskID)
+		require.Equal(t, model.TaskStatusCompleted, task.Status, task.ErrorMessage)
+		assert.Equal(t, c.genre, task.Genre)
+		assert.Equal(t, c.genreSource, task.GenreSource)
+		assert.Equal(t, c.prompt, task.PromptVersion, "指定的提示词版本优先于体裁对应的提示词")
+
+		analysis, err := s.GetAnalysisResult(article.ID)
+		require.NoError(t, err)
+		assert.Equal(t, c.genre, analysis.Genre)
+		assert.Equal(t, c.genreSource, analysis.GenreSource)
+	}
+	assert.Equal(t, []string{"poetry-v1", builtinPromptVersion, "v2"}, versions)
+
+	genres := s.ListGenres()
+	require.Len(t, genres, 6)
+	assert.Equal(t, GenreArgumentative, genres[0].Genre)
+	assert.Equal(t, builtinPromptVersion, genres[0].PromptVersion)
+}
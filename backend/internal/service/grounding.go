@@ -0,0 +1,199 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"article-analysis/internal/model"
+)
+
+// groundingThreshold 引文与原文片段的相似度达到该值才视为在原文中找到
+//
+// 相似度按忽略空白和标点后的编辑距离计算，允许模型引用时改动个别字词，
+// 但整句改写或编造的引文会低于该值。
+const groundingThreshold = 0.8
+
+// minGroundingRunes 引文去掉空白和标点后至少要有的字符数，过短的引文在原文中随处可能出现，无法据此核实
+const minGroundingRunes = 6
+
+// matchText 用于比对的文本：去掉空白和标点并统一大小写、全角字符，
+// offsets 记录每个字符在原文中的位置（按字符计）
+type matchText struct {
+	runes   []rune
+	offsets []int
+}
+
+func newMatchText(s string) matchText {
+	var t matchText
+	i := 0
+	for _, r := range s {
+		if !unicode.IsSpace(r) && !unicode.IsPunct(r) && !unicode.IsSymbol(r) {
+			// 全角字母数字转换为半角
+			if r >= 0xFF01 && r <= 0xFF5E {
+				r -= 0xFEE0
+			}
+			t.runes = append(t.runes, unicode.ToLower(r))
+			t.offsets = append(t.offsets, i)
+		}
+		i++
+	}
+	return t
+}
+
+// locate 在文本中查找与引文最接近的片段，返回片段在比对文本中的范围和相似度
+func (t matchText) locate(quote []rune) (start, end int, score float64) {
+	if len(quote) == 0 || len(t.runes) == 0 {
+		return 0, 0, 0
+	}
+	text := string(t.runes)
+	if idx := strings.Index(text, string(quote)); idx >= 0 {
+		start = utf8.RuneCountInString(text[:idx])
+		return start, start + len(quote), 1
+	}
+
+	// 近似子串匹配：引文必须整体匹配，原文片段的起点和终点任意。
+	// dist[i] 为引文前 i 个字符与以当前位置结尾的某个原文片段的最小编辑距离，from[i] 为该片段起点
+	m := len(quote)
+	dist := make([]int, m+1)
+	from := make([]int, m+1)
+	prevDist := make([]int, m+1)
+	prevFrom := make([]int, m+1)
+	for i := range prevDist {
+		prevDist[i] = i
+	}
+	best := m + 1
+	for j, r := range t.runes {
+		dist[0], from[0] = 0, j+1
+		for i := 1; i <= m; i++ {
+			cost := 1
+			if quote[i-1] == r {
+				cost = 0
+			}
+			dist[i], from[i] = prevDist[i-1]+cost, prevFrom[i-1]
+			if prevDist[i]+1 < dist[i] {
+				dist[i], from[i] = prevDist[i]+1, prevFrom[i]
+			}
+			if dist[i-1]+1 < dist[i] {
+				dist[i], from[i] = dist[i-1]+1, from[i-1]
+			}
+		}
+		if dist[m] < best {
+			best, start, end = dist[m], from[m], j+1
+		}
+		dist, prevDist = prevDist, dist
+		from, prevFrom = prevFrom, from
+	}
+	if start >= end {
+		return 0, 0, 0
+	}
+	return start, end, 1 - float64(best)/float64(m)
+}
+
+// grounder 在文章内容中定位引文
+type grounder struct {
+	text       matchText
+	content    []rune
+	paragraphs []int
+}
+
+func newGrounder(content string) *grounder {
+	runes := []rune(content)
+	return &grounder{text: newMatchText(content), content: runes, paragraphs: paragraphStarts(runes)}
+}
+
+// ground 填写每条要点的引文位置并标记能否核实，返回能核实的要点数
+func (g *grounder) ground(items []model.AnalysisItem) (verified int) {
+	for i := range items {
+		item := &items[i]
+		item.Evidence, item.Verified = nil, false
+
+		quote := newMatchText(item.Quote).runes
+		if len(quote) < minGroundingRunes {
+			continue
+		}
+		start, end, score := g.text.locate(quote)
+		if score < groundingThreshold {
+			continue
+		}
+		begin, finish := g.text.offsets[start], g.text.offsets[end-1]+1
+		item.Evidence = &model.Evidence{
+			Start:     begin,
+			End:       finish,
+			Paragraph: paragraphOf(g.paragraphs, begin),
+			Text:      string(g.content[begin:finish]),
+			Score:     score,
+		}
+		item.Verified = true
+		verified++
+	}
+	return verified
+}
+
+// groundResult 在文章内容中定位每条要点的引文，填写引文位置并标记能否核实，
+// 返回能核实的要点数和要点总数
+func groundResult(content string, result *model.AnalysisResult) (verified, total int) {
+	if result == nil {
+		return 0, 0
+	}
+	g := newGrounder(content)
+	for _, items := range [][]model.AnalysisItem{
+		result.CoreViewpoints, result.FileStructure, result.AuthorThoughts, result.RelatedMaterials,
+	} {
+		verified += g.ground(items)
+		total += len(items)
+	}
+	return verified, total
+}
+
+// groundFields 对自定义分析方案中要点列表类型的维度定位引文，返回能核实的要点数和要点总数
+func groundFields(content string, schema *model.AnalysisSchema, fields model.AnalysisFields) (verified, total int) {
+	if len(fields) == 0 {
+		return 0, 0
+	}
+	g := newGrounder(content)
+	for _, field := range schema.Fields {
+		if field.Type != model.FieldTypeItems || fields[field.Key] == nil {
+			continue
+		}
+		var items []model.AnalysisItem
+		if err := json.Unmarshal(fields[field.Key], &items); err != nil {
+			continue
+		}
+		verified += g.ground(items)
+		total += len(items)
+		if data, err := json.Marshal(items); err == nil {
+			fields[field.Key] = data
+		}
+	}
+	return verified, total
+}
+
+// paragraphStarts 返回每个非空段落的起始位置，段落按换行划分
+func paragraphStarts(content []rune) []int {
+	var starts []int
+	lineStart := 0
+	for i := 0; i <= len(content); i++ {
+		if i < len(content) && content[i] != '\n' {
+			continue
+		}
+		if strings.TrimSpace(string(content[lineStart:i])) != "" {
+			starts = append(starts, lineStart)
+		}
+		lineStart = i + 1
+	}
+	return starts
+}
+
+// paragraphOf 返回位置所在的段落序号，从 1 开始
+func paragraphOf(starts []int, offset int) int {
+	paragraph := 0
+	for i, start := range starts {
+		if start > offset {
+			break
+		}
+		paragraph = i + 1
+	}
+	return paragraph
+}
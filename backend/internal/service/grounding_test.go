@@ -0,0 +1,54 @@
+package service
+
+import (
+	"testing"
+
+	"article-analysis/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroundResult(t *testing.T) {
+	content := "标题\n\n第一段讲述了背景。\n教育的根本目的，是培养独立思考的人。\n最后一段总结全文。"
+	result := &model.AnalysisResult{
+		CoreViewpoints: []model.AnalysisItem{
+			// 标点和空白不同仍视为原文
+			{Title: "精确", Quote: "教育的根本目的 是培养独立思考的人"},
+			// 个别字不同
+			{Title: "近似", Quote: "教育的根本目标，是培养独立思考的人。"},
+		},
+		RelatedMaterials: []model.AnalysisItem{
+			{Title: "编造", Quote: "人工智能将彻底取代教师的工作"},
+			{Title: "无引文"},
+			// 原文中出现过，但过短无法说明出处
+			{Title: "过短", Quote: "“教育”"},
+			{Title: "五字", Quote: "最后一段总"},
+		},
+	}
+
+	verified, total := groundResult(content, result)
+	assert.Equal(t, 2, verified)
+	assert.Equal(t, 6, total)
+
+	exact := result.CoreViewpoints[0]
+	require.True(t, exact.Verified)
+	require.NotNil(t, exact.Evidence)
+	assert.Equal(t, 1.0, exact.Evidence.Score)
+	assert.Equal(t, 3, exact.Evidence.Paragraph)
+	assert.Equal(t, "教育的根本目的，是培养独立思考的人", exact.Evidence.Text)
+	assert.Equal(t, exact.Evidence.Text, string([]rune(content)[exact.Evidence.Start:exact.Evidence.End]),
+		"偏移量按字符计")
+
+	fuzzy := result.CoreViewpoints[1]
+	require.True(t, fuzzy.Verified)
+	assert.Equal(t, exact.Evidence.Start, fuzzy.Evidence.Start)
+	assert.Equal(t, exact.Evidence.End, fuzzy.Evidence.End)
+	assert.Less(t, fuzzy.Evidence.Score, 1.0)
+	assert.GreaterOrEqual(t, fuzzy.Evidence.Score, groundingThreshold)
+
+	for _, item := range result.RelatedMaterials {
+		assert.False(t, item.Verified, item.Title)
+		assert.Nil(t, item.Evidence, item.Title)
+	}
+}
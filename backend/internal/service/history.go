@@ -0,0 +1,61 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"article-analysis/internal/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAnalysisNotFound     = errors.New("分析记录不存在")
+	ErrAnalysisNotCompleted = errors.New("分析尚未完成，不能设为当前结果")
+)
+
+// ListAnalyses 按时间倒序列出文章的全部分析记录
+func (s *AnalysisService) ListAnalyses(articleID uint64) ([]model.ArticleAnalysis, error) {
+	if _, err := s.articleRepo.GetByID(articleID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrArticleNotFound
+		}
+		return nil, fmt.Errorf("查询文章失败: %w", err)
+	}
+	analyses, err := s.analysisRepo.ListByArticleID(articleID)
+	if err != nil {
+		return nil, fmt.Errorf("查询分析记录失败: %w", err)
+	}
+	return analyses, nil
+}
+
+// GetAnalysis 获取文章的某一次分析
+func (s *AnalysisService) GetAnalysis(articleID, analysisID uint64) (*model.ArticleAnalysis, error) {
+	analysis, err := s.analysisRepo.GetByID(analysisID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAnalysisNotFound
+		}
+		return nil, fmt.Errorf("查询分析记录失败: %w", err)
+	}
+	if analysis.ArticleID != articleID {
+		return nil, ErrAnalysisNotFound
+	}
+	return analysis, nil
+}
+
+// SetCurrentAnalysis 将文章已完成的某次分析设为当前结果，如回退到之前的模型给出的结果
+func (s *AnalysisService) SetCurrentAnalysis(articleID, analysisID uint64) (*model.ArticleAnalysis, error) {
+	analysis, err := s.GetAnalysis(articleID, analysisID)
+	if err != nil {
+		return nil, err
+	}
+	if analysis.AnalysisStatus != "completed" {
+		return nil, ErrAnalysisNotCompleted
+	}
+	if err := s.analysisRepo.SetCurrent(articleID, analysisID); err != nil {
+		return nil, fmt.Errorf("更新当前分析结果失败: %w", err)
+	}
+	analysis.IsCurrent = true
+	return analysis, nil
+}
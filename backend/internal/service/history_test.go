@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"article-analysis/internal/model"
+	"article-analysis/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalysisService_KeepsAnalysisHistory(t *testing.T) {
+	analyzer := &MockOpenAIClient{}
+	s, taskRepo, db := newTestAnalysisServiceWithAnalyzer(t, nil, analyzer)
+
+	article := &model.Article{Title: "文章", Author: "张三", Content: "测试文章内容", FilePath: "f.txt"}
+	require.NoError(t, repository.NewArticleRepository(db).Create(article))
+
+	analyzer.On("AnalyzeArticle", mock.Anything, "测试文章内容").Return(&AnalysisResponse{CoreViewpoints: "第一次", Model: "old-model"}, nil).Once()
+	analyzer.On("AnalyzeArticle", mock.Anything, "测试文章内容").Return(&AnalysisResponse{CoreViewpoints: "第二次", Model: "new-model"}, nil).Once()
+	analyzer.On("AnalyzeArticle", mock.Anything, "测试文章内容").Return(nil, errors.New("模型不可用")).Once()
+
+	statuses := []string{model.TaskStatusCompleted, model.TaskStatusCompleted, model.TaskStatusFailed}
+	for _, want := range statuses {
+		submitted, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{})
+		require.NoError(t, err)
+		task := runUntilFinished(t, s, taskRepo, submitted.TaskID)
+		require.Equal(t, want, task.Status)
+	}
+
+	analyses, err := s.ListAnalyses(article.ID)
+	require.NoError(t, err)
+	require.Len(t, analyses, 3)
+	assert.Equal(t, []int{3, 2, 1}, []int{analyses[0].Version, analyses[1].Version, analyses[2].Version})
+	assert.Equal(t, "failed", analyses[0].AnalysisStatus)
+	assert.Equal(t, "第二次", analyses[1].CoreViewpoints)
+	assert.Equal(t, "new-model", analyses[1].Model)
+	assert.Equal(t, "第一次", analyses[2].CoreViewpoints, "重新分析不覆盖之前的结果")
+	assert.Equal(t, []bool{false, true, false}, []bool{analyses[0].IsCurrent, analyses[1].IsCurrent, analyses[2].IsCurrent},
+		"失败的分析不影响当前结果")
+	_, err = s.ListAnalyses(article.ID + 1)
+	assert.ErrorIs(t, err, ErrArticleNotFound)
+
+	current, err := s.GetAnalysisResult(article.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "第二次", current.CoreViewpoints)
+
+	list, err := repository.NewArticleRepository(db).GetListWithAnalysis(&model.PaginationRequest{Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	rows := list.List.([]repository.ArticleWithAnalysis)
+	require.Len(t, rows, 1)
+	assert.Equal(t, "completed", rows[0].AnalysisStatus, "文章列表展示当前结果的状态，而非最新一次分析")
+
+	_, err = s.SetCurrentAnalysis(article.ID, analyses[0].ID)
+	assert.ErrorIs(t, err, ErrAnalysisNotCompleted)
+	_, err = s.SetCurrentAnalysis(article.ID+1, analyses[2].ID)
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+
+	_, err = s.SetCurrentAnalysis(article.ID, analyses[2].ID)
+	require.NoError(t, err)
+	current, err = s.GetAnalysisResult(article.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "第一次", current.CoreViewpoints)
+	assert.Equal(t, "old-model", current.Model)
+	analyzer.AssertExpectations(t)
+}
+
+func TestAnalysisService_MigratesLegacyAnalyses(t *testing.T) {
+	s, _, db := newTestAnalysisService(t)
+	analysisRepo := repository.NewAnalysisRepository(db)
+
+	legacy := []*model.ArticleAnalysis{
+		{ArticleID: 1, AnalysisStatus: "completed", CoreViewpoints: "早期结果"},
+		{ArticleID: 2, AnalysisStatus: "failed"},
+	}
+	for _, analysis := range legacy {
+		require.NoError(t, analysisRepo.Create(analysis))
+	}
+	require.NoError(t, s.RecoverOnStartup())
+
+	for _, analysis := range legacy {
+		stored, err := analysisRepo.GetByID(analysis.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stored.Version)
+		assert.Equal(t, analysis.AnalysisStatus == "completed", stored.IsCurrent)
+	}
+	next, err := analysisRepo.NextVersion(1)
+	require.NoError(t, err)
+	assert.Equal(t, 2, next)
+}
@@ -0,0 +1,15 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// newOpaqueID 生成不含业务信息的随机ID，用于对外暴露的任务ID等
+func newOpaqueID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"article-analysis/internal/model"
+)
+
+// ChunkObserver 接收长文分段分析的进度，done 为已完成的模型调用次数，total 为预计的总次数
+type ChunkObserver func(done, total int)
+
+type chunkObserverKey struct{}
+
+// WithChunkObserver 返回携带分段进度观察者的上下文
+func WithChunkObserver(ctx context.Context, observer ChunkObserver) context.Context {
+	return context.WithValue(ctx, chunkObserverKey{}, observer)
+}
+
+func notifyChunk(ctx context.Context, done, total int) {
+	if observer, ok := ctx.Value(chunkObserverKey{}).(ChunkObserver); ok && observer != nil {
+		observer(done, total)
+	}
+}
+
+// chunkResult 一段或相邻若干段内容的分析结果
+type chunkResult struct {
+	first, last int // 覆盖的分段序号，从1开始
+	result      *AnalysisResponse
+}
+
+func (r chunkResult) label() string {
+	if r.first == r.last {
+		return fmt.Sprintf("第%d部分", r.first)
+	}
+	return fmt.Sprintf("第%d-%d部分", r.first, r.last)
+}
+
+// analyzeInChunks 长文分段分析：逐段分析（map）后合并为全文结果（reduce）
+//
+// 各段结果合在一起仍超过分段上限时，先按顺序分组合并，再对分组结果继续合并，直到只剩一组。
+func (c *OpenAIClient) analyzeInChunks(ctx context.Context, prompt *PromptTemplate, schema *model.AnalysisSchema, chunks []string) (*AnalysisResponse, error) {
+	if len(chunks) == 1 {
+		text, err := prompt.analysisPrompt(schema, chunks[0])
+		if err != nil {
+			return nil, err
+		}
+		return c.complete(ctx, prompt, schema, text)
+	}
+
+	done, total := 0, len(chunks)+1
+	partials := make([]chunkResult, 0, len(chunks))
+	for i, chunk := range chunks {
+		text, err := prompt.chunkPrompt(schema, chunk, i+1, len(chunks))
+		if err != nil {
+			return nil, err
+		}
+		result, err := c.complete(ctx, prompt, schema, text)
+		if err != nil {
+			return nil, fmt.Errorf("第%d/%d部分分析失败: %w", i+1, len(chunks), err)
+		}
+		partials = append(partials, chunkResult{first: i + 1, last: i + 1, result: result})
+		done++
+		notifyChunk(ctx, done, total)
+	}
+
+	limit := c.chunkTokens()
+	for {
+		groups := groupChunkResults(schema, partials, limit)
+		if len(groups) == 1 {
+			text, err := prompt.reducePrompt(schema, groups[0], len(chunks))
+			if err != nil {
+				return nil, err
+			}
+			result, err := c.complete(ctx, prompt, schema, text)
+			if err != nil {
+				return nil, fmt.Errorf("合并分段结果失败: %w", err)
+			}
+			notifyChunk(ctx, total, total)
+			return result, nil
+		}
+
+		next := make([]chunkResult, 0, len(groups))
+		for _, group := range groups {
+			if len(group) == 1 {
+				next = append(next, group[0])
+				continue
+			}
+			total++
+			text, err := prompt.reducePrompt(schema, group, len(chunks))
+			if err != nil {
+				return nil, err
+			}
+			result, err := c.complete(ctx, prompt, schema, text)
+			if err != nil {
+				return nil, fmt.Errorf("合并分段结果失败: %w", err)
+			}
+			next = append(next, chunkResult{first: group[0].first, last: group[len(group)-1].last, result: result})
+			done++
+			notifyChunk(ctx, done, total)
+		}
+		partials = next
+	}
+}
+
+// groupChunkResults 将分段结果按顺序分组，每组合计不超过 limit 个 token；
+// 每组至少两项，保证逐层合并时结果数量持续减少
+func groupChunkResults(schema *model.AnalysisSchema, results []chunkResult, limit int) [][]chunkResult {
+	var groups [][]chunkResult
+	var current []chunkResult
+	tokens := 0
+	for _, r := range results {
+		t := estimateTokens(formatChunkResult(schema, r))
+		if len(current) >= 2 && tokens+t > limit {
+			groups = append(groups, current)
+			current = nil
+			tokens = 0
+		}
+		current = append(current, r)
+		tokens += t
+	}
+	if len(current) > 0 {
+		groups = append(groups, current)
+	}
+	return groups
+}
+
+func formatChunkResult(schema *model.AnalysisSchema, r chunkResult) string {
+	if isDefaultSchema(schema) {
+		return fmt.Sprintf("【%s】\n核心观点：%s\n文件结构：%s\n作者思路：%s\n相关素材与事例：%s\n",
+			r.label(), r.result.CoreViewpoints, r.result.FileStructure, r.result.AuthorThoughts, r.result.RelatedMaterials)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "【%s】\n", r.label())
+	for _, field := range schema.Fields {
+		fmt.Fprintf(&b, "%s：%s\n", field.Label, fieldText(field, r.result.Fields[field.Key]))
+	}
+	return b.String()
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"article-analysis/internal/config"
+	"article-analysis/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitIntoChunks(t *testing.T) {
+	paragraph := strings.Repeat("字", 30)
+	content := strings.Join([]string{
+		"第一章 开端", paragraph, paragraph,
+		"第二章 发展", paragraph, "",
+		"第三章 结局", paragraph,
+	}, "\n")
+
+	chunks := splitIntoChunks(content, 70)
+	require.Len(t, chunks, 3)
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, estimateTokens(chunk), 70)
+	}
+	assert.True(t, strings.HasPrefix(chunks[1], "第二章"), "过半的分段应在章节标题处断开")
+	assert.True(t, strings.HasPrefix(chunks[2], "第三章"))
+}
+
+func TestSplitIntoChunks_LongParagraph(t *testing.T) {
+	sentence := strings.Repeat("长", 19) + "。"
+	content := strings.Repeat(sentence, 10) + strings.Repeat("无", 120)
+
+	chunks := splitIntoChunks(content, 50)
+	var joined strings.Builder
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, estimateTokens(chunk), 50)
+		joined.WriteString(chunk)
+	}
+	assert.Equal(t, content, joined.String(), "切分不应丢失内容")
+}
+
+func TestOpenAIClient_MapReduceLongArticle(t *testing.T) {
+	var mu sync.Mutex
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		mu.Lock()
+		prompts = append(prompts, req.Messages[len(req.Messages)-1].Content)
+		mu.Unlock()
+		writeChatCompletion(w, testAnalysisJSON)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		OpenAI:   config.OpenAIConfig{APIKey: "test-api-key", APIBase: server.URL, Model: "test-model"},
+		Analysis: config.AnalysisConfig{ChunkTokens: 200},
+	}
+	client := NewOpenAIClient(cfg, logger.NewLogger("error"))
+
+	paragraph := strings.Repeat("内容", 40)
+	content := strings.Repeat(paragraph+"\n", 6)
+	chunks := splitIntoChunks(content, 200)
+	require.Len(t, chunks, 3)
+	assert.Equal(t, 4, client.estimateUsage(content, defaultPromptTemplate(), defaultAnalysisSchema).Calls)
+
+	var progress [][2]int
+	ctx := WithChunkObserver(context.Background(), func(done, total int) {
+		progress = append(progress, [2]int{done, total})
+	})
+
+	result, err := client.AnalyzeArticle(ctx, content)
+	require.NoError(t, err)
+	assert.Equal(t, "观点", result.CoreViewpoints)
+
+	require.Len(t, prompts, 4, "3次分段分析加1次合并")
+	assert.Contains(t, prompts[0], "第1部分（共3部分）")
+	assert.Contains(t, prompts[3], "【第1部分】")
+	assert.Contains(t, prompts[3], "【第3部分】")
+	assert.Equal(t, [][2]int{{1, 4}, {2, 4}, {3, 4}, {4, 4}}, progress)
+}
+
+func TestOpenAIClient_ShortArticleSinglePass(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		writeChatCompletion(w, testAnalysisJSON)
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(server.URL, 0)
+	assert.Equal(t, 1, client.estimateUsage("短文", defaultPromptTemplate(), defaultAnalysisSchema).Calls)
+
+	_, err := client.AnalyzeArticle(context.Background(), "短文")
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestGroupChunkResults_AlwaysShrinks(t *testing.T) {
+	big := &AnalysisResponse{CoreViewpoints: strings.Repeat("观", 200)}
+	results := []chunkResult{
+		{first: 1, last: 1, result: big},
+		{first: 2, last: 2, result: big},
+		{first: 3, last: 3, result: big},
+	}
+
+	groups := groupChunkResults(defaultAnalysisSchema, results, 50)
+	require.Len(t, groups, 2)
+	assert.Len(t, groups[0], 2)
+	assert.Equal(t, "第1部分", results[0].label())
+	assert.Equal(t, "第1-2部分", chunkResult{first: 1, last: 2}.label())
+}
@@ -2,148 +2,380 @@ package service
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"strings"
+	"time"
 
 	"article-analysis/internal/config"
+	"article-analysis/internal/model"
 	"article-analysis/pkg/logger"
 
-	"github.com/sashabaranov/go-openai"
+	"go.uber.org/zap"
 )
 
+// OpenAIClient 文章分析器，负责构造提示词、调用模型服务并解析结果
+//
+// 名称沿用最初只支持 OpenAI 兼容接口时的命名，实际调用的服务由模型配置（profile）决定。
+// 主模型服务重试耗尽或处于熔断状态时，按降级链依次改用后备服务。
 type OpenAIClient struct {
-	client *openai.Client
-	log    *logger.Logger
-	config *config.Config
+	backends []*llmBackend // 降级链，第一个为主模型服务
+	profile  config.ModelProfile
+	retry    retryPolicy
+	log      *logger.Logger
+	config   *config.Config
 }
 
+// NewOpenAIClient 使用默认模型配置创建分析器
 func NewOpenAIClient(cfg *config.Config, log *logger.Logger) *OpenAIClient {
-	clientConfig := openai.DefaultConfig(cfg.OpenAI.APIKey)
-	if cfg.OpenAI.APIBase != "" {
-		clientConfig.BaseURL = cfg.OpenAI.APIBase
-	}
-	
+	return NewOpenAIClientForProfile(cfg, defaultProfile(cfg), log)
+}
+
+// NewOpenAIClientForProfile 使用指定的模型配置及其降级链创建分析器，熔断状态只在该分析器内有效
+func NewOpenAIClientForProfile(cfg *config.Config, profile config.ModelProfile, log *logger.Logger) *OpenAIClient {
+	return newOpenAIClientFromRegistry(cfg, newBackendRegistry(cfg, log), profile.Name, log)
+}
+
+// newOpenAIClientFromRegistry 从模型服务注册表取出模型配置的调用链创建分析器，
+// 同一注册表创建的分析器共享每个模型服务的熔断状态
+func newOpenAIClientFromRegistry(cfg *config.Config, registry *backendRegistry, profileName string, log *logger.Logger) *OpenAIClient {
+	return newOpenAIClientWithBackends(cfg, registry.chain(profileName), log)
+}
+
+// NewOpenAIClientWithProvider 使用指定的模型服务和默认模型配置创建分析器，不做降级
+func NewOpenAIClientWithProvider(cfg *config.Config, provider LLMProvider, log *logger.Logger) *OpenAIClient {
+	backend := &llmBackend{profile: defaultProfile(cfg), provider: provider, breaker: newBreakerFromConfig(cfg)}
+	return newOpenAIClientWithBackends(cfg, []*llmBackend{backend}, log)
+}
+
+func newOpenAIClientWithBackends(cfg *config.Config, backends []*llmBackend, log *logger.Logger) *OpenAIClient {
 	return &OpenAIClient{
-		client: openai.NewClientWithConfig(clientConfig),
+		backends: backends,
+		profile:  backends[0].profile,
+		retry: retryPolicy{
+			maxRetries: cfg.OpenAI.MaxRetries,
+			baseDelay:  time.Duration(cfg.OpenAI.RetryBaseDelay) * time.Millisecond,
+			maxDelay:   time.Duration(cfg.OpenAI.RetryMaxDelay) * time.Millisecond,
+		},
 		log:    log,
 		config: cfg,
 	}
 }
 
+var (
+	_ modelNamer        = (*OpenAIClient)(nil)
+	_ usageEstimator    = (*OpenAIClient)(nil)
+	_ cacheableAnalyzer = (*OpenAIClient)(nil)
+)
+
+// defaultProfile 返回默认模型配置，默认配置名不存在时取第一个
+func defaultProfile(cfg *config.Config) config.ModelProfile {
+	profiles := cfg.OpenAI.ResolvedProfiles()
+	name := cfg.OpenAI.DefaultProfileName()
+	for _, p := range profiles {
+		if p.Name == name {
+			return p
+		}
+	}
+	return profiles[0]
+}
+
+// analysisResultFormat 提示词中给出的返回格式，与 analysisResultSchema 对应
+const analysisResultFormat = `{
+  "core_viewpoints": [
+    {"title": "要点标题", "explanation": "具体说明", "quote": "原文中支撑该要点的句子"}
+  ],
+  "file_structure": [同上],
+  "author_thoughts": [同上],
+  "related_materials": [同上]
+}
+
+每个方面列出若干条要点，每条要点包含标题、说明和引文；quote 必须摘自原文，没有合适的引文时填空字符串。`
+
+// defaultOutputTokens 预估用量时单次模型调用的输出 token 数，模型配置了 max_tokens 且更小时以其为准
+const defaultOutputTokens = 1500
+
 type AnalysisRequest struct {
 	Content string
 	Prompt  string
 }
 
 type AnalysisResponse struct {
+	Result model.AnalysisResult
+	Fields model.AnalysisFields // 自定义分析方案的结果，使用内置方案时为空
+
+	// 由 Result 生成的文本，兼容只读取文本字段的调用方
 	CoreViewpoints   string
 	FileStructure    string
 	AuthorThoughts   string
 	RelatedMaterials string
+
+	// 实际完成本次分析的模型服务，发生降级时与请求的模型配置不同
+	Provider string
+	Profile  string
+	Model    string
+}
+
+// newAnalysisResponse 由结构化结果生成分析结果，同时生成各维度的文本
+func newAnalysisResponse(result model.AnalysisResult) *AnalysisResponse {
+	// 缺少的维度以空数组返回，便于前端直接遍历
+	for _, items := range []*[]model.AnalysisItem{&result.CoreViewpoints, &result.FileStructure, &result.AuthorThoughts, &result.RelatedMaterials} {
+		if *items == nil {
+			*items = []model.AnalysisItem{}
+		}
+	}
+	return &AnalysisResponse{
+		Result:           result,
+		CoreViewpoints:   model.FormatItems(result.CoreViewpoints),
+		FileStructure:    model.FormatItems(result.FileStructure),
+		AuthorThoughts:   model.FormatItems(result.AuthorThoughts),
+		RelatedMaterials: model.FormatItems(result.RelatedMaterials),
+	}
 }
 
+// AnalyzeArticle 分析文章，使用上下文中的提示词版本和分析方案（见 WithPromptTemplate、WithAnalysisSchema），
+// 未指定时使用内置提示词和内置分析方案
 func (c *OpenAIClient) AnalyzeArticle(ctx context.Context, content string) (*AnalysisResponse, error) {
-	prompt := c.buildAnalysisPrompt(content)
-	
-	// 使用配置的模型，如果没有配置则使用默认的GPT-3.5-turbo
-	model := c.getModel()
-	
-	resp, err := c.client.CreateChatCompletion(
-		ctx,
-		openai.ChatCompletionRequest{
-			Model: model,
-			Messages: []openai.ChatCompletionMessage{
-				{
-					Role:    openai.ChatMessageRoleSystem,
-					Content: "你是一个专业的文章分析助手，请对文章内容进行深度分析。",
-				},
-				{
-					Role:    openai.ChatMessageRoleUser,
-					Content: prompt,
-				},
-			},
-			Temperature: 0.7,
-		},
-	)
-	
-	if err != nil {
-		c.log.Error("OpenAI API调用失败", err)
-		return nil, fmt.Errorf("OpenAI API调用失败: %w", err)
+	prompt, schema := promptFromContext(ctx), schemaFromContext(ctx)
+	if err := prompt.supports(schema); err != nil {
+		return nil, err
 	}
-	
-	if len(resp.Choices) == 0 {
-		return nil, fmt.Errorf("OpenAI API返回空响应")
+	limit := c.chunkTokens()
+	if estimateTokens(content) > limit {
+		return c.analyzeInChunks(ctx, prompt, schema, splitIntoChunks(content, limit))
 	}
-	
-	result, err := c.parseAIResponse(resp.Choices[0].Message.Content)
+	text, err := prompt.analysisPrompt(schema, content)
 	if err != nil {
-		c.log.Error("解析AI响应失败", err)
-		return nil, fmt.Errorf("解析AI响应失败: %w", err)
+		return nil, err
+	}
+	return c.complete(ctx, prompt, schema, text)
+}
+
+// complete 按降级链发送分析提示词并解析结果
+func (c *OpenAIClient) complete(ctx context.Context, prompt *PromptTemplate, schema *model.AnalysisSchema, text string) (*AnalysisResponse, error) {
+	messages := []ChatMessage{
+		{
+			Role:    "system",
+			Content: prompt.system,
+		},
+		{
+			Role:    "user",
+			Content: text,
+		},
+	}
+
+	var lastErr error
+	for i, backend := range c.backends {
+		if i > 0 {
+			if ctx.Err() != nil {
+				break
+			}
+			c.log.Warn("模型服务不可用，切换到降级服务",
+				zap.String("profile", backend.profile.Name),
+				zap.String("provider", backend.provider.Name()),
+				zap.Error(lastErr))
+		}
+
+		req := ChatRequest{
+			Model:       c.modelFor(backend.profile),
+			Messages:    messages,
+			Temperature: backend.profile.GetTemperature(),
+			MaxTokens:   backend.profile.MaxTokens,
+			Schema:      responseSchema(schema),
+			JSONMode:    backend.profile.JSONMode,
+		}
+		resp, err := c.chat(ctx, backend, req)
+		if err != nil {
+			c.log.Error("模型调用失败", err,
+				zap.String("profile", backend.profile.Name),
+				zap.String("provider", backend.provider.Name()))
+			lastErr = err
+			continue
+		}
+
+		result, resp, err := c.parseWithRepair(ctx, backend, schema, req, resp)
+		if err != nil {
+			c.log.Error("解析AI响应失败", err)
+			return nil, fmt.Errorf("解析AI响应失败: %w", err)
+		}
+		result.Provider = backend.provider.Name()
+		result.Profile = backend.profile.Name
+		result.Model = resp.Model
+		if result.Model == "" {
+			result.Model = c.modelFor(backend.profile)
+		}
+		return result, nil
 	}
-	
-	return result, nil
+
+	return nil, fmt.Errorf("模型调用失败: %w", lastErr)
 }
 
-func (c *OpenAIClient) buildAnalysisPrompt(content string) string {
-	return fmt.Sprintf(`
-请对以下文章进行深度分析，并以JSON格式返回分析结果：
+// chat 调用模型服务，对超时、限流、5xx 等可重试错误按指数退避重试，
+// 服务端返回 Retry-After 时按其等待；鉴权失败、参数错误等直接返回。
+// 服务处于熔断状态时不发出请求，返回 ErrCircuitOpen 或此前的调用错误。
+func (c *OpenAIClient) chat(ctx context.Context, backend *llmBackend, req ChatRequest) (*ChatResponse, error) {
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		if !backend.breaker.allow() {
+			if lastErr != nil {
+				return nil, lastErr
+			}
+			return nil, fmt.Errorf("%s: %w", backend.profile.Name, ErrCircuitOpen)
+		}
 
-文章内容：
-%s
+		start := time.Now()
+		resp, err := c.call(ctx, backend.provider, req)
+		backend.breaker.record(err)
+		record := LLMAttempt{Attempt: attempt, Profile: backend.profile.Name, Model: req.Model, Duration: time.Since(start), Err: err}
+		if err == nil {
+			if resp.Model != "" {
+				record.Model = resp.Model
+			}
+			record.PromptTokens = resp.PromptTokens
+			record.CompletionTokens = resp.CompletionTokens
+			notifyAttempt(ctx, record)
+			return resp, nil
+		}
+		lastErr = err
 
-请提供以下四个方面的分析：
+		record.Retryable, record.StatusCode = classifyError(err)
+		if ctx.Err() != nil || !record.Retryable || attempt > c.retry.maxRetries {
+			record.Retryable = record.Retryable && ctx.Err() == nil
+			notifyAttempt(ctx, record)
+			return nil, err
+		}
 
-1. 核心观点：总结文章的主要观点和核心论点
-2. 文件结构：分析文章的结构组织方式
-3. 作者思路：分析作者的写作思路和逻辑脉络
-4. 相关素材与事例：提取文章中的重要素材、案例和论据
+		wait := c.retry.backoff(attempt)
+		var providerErr *ProviderError
+		if errors.As(err, &providerErr) && providerErr.RetryAfter > 0 {
+			wait = providerErr.RetryAfter
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			// 等待超过剩余时间，重试也无法完成
+			notifyAttempt(ctx, record)
+			return nil, err
+		}
+		record.Wait = wait
+		notifyAttempt(ctx, record)
 
-请以以下JSON格式返回结果：
-{
-  "core_viewpoints": "核心观点内容",
-  "file_structure": "文件结构描述",
-  "author_thoughts": "作者思路分析",
-  "related_materials": "相关素材与事例"
+		c.log.Warn("模型调用失败，准备重试",
+			zap.String("provider", backend.provider.Name()),
+			zap.Int("attempt", attempt),
+			zap.Int("status_code", record.StatusCode),
+			zap.Duration("wait", wait),
+			zap.Error(err))
+		if err := sleepContext(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
 }
 
-文章内容长度：%d字符`, content, len(content))
+// call 发出一次调用；上下文中有增量内容观察者且服务支持流式输出时使用流式调用
+func (c *OpenAIClient) call(ctx context.Context, provider LLMProvider, req ChatRequest) (*ChatResponse, error) {
+	if observer := tokenObserverFrom(ctx); observer != nil {
+		if streaming, ok := provider.(streamingProvider); ok {
+			return streaming.ChatStream(ctx, req, observer)
+		}
+	}
+	return provider.Chat(ctx, req)
 }
 
 func (c *OpenAIClient) getModel() string {
+	return c.modelFor(c.profile)
+}
+
+// cacheKey 分析结果的缓存键：文章内容、提示词版本及内容、分析方案、模型及影响输出的生成参数的 SHA-256
+func (c *OpenAIClient) cacheKey(content string, prompt *PromptTemplate, schema *model.AnalysisSchema) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%s\x00%g\x00%d\x00%d\x00",
+		prompt.Version, prompt.Digest, schemaDigest(schema), c.profile.Provider, c.getModel(),
+		c.profile.GetTemperature(), c.profile.MaxTokens, c.chunkTokens())
+	h.Write([]byte(content))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// chunkTokens 返回单次送入模型的文章内容上限
+func (c *OpenAIClient) chunkTokens() int {
+	if c.profile.ChunkTokens > 0 {
+		return c.profile.ChunkTokens
+	}
+	if c.config.Analysis.ChunkTokens > 0 {
+		return c.config.Analysis.ChunkTokens
+	}
+	return defaultChunkTokens
+}
+
+// UsageEstimate 分析一篇文章预计的模型用量
+type UsageEstimate struct {
+	Calls        int `json:"calls"`
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// estimateUsage 按实际会发送的提示词估算分析用量，长文按分段方案估算，多层合并忽略不计
+func (c *OpenAIClient) estimateUsage(content string, prompt *PromptTemplate, schema *model.AnalysisSchema) UsageEstimate {
+	system := estimateTokens(prompt.system)
+	output := defaultOutputTokens
+	if c.profile.MaxTokens > 0 && c.profile.MaxTokens < output {
+		output = c.profile.MaxTokens
+	}
+
+	limit := c.chunkTokens()
+	var chunks []string
+	if estimateTokens(content) > limit {
+		chunks = splitIntoChunks(content, limit)
+	}
+	if len(chunks) <= 1 {
+		return UsageEstimate{
+			Calls:        1,
+			InputTokens:  system + estimatePromptTokens(prompt.analysisPrompt(schema, content)),
+			OutputTokens: output,
+		}
+	}
+
+	estimate := UsageEstimate{Calls: len(chunks) + 1}
+	for i, chunk := range chunks {
+		estimate.InputTokens += system + estimatePromptTokens(prompt.chunkPrompt(schema, chunk, i+1, len(chunks)))
+	}
+	// 合并时每段的分析结果作为输入
+	estimate.InputTokens += system + estimatePromptTokens(prompt.reducePrompt(schema, nil, len(chunks))) + len(chunks)*output
+	estimate.OutputTokens = estimate.Calls * output
+	return estimate
+}
+
+// estimatePromptTokens 估算渲染后提示词的 token 数；提示词在加载时已校验，渲染失败时按空提示词估算
+func estimatePromptTokens(text string, err error) int {
+	if err != nil {
+		return 0
+	}
+	return estimateTokens(text)
+}
+
+// modelFor 返回模型配置使用的模型，未配置时回退到顶层配置和默认的Moonshot模型
+func (c *OpenAIClient) modelFor(profile config.ModelProfile) string {
+	if profile.Model != "" {
+		return profile.Model
+	}
 	if c.config.OpenAI.Model != "" {
 		return c.config.OpenAI.Model
 	}
 	return "kimi-k2-0905-preview" // 默认Moonshot模型
 }
 
-func (c *OpenAIClient) parseAIResponse(content string) (*AnalysisResponse, error) {
-	// 提取JSON部分
-	startIdx := strings.Index(content, "{")
-	endIdx := strings.LastIndex(content, "}")
-	
-	if startIdx == -1 || endIdx == -1 {
-		return nil, fmt.Errorf("无法解析AI响应格式，找不到JSON内容")
-	}
-	
-	jsonStr := content[startIdx : endIdx+1]
-	
-	// 定义临时结构体用于解析
-	tempResult := struct {
-		CoreViewpoints   string `json:"core_viewpoints"`
-		FileStructure    string `json:"file_structure"`
-		AuthorThoughts   string `json:"author_thoughts"`
-		RelatedMaterials string `json:"related_materials"`
-	}{}
-	
-	if err := json.Unmarshal([]byte(jsonStr), &tempResult); err != nil {
-		return nil, fmt.Errorf("JSON解析失败: %w", err)
-	}
-	
-	return &AnalysisResponse{
-		CoreViewpoints:   tempResult.CoreViewpoints,
-		FileStructure:    tempResult.FileStructure,
-		AuthorThoughts:   tempResult.AuthorThoughts,
-		RelatedMaterials: tempResult.RelatedMaterials,
-	}, nil
-}
\ No newline at end of file
+// parseAIResponse 按分析方案解析并校验模型返回的分析结果
+func (c *OpenAIClient) parseAIResponse(content string, schema *model.AnalysisSchema) (*AnalysisResponse, error) {
+	if isDefaultSchema(schema) {
+		var result model.AnalysisResult
+		if err := decodeStructured(content, analysisResultSchema.Definition, &result); err != nil {
+			return nil, err
+		}
+		return newAnalysisResponse(result), nil
+	}
+
+	var fields model.AnalysisFields
+	if err := decodeStructured(content, responseSchema(schema).Definition, &fields); err != nil {
+		return nil, err
+	}
+	return &AnalysisResponse{Fields: fields}, nil
+}
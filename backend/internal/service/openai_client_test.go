@@ -10,6 +10,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockOpenAIClient 是一个模拟的OpenAI客户端
@@ -40,18 +41,10 @@ func TestOpenAIClient_AnalyzeArticle_Success(t *testing.T) {
 	assert.NotNil(t, client)
 }
 
-func TestOpenAIClient_buildAnalysisPrompt(t *testing.T) {
-	log := logger.NewLogger("test")
-	cfg := &config.Config{
-		OpenAI: config.OpenAIConfig{
-			APIKey: "test-api-key",
-		},
-	}
-	
-	client := NewOpenAIClient(cfg, log)
-	
+func TestPromptTemplate_analysisPrompt(t *testing.T) {
 	content := "这是一篇测试文章内容"
-	prompt := client.buildAnalysisPrompt(content)
+	prompt, err := defaultPromptTemplate().analysisPrompt(defaultAnalysisSchema, content)
+	require.NoError(t, err)
 	
 	// 验证提示词包含必要的内容
 	assert.Contains(t, prompt, "请对以下文章进行深度分析")
@@ -77,21 +70,32 @@ func TestOpenAIClient_parseAIResponse_Success(t *testing.T) {
 	responseContent := `
 以下是分析结果：
 {
-  "core_viewpoints": "文章核心观点",
-  "file_structure": "文章结构描述",
-  "author_thoughts": "作者思路分析",
-  "related_materials": "相关素材"
+  "core_viewpoints": [
+    {"title": "文章核心观点", "explanation": "", "quote": ""}
+  ],
+  "file_structure": [
+    {"title": "文章结构", "explanation": "总分总", "quote": ""}
+  ],
+  "author_thoughts": [
+    {"title": "作者思路分析", "explanation": "", "quote": ""}
+  ],
+  "related_materials": [
+    {"title": "相关素材", "explanation": "", "quote": "原文引文"},
+    {"title": "案例", "explanation": "说明", "quote": ""}
+  ]
 }
 希望这个分析对您有帮助！`
 	
-	result, err := client.parseAIResponse(responseContent)
+	result, err := client.parseAIResponse(responseContent, defaultAnalysisSchema)
 	
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
 	assert.Equal(t, "文章核心观点", result.CoreViewpoints)
-	assert.Equal(t, "文章结构描述", result.FileStructure)
+	assert.Equal(t, "文章结构：总分总", result.FileStructure)
 	assert.Equal(t, "作者思路分析", result.AuthorThoughts)
-	assert.Equal(t, "相关素材", result.RelatedMaterials)
+	assert.Equal(t, "1. 相关素材（原文：“原文引文”）\n2. 案例：说明", result.RelatedMaterials)
+	require.Len(t, result.Result.RelatedMaterials, 2)
+	assert.Equal(t, "原文引文", result.Result.RelatedMaterials[0].Quote)
 }
 
 func TestOpenAIClient_parseAIResponse_InvalidJSON(t *testing.T) {
@@ -111,7 +115,7 @@ func TestOpenAIClient_parseAIResponse_InvalidJSON(t *testing.T) {
   "invalid_json": "缺少闭合括号"
 希望这个分析对您有帮助！`
 	
-	result, err := client.parseAIResponse(responseContent)
+	result, err := client.parseAIResponse(responseContent, defaultAnalysisSchema)
 	
 	assert.Error(t, err)
 	assert.Nil(t, result)
@@ -134,7 +138,7 @@ func TestOpenAIClient_parseAIResponse_NoJSON(t *testing.T) {
 没有JSON格式的内容
 希望这个分析对您有帮助！`
 	
-	result, err := client.parseAIResponse(responseContent)
+	result, err := client.parseAIResponse(responseContent, defaultAnalysisSchema)
 	
 	assert.Error(t, err)
 	assert.Nil(t, result)
@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"unicode/utf8"
+
+	"article-analysis/internal/model"
+)
+
+// builtinPromptVersion 默认使用的内置提示词版本，内置提示词的版本即 prompts 目录下的文件名
+//
+// 修改内置提示词时应新增一个版本的文件，而不是修改已有文件，以便区分各版本产生的结果。
+// v2 列出固定的四个分析维度，只适用于内置分析方案；v3 起分析维度由分析方案生成。
+const builtinPromptVersion = "v3"
+
+//go:embed prompts/*.tmpl
+var builtinPromptFiles embed.FS
+
+var (
+	ErrUnknownPrompt        = errors.New("提示词版本不存在")
+	ErrPromptSchemaMismatch = errors.New("提示词版本不支持自定义分析方案")
+)
+
+// promptTemplateNames 每个提示词版本必须定义的模板
+var promptTemplateNames = []string{"system", "analysis", "chunk", "reduce"}
+
+// PromptTemplate 一个版本的分析提示词，使用 text/template 编写，包含以下模板：
+//
+//	system   系统提示词
+//	analysis 全文分析，可用 .Content、.Length（字符数）、.Dimensions、.Format
+//	chunk    长文分段分析，可用 .Content、.Index、.Total、.Dimensions、.Format
+//	reduce   合并分段结果，可用 .Results、.Total、.Dimensions、.Format
+//
+// .Dimensions 为分析方案中的维度（见 PromptDimension），.Format 为与结果校验规则对应的返回格式说明，均由程序提供。
+// 全文分析模板没有列出 .Dimensions 的提示词只能用于内置分析方案。
+type PromptTemplate struct {
+	Version string
+	Source  string // 内置模板为 builtin，否则为文件路径
+	Digest  string // 模板内容的 SHA-256，版本号相同但内容被修改时可以区分
+
+	tmpl    *template.Template
+	system  string
+	dynamic bool // 是否按分析方案列出维度
+}
+
+// PromptDimension 提示词中的一个分析维度
+type PromptDimension struct {
+	Index       int // 序号，从 1 开始
+	Key         string
+	Label       string
+	Description string
+	Type        string
+}
+
+func promptDimensions(schema *model.AnalysisSchema) []PromptDimension {
+	dimensions := make([]PromptDimension, 0, len(schema.Fields))
+	for i, field := range schema.Fields {
+		dimensions = append(dimensions, PromptDimension{
+			Index:       i + 1,
+			Key:         field.Key,
+			Label:       field.Label,
+			Description: field.Description,
+			Type:        field.Type,
+		})
+	}
+	return dimensions
+}
+
+// supports 检查提示词能否用于分析方案
+func (p *PromptTemplate) supports(schema *model.AnalysisSchema) error {
+	if isDefaultSchema(schema) || p.dynamic {
+		return nil
+	}
+	return fmt.Errorf("%w：%s 只适用于内置分析方案", ErrPromptSchemaMismatch, p.Version)
+}
+
+func parsePromptTemplate(version, source string, text []byte) (*PromptTemplate, error) {
+	tmpl, err := template.New(version).Option("missingkey=error").Parse(string(text))
+	if err != nil {
+		return nil, fmt.Errorf("提示词 %s 解析失败: %w", version, err)
+	}
+	for _, name := range promptTemplateNames {
+		if tmpl.Lookup(name) == nil {
+			return nil, fmt.Errorf("提示词 %s 缺少模板 %s", version, name)
+		}
+	}
+
+	sum := sha256.Sum256(text)
+	p := &PromptTemplate{Version: version, Source: source, Digest: hex.EncodeToString(sum[:]), tmpl: tmpl}
+	if p.system, err = p.render("system", nil); err != nil {
+		return nil, err
+	}
+	// 用示例数据渲染一遍，引用了不存在的字段等错误在加载时即可发现；
+	// 同时检查渲染结果中是否出现示例维度，判断提示词是否按分析方案列出维度
+	probe := &model.AnalysisSchema{Name: "probe", Fields: model.SchemaFields{
+		{Key: "probe", Label: "示例维度PROBE", Type: model.FieldTypeItems},
+	}}
+	rendered, err := p.analysisPrompt(probe, "示例")
+	if err != nil {
+		return nil, err
+	}
+	p.dynamic = strings.Contains(rendered, "示例维度PROBE")
+	if _, err := p.chunkPrompt(probe, "示例", 1, 2); err != nil {
+		return nil, err
+	}
+	if _, err := p.reducePrompt(probe, nil, 2); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *PromptTemplate) render(name string, data interface{}) (string, error) {
+	var b strings.Builder
+	if err := p.tmpl.ExecuteTemplate(&b, name, data); err != nil {
+		return "", fmt.Errorf("提示词 %s 渲染失败: %w", p.Version, err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+func (p *PromptTemplate) analysisPrompt(schema *model.AnalysisSchema, content string) (string, error) {
+	return p.render("analysis", struct {
+		Content    string
+		Length     int
+		Dimensions []PromptDimension
+		Format     string
+	}{content, utf8.RuneCountInString(content), promptDimensions(schema), resultFormat(schema)})
+}
+
+func (p *PromptTemplate) chunkPrompt(schema *model.AnalysisSchema, chunk string, index, total int) (string, error) {
+	return p.render("chunk", struct {
+		Content      string
+		Index, Total int
+		Dimensions   []PromptDimension
+		Format       string
+	}{chunk, index, total, promptDimensions(schema), resultFormat(schema)})
+}
+
+func (p *PromptTemplate) reducePrompt(schema *model.AnalysisSchema, results []chunkResult, total int) (string, error) {
+	var b strings.Builder
+	for _, r := range results {
+		b.WriteString(formatChunkResult(schema, r))
+		b.WriteString("\n")
+	}
+	return p.render("reduce", struct {
+		Results    string
+		Total      int
+		Dimensions []PromptDimension
+		Format     string
+	}{b.String(), total, promptDimensions(schema), resultFormat(schema)})
+}
+
+var (
+	builtinPromptsOnce sync.Once
+	builtinPrompts     map[string]*PromptTemplate
+)
+
+// loadBuiltinPrompts 解析全部内置提示词
+func loadBuiltinPrompts() map[string]*PromptTemplate {
+	builtinPromptsOnce.Do(func() {
+		builtinPrompts = make(map[string]*PromptTemplate)
+		files, err := builtinPromptFiles.ReadDir("prompts")
+		if err != nil {
+			panic(fmt.Sprintf("读取内置提示词失败: %v", err))
+		}
+		for _, file := range files {
+			text, err := builtinPromptFiles.ReadFile("prompts/" + file.Name())
+			if err != nil {
+				panic(fmt.Sprintf("读取内置提示词失败: %v", err))
+			}
+			version := strings.TrimSuffix(file.Name(), ".tmpl")
+			prompt, err := parsePromptTemplate(version, "builtin", text)
+			if err != nil {
+				panic(fmt.Sprintf("内置提示词无效: %v", err))
+			}
+			builtinPrompts[version] = prompt
+		}
+	})
+	return builtinPrompts
+}
+
+// defaultPromptTemplate 返回默认的内置提示词
+func defaultPromptTemplate() *PromptTemplate {
+	return loadBuiltinPrompts()[builtinPromptVersion]
+}
+
+type promptTemplateKey struct{}
+
+// WithPromptTemplate 返回携带提示词的上下文，分析器按其构造提示词
+func WithPromptTemplate(ctx context.Context, prompt *PromptTemplate) context.Context {
+	return context.WithValue(ctx, promptTemplateKey{}, prompt)
+}
+
+// promptFromContext 返回上下文中的提示词，未设置时使用内置提示词
+func promptFromContext(ctx context.Context) *PromptTemplate {
+	if prompt, ok := ctx.Value(promptTemplateKey{}).(*PromptTemplate); ok && prompt != nil {
+		return prompt
+	}
+	return defaultPromptTemplate()
+}
+
+// PromptRegistry 可选的提示词版本：内置版本及提示词目录中的 <版本>.tmpl 文件
+type PromptRegistry struct {
+	templates      map[string]*PromptTemplate
+	defaultVersion string
+}
+
+// NewPromptRegistry 加载提示词目录中的模板，dir 为空时只有内置版本；
+// 任一文件无效或默认版本不存在时返回错误
+func NewPromptRegistry(dir, defaultVersion string) (*PromptRegistry, error) {
+	r := &PromptRegistry{
+		templates:      make(map[string]*PromptTemplate),
+		defaultVersion: builtinPromptVersion,
+	}
+	for version, prompt := range loadBuiltinPrompts() {
+		r.templates[version] = prompt
+	}
+
+	if dir != "" {
+		files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+		if err != nil {
+			return nil, fmt.Errorf("读取提示词目录失败: %w", err)
+		}
+		for _, file := range files {
+			version := strings.TrimSuffix(filepath.Base(file), ".tmpl")
+			if _, ok := r.templates[version]; ok {
+				return nil, fmt.Errorf("提示词版本 %s 与内置版本重复，修改提示词请使用新的版本号", version)
+			}
+			text, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("读取提示词失败: %w", err)
+			}
+			prompt, err := parsePromptTemplate(version, file, text)
+			if err != nil {
+				return nil, err
+			}
+			r.templates[version] = prompt
+		}
+	}
+
+	if defaultVersion != "" {
+		if _, ok := r.templates[defaultVersion]; !ok {
+			return nil, fmt.Errorf("默认%w: %s", ErrUnknownPrompt, defaultVersion)
+		}
+		r.defaultVersion = defaultVersion
+	}
+	return r, nil
+}
+
+// Get 返回指定版本的提示词，版本为空时返回默认版本
+func (r *PromptRegistry) Get(version string) (*PromptTemplate, error) {
+	if version == "" {
+		version = r.defaultVersion
+	}
+	prompt, ok := r.templates[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPrompt, version)
+	}
+	return prompt, nil
+}
+
+// Default 返回默认版本的提示词
+func (r *PromptRegistry) Default() *PromptTemplate {
+	return r.templates[r.defaultVersion]
+}
+
+// PromptInfo 对外展示的提示词版本
+type PromptInfo struct {
+	Version string `json:"version"`
+	Source  string `json:"source"`
+	Digest  string `json:"digest"`
+	Default bool   `json:"default"`
+	Dynamic bool   `json:"dynamic"` // 是否支持自定义分析方案
+}
+
+// List 按版本号列出可用的提示词
+func (r *PromptRegistry) List() []PromptInfo {
+	list := make([]PromptInfo, 0, len(r.templates))
+	for _, p := range r.templates {
+		list = append(list, PromptInfo{
+			Version: p.Version,
+			Source:  p.Source,
+			Digest:  p.Digest,
+			Default: p.Version == r.defaultVersion,
+			Dynamic: p.dynamic,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"article-analysis/internal/config"
+	"article-analysis/internal/model"
+	"article-analysis/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPromptTemplate = `{{define "system"}}你是语文老师。{{end}}
+{{define "analysis"}}分析：{{.Content}}
+{{.Format}}{{end}}
+{{define "chunk"}}第{{.Index}}/{{.Total}}部分：{{.Content}}
+{{.Format}}{{end}}
+{{define "reduce"}}合并：{{.Results}}
+{{.Format}}{{end}}`
+
+func writePromptFile(t *testing.T, dir, name, text string) {
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(text), 0o644))
+}
+
+func TestPromptRegistry(t *testing.T) {
+	dir := t.TempDir()
+	writePromptFile(t, dir, "v10.tmpl", testPromptTemplate)
+
+	registry, err := NewPromptRegistry(dir, "")
+	require.NoError(t, err)
+	def, err := registry.Get("")
+	require.NoError(t, err)
+	assert.Equal(t, builtinPromptVersion, def.Version, "未配置默认版本时使用内置版本")
+
+	v10, err := registry.Get("v10")
+	require.NoError(t, err)
+	assert.Equal(t, "你是语文老师。", v10.system)
+	prompt, err := v10.analysisPrompt(defaultAnalysisSchema, "正文")
+	require.NoError(t, err)
+	assert.Contains(t, prompt, "分析：正文")
+	assert.Contains(t, prompt, `"core_viewpoints"`)
+
+	_, err = registry.Get("v99")
+	assert.ErrorIs(t, err, ErrUnknownPrompt)
+
+	custom := &model.AnalysisSchema{Name: "rhetoric", Fields: model.SchemaFields{{Key: "devices", Label: "修辞手法", Type: model.FieldTypeItems}}}
+	assert.ErrorIs(t, v10.supports(custom), ErrPromptSchemaMismatch, "没有列出维度的提示词只适用于内置方案")
+	assert.NoError(t, def.supports(custom))
+	v2, err := registry.Get("v2")
+	require.NoError(t, err)
+	assert.ErrorIs(t, v2.supports(custom), ErrPromptSchemaMismatch)
+	versions := make([]string, 0)
+	for _, info := range registry.List() {
+		versions = append(versions, info.Version)
+	}
+	assert.Equal(t, []string{"narrative-v1", "news-v1", "poetry-v1", "technical-v1", "v10", "v2", builtinPromptVersion}, versions)
+
+	registry, err = NewPromptRegistry(dir, "v10")
+	require.NoError(t, err)
+	assert.Equal(t, "v10", registry.Default().Version)
+
+	_, err = NewPromptRegistry(dir, "v99")
+	assert.ErrorIs(t, err, ErrUnknownPrompt)
+
+	writePromptFile(t, dir, builtinPromptVersion+".tmpl", testPromptTemplate)
+	_, err = NewPromptRegistry(dir, "")
+	assert.ErrorContains(t, err, "与内置版本重复")
+
+	broken := t.TempDir()
+	writePromptFile(t, broken, "v4.tmpl", `{{define "system"}}系统{{end}}{{define "analysis"}}{{.Missing}}{{end}}`)
+	_, err = NewPromptRegistry(broken, "")
+	assert.ErrorContains(t, err, "缺少模板 chunk")
+
+	writePromptFile(t, broken, "v4.tmpl", strings.Replace(testPromptTemplate, "{{.Content}}", "{{.Missing}}", 1))
+	_, err = NewPromptRegistry(broken, "")
+	assert.ErrorContains(t, err, "渲染失败", "引用不存在的字段在加载时报错")
+}
+
+func TestAnalysisService_SelectsPromptVersion(t *testing.T) {
+	var mu sync.Mutex
+	var systems []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		systems = append(systems, req.Messages[0].Content)
+		mu.Unlock()
+		writeChatCompletion(w, testAnalysisJSON)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	writePromptFile(t, dir, "v10.tmpl", testPromptTemplate)
+
+	cfg := &config.Config{
+		OpenAI:   config.OpenAIConfig{APIKey: "test-api-key", APIBase: server.URL, Model: "test-model"},
+		Analysis: config.AnalysisConfig{MaxConcurrency: 1, PollInterval: 1, Timeout: 10, PromptDir: dir},
+	}
+	s, taskRepo, db := newTestAnalysisServiceWithAnalyzer(t, cfg, nil)
+	articleRepo := repository.NewArticleRepository(db)
+
+	article := &model.Article{Title: "作文", Author: "张三", Content: "作文内容", FilePath: "f.txt"}
+	require.NoError(t, articleRepo.Create(article))
+
+	_, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{Prompt: "v99"})
+	require.ErrorIs(t, err, ErrUnknownPrompt)
+
+	for _, version := range []string{"", "v10"} {
+		submitted, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{Prompt: version})
+		require.NoError(t, err)
+		task := runUntilFinished(t, s, taskRepo, submitted.TaskID)
+		require.Equal(t, model.TaskStatusCompleted, task.Status)
+		assert.False(t, task.CacheHit, "不同提示词版本的结果分开缓存")
+
+		analysis, err := s.GetAnalysisResult(article.ID)
+		require.NoError(t, err)
+		if version == "" {
+			version = builtinPromptVersion
+		}
+		assert.Equal(t, version, task.PromptVersion)
+		assert.Equal(t, version, analysis.PromptVersion)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, systems, 2)
+	assert.Equal(t, defaultPromptTemplate().system, systems[0])
+	assert.Equal(t, "你是语文老师。", systems[1])
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"article-analysis/internal/config"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// 支持的模型服务类型
+const (
+	ProviderOpenAI    = "openai"    // OpenAI 兼容接口（OpenAI、Moonshot、DeepSeek 等）
+	ProviderOllama    = "ollama"    // Ollama 原生 /api/chat 接口
+	ProviderAnthropic = "anthropic" // Anthropic 风格的 /v1/messages 接口
+)
+
+// Analyzer 文章分析器，AnalysisService 通过该接口执行分析
+type Analyzer interface {
+	AnalyzeArticle(ctx context.Context, content string) (*AnalysisResponse, error)
+}
+
+// ChatMessage 对话消息，Role 取值 system/user/assistant
+type ChatMessage struct {
+	Role    string
+	Content string
+}
+
+// 结构化输出方式
+const (
+	JSONModeAuto   = "auto"   // 按服务选择支持最好的方式
+	JSONModeSchema = "schema" // 按 JSON Schema 约束输出
+	JSONModeObject = "object" // 只要求输出合法 JSON
+	JSONModeTool   = "tool"   // 通过函数调用（tool use）返回，参数即结果
+	JSONModeOff    = "off"    // 不使用，仅依靠提示词约束格式
+)
+
+// normalizeJSONMode 统一结构化输出方式的写法，未配置或无法识别时为 auto
+func normalizeJSONMode(mode string) (string, bool) {
+	switch mode = strings.ToLower(strings.TrimSpace(mode)); mode {
+	case "":
+		return JSONModeAuto, true
+	case JSONModeAuto, JSONModeSchema, JSONModeObject, JSONModeTool, JSONModeOff:
+		return mode, true
+	default:
+		return JSONModeAuto, false
+	}
+}
+
+// ResponseSchema 期望模型返回的 JSON 结构
+type ResponseSchema struct {
+	Name        string // 仅含字母、数字、下划线，用作 schema 名或函数名
+	Description string
+	Definition  jsonschema.Definition
+}
+
+// ChatRequest 与具体服务无关的对话请求
+type ChatRequest struct {
+	Model       string
+	Messages    []ChatMessage
+	Temperature float32
+	MaxTokens   int
+
+	// Schema 不为空时按 JSONMode 要求服务返回结构化结果，服务以何种方式支持由各实现决定；
+	// 使用函数调用时，函数参数作为 ChatResponse.Content 返回
+	Schema   *ResponseSchema
+	JSONMode string
+}
+
+// ChatResponse 对话结果
+type ChatResponse struct {
+	Content          string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// LLMProvider 大模型服务的最小抽象，只负责一次对话调用，重试由调用方处理
+type LLMProvider interface {
+	Name() string
+	Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error)
+}
+
+// ProviderError 模型服务返回的HTTP错误
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration // 服务端要求的重试等待，未提供时为 0
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("%s 返回错误，状态码: %d，信息: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// NewProvider 按模型配置创建模型服务
+func NewProvider(cfg config.ModelProfile) (LLMProvider, error) {
+	switch strings.ToLower(cfg.Provider) {
+	case "", ProviderOpenAI:
+		return newOpenAIProvider(cfg.APIKey, cfg.APIBase), nil
+	case ProviderOllama:
+		return newOllamaProvider(cfg.APIBase), nil
+	case ProviderAnthropic:
+		return newAnthropicProvider(cfg.APIKey, cfg.APIBase), nil
+	default:
+		return nil, fmt.Errorf("不支持的模型服务类型: %s", cfg.Provider)
+	}
+}
+
+// postJSON 发送JSON请求并解析JSON响应，非2xx响应转换为 ProviderError
+func postJSON(ctx context.Context, client *http.Client, provider, url string, headers map[string]string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		perr := &ProviderError{
+			Provider:   provider,
+			StatusCode: resp.StatusCode,
+			Message:    errorMessageFromBody(data),
+		}
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			perr.RetryAfter = wait
+		}
+		return perr
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+	return nil
+}
+
+// errorMessageFromBody 从错误响应中提取可读的错误信息，兼容 {"error":"..."} 与 {"error":{"message":"..."}}
+func errorMessageFromBody(data []byte) string {
+	var body struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(data, &body); err == nil && len(body.Error) > 0 {
+		var message string
+		if err := json.Unmarshal(body.Error, &message); err == nil {
+			return message
+		}
+		var detail struct {
+			Message string `json:"message"`
+		}
+		if err := json.Unmarshal(body.Error, &detail); err == nil && detail.Message != "" {
+			return detail.Message
+		}
+	}
+
+	text := strings.TrimSpace(string(data))
+	if len(text) > 200 {
+		text = text[:200]
+	}
+	return text
+}
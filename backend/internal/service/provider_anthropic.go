@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+const (
+	defaultAnthropicBase      = "https://api.anthropic.com"
+	anthropicVersion          = "2023-06-01"
+	defaultAnthropicMaxTokens = 4096
+)
+
+// anthropicProvider Anthropic 风格的 messages 接口 POST /v1/messages
+type anthropicProvider struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+}
+
+func newAnthropicProvider(apiKey, apiBase string) *anthropicProvider {
+	if apiBase == "" {
+		apiBase = defaultAnthropicBase
+	}
+	return &anthropicProvider{
+		apiKey:  apiKey,
+		baseURL: strings.TrimRight(apiBase, "/"),
+		client:  &http.Client{},
+	}
+}
+
+func (p *anthropicProvider) Name() string {
+	return ProviderAnthropic
+}
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model       string               `json:"model"`
+	System      string               `json:"system,omitempty"`
+	Messages    []anthropicMessage   `json:"messages"`
+	MaxTokens   int                  `json:"max_tokens"`
+	Temperature float32              `json:"temperature"`
+	Tools       []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice  *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema *jsonschema.Definition `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type anthropicResponse struct {
+	Model   string `json:"model"`
+	Content []struct {
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		Input json.RawMessage `json:"input"` // tool_use 块的参数
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+func (p *anthropicProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	body := anthropicRequest{
+		Model:       req.Model,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+	}
+	if body.MaxTokens <= 0 {
+		// messages 接口要求必须指定 max_tokens
+		body.MaxTokens = defaultAnthropicMaxTokens
+	}
+	// system 消息单独传递，其余消息按原顺序放入 messages
+	var system []string
+	for _, m := range req.Messages {
+		if m.Role == "system" {
+			system = append(system, m.Content)
+			continue
+		}
+		body.Messages = append(body.Messages, anthropicMessage{Role: m.Role, Content: m.Content})
+	}
+	body.System = strings.Join(system, "\n\n")
+	// messages 接口没有 JSON mode，结构化输出一律通过强制调用工具实现
+	if req.Schema != nil && req.JSONMode != JSONModeOff {
+		body.Tools = []anthropicTool{{
+			Name:        req.Schema.Name,
+			Description: req.Schema.Description,
+			InputSchema: &req.Schema.Definition,
+		}}
+		body.ToolChoice = &anthropicToolChoice{Type: "tool", Name: req.Schema.Name}
+	}
+
+	headers := map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": anthropicVersion,
+	}
+	var resp anthropicResponse
+	if err := postJSON(ctx, p.client, p.Name(), p.baseURL+"/v1/messages", headers, body, &resp); err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	var toolInput json.RawMessage
+	for _, block := range resp.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			if toolInput == nil {
+				toolInput = block.Input
+			}
+		}
+	}
+	content := text.String()
+	if body.ToolChoice != nil && len(toolInput) > 0 {
+		content = string(toolInput)
+	}
+	if content == "" {
+		return nil, fmt.Errorf("Anthropic API返回空响应")
+	}
+
+	return &ChatResponse{
+		Content:          content,
+		Model:            resp.Model,
+		PromptTokens:     resp.Usage.InputTokens,
+		CompletionTokens: resp.Usage.OutputTokens,
+	}, nil
+}
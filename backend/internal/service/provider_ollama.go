@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+const defaultOllamaBase = "http://localhost:11434"
+
+// ollamaProvider Ollama 原生接口 POST /api/chat
+type ollamaProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+func newOllamaProvider(apiBase string) *ollamaProvider {
+	if apiBase == "" {
+		apiBase = defaultOllamaBase
+	}
+	return &ollamaProvider{
+		baseURL: strings.TrimRight(apiBase, "/"),
+		client:  &http.Client{},
+	}
+}
+
+func (p *ollamaProvider) Name() string {
+	return ProviderOllama
+}
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaChatRequest struct {
+	Model    string                 `json:"model"`
+	Messages []ollamaMessage        `json:"messages"`
+	Stream   bool                   `json:"stream"`
+	Format   interface{}            `json:"format,omitempty"` // "json" 或 JSON Schema
+	Options  map[string]interface{} `json:"options,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+}
+
+func (p *ollamaProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	body := ollamaChatRequest{
+		Model:   req.Model,
+		Stream:  false,
+		Options: map[string]interface{}{"temperature": req.Temperature},
+	}
+	if req.MaxTokens > 0 {
+		body.Options["num_predict"] = req.MaxTokens
+	}
+	for _, m := range req.Messages {
+		body.Messages = append(body.Messages, ollamaMessage{Role: m.Role, Content: m.Content})
+	}
+	// Ollama 的 format 参数直接支持 JSON Schema，函数调用也以此实现
+	if req.Schema != nil {
+		switch req.JSONMode {
+		case JSONModeObject:
+			body.Format = "json"
+		case JSONModeOff:
+		default:
+			schema, err := json.Marshal(&req.Schema.Definition)
+			if err != nil {
+				return nil, err
+			}
+			body.Format = json.RawMessage(schema)
+		}
+	}
+
+	var resp ollamaChatResponse
+	if err := postJSON(ctx, p.client, p.Name(), p.baseURL+"/api/chat", nil, body, &resp); err != nil {
+		return nil, err
+	}
+
+	return &ChatResponse{
+		Content:          resp.Message.Content,
+		Model:            resp.Model,
+		PromptTokens:     resp.PromptEvalCount,
+		CompletionTokens: resp.EvalCount,
+	}, nil
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// openaiProvider OpenAI 兼容接口，基于 go-openai
+type openaiProvider struct {
+	client *openai.Client
+}
+
+var _ streamingProvider = (*openaiProvider)(nil)
+
+func newOpenAIProvider(apiKey, apiBase string) *openaiProvider {
+	clientConfig := openai.DefaultConfig(apiKey)
+	if apiBase != "" {
+		clientConfig.BaseURL = apiBase
+	}
+	clientConfig.HTTPClient = &retryAfterDoer{client: &http.Client{}}
+
+	return &openaiProvider{client: openai.NewClientWithConfig(clientConfig)}
+}
+
+func (p *openaiProvider) Name() string {
+	return ProviderOpenAI
+}
+
+func (p *openaiProvider) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	request := p.chatRequest(req)
+
+	var retryAfter time.Duration
+	resp, err := p.client.CreateChatCompletion(context.WithValue(ctx, retryAfterKey{}, &retryAfter), request)
+	if err != nil {
+		return nil, p.wrapError(err, retryAfter)
+	}
+
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("OpenAI API返回空响应")
+	}
+
+	content := resp.Choices[0].Message.Content
+	if calls := resp.Choices[0].Message.ToolCalls; len(calls) > 0 && calls[0].Function.Arguments != "" {
+		content = calls[0].Function.Arguments
+	}
+
+	return &ChatResponse{
+		Content:          content,
+		Model:            resp.Model,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+	}, nil
+}
+
+// ChatStream 以流式方式调用，请求携带 stream_options 以在最后一个分片中获取用量；
+// 使用函数调用时增量内容为函数参数
+func (p *openaiProvider) ChatStream(ctx context.Context, req ChatRequest, onDelta func(delta string)) (*ChatResponse, error) {
+	request := p.chatRequest(req)
+	request.Stream = true
+	request.StreamOptions = &openai.StreamOptions{IncludeUsage: true}
+
+	var retryAfter time.Duration
+	stream, err := p.client.CreateChatCompletionStream(context.WithValue(ctx, retryAfterKey{}, &retryAfter), request)
+	if err != nil {
+		return nil, p.wrapError(err, retryAfter)
+	}
+	defer stream.Close()
+
+	var content, arguments strings.Builder
+	result := &ChatResponse{}
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, p.wrapError(err, retryAfter)
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.PromptTokens = chunk.Usage.PromptTokens
+			result.CompletionTokens = chunk.Usage.CompletionTokens
+		}
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		delta := chunk.Choices[0].Delta
+		if delta.Content != "" {
+			content.WriteString(delta.Content)
+			onDelta(delta.Content)
+		}
+		if len(delta.ToolCalls) > 0 && delta.ToolCalls[0].Function.Arguments != "" {
+			arguments.WriteString(delta.ToolCalls[0].Function.Arguments)
+			onDelta(delta.ToolCalls[0].Function.Arguments)
+		}
+	}
+
+	result.Content = content.String()
+	if arguments.Len() > 0 {
+		result.Content = arguments.String()
+	}
+	if result.Content == "" {
+		return nil, fmt.Errorf("OpenAI API返回空响应")
+	}
+	return result, nil
+}
+
+// chatRequest 转换为 go-openai 的请求
+func (p *openaiProvider) chatRequest(req ChatRequest) openai.ChatCompletionRequest {
+	messages := make([]openai.ChatCompletionMessage, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		messages = append(messages, openai.ChatCompletionMessage{Role: m.Role, Content: m.Content})
+	}
+
+	request := openai.ChatCompletionRequest{
+		Model:       req.Model,
+		Messages:    messages,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+	}
+	applyOpenAIJSONMode(&request, req)
+	return request
+}
+
+// applyOpenAIJSONMode 设置结构化输出参数；auto 使用兼容性最好的 JSON mode，
+// 多数 OpenAI 兼容服务（Moonshot、DeepSeek 等）支持 json_object 但不一定支持 json_schema
+func applyOpenAIJSONMode(request *openai.ChatCompletionRequest, req ChatRequest) {
+	if req.Schema == nil {
+		return
+	}
+	switch req.JSONMode {
+	case JSONModeSchema:
+		request.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:        req.Schema.Name,
+				Description: req.Schema.Description,
+				Schema:      &req.Schema.Definition,
+				Strict:      true,
+			},
+		}
+	case JSONModeTool:
+		request.Tools = []openai.Tool{{
+			Type: openai.ToolTypeFunction,
+			Function: &openai.FunctionDefinition{
+				Name:        req.Schema.Name,
+				Description: req.Schema.Description,
+				Parameters:  &req.Schema.Definition,
+			},
+		}}
+		request.ToolChoice = openai.ToolChoice{Type: openai.ToolTypeFunction, Function: openai.ToolFunction{Name: req.Schema.Name}}
+	case JSONModeOff:
+	default:
+		request.ResponseFormat = &openai.ChatCompletionResponseFormat{Type: openai.ChatCompletionResponseFormatTypeJSONObject}
+	}
+}
+
+// wrapError 将 go-openai 的HTTP错误转换为 ProviderError，传输层错误原样返回
+func (p *openaiProvider) wrapError(err error, retryAfter time.Duration) error {
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		return &ProviderError{Provider: p.Name(), StatusCode: apiErr.HTTPStatusCode, Message: apiErr.Message, RetryAfter: retryAfter, Err: err}
+	case errors.As(err, &reqErr):
+		return &ProviderError{Provider: p.Name(), StatusCode: reqErr.HTTPStatusCode, Message: reqErr.Error(), RetryAfter: retryAfter, Err: err}
+	default:
+		return err
+	}
+}
+
+// retryAfterKey 在请求上下文中携带 Retry-After 的接收者
+type retryAfterKey struct{}
+
+// retryAfterDoer 包装HTTP客户端，记录限流/不可用响应中的 Retry-After
+//
+// go-openai 的错误类型不包含响应头，只能在传输层截获。
+type retryAfterDoer struct {
+	client openai.HTTPDoer
+}
+
+func (d *retryAfterDoer) Do(req *http.Request) (*http.Response, error) {
+	resp, err := d.client.Do(req)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if holder, ok := req.Context().Value(retryAfterKey{}).(*time.Duration); ok {
+			if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				*holder = wait
+			}
+		}
+	}
+	return resp, err
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"article-analysis/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProvider(t *testing.T) {
+	for _, name := range []string{"", ProviderOpenAI, ProviderOllama, ProviderAnthropic} {
+		provider, err := NewProvider(config.ModelProfile{Provider: name})
+		require.NoError(t, err)
+		if name != "" {
+			assert.Equal(t, name, provider.Name())
+		}
+	}
+
+	_, err := NewProvider(config.ModelProfile{Provider: "unknown"})
+	assert.Error(t, err)
+}
+
+func TestOllamaProvider_Chat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/chat", r.URL.Path)
+
+		var body ollamaChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "qwen2.5", body.Model)
+		assert.False(t, body.Stream)
+		require.Len(t, body.Messages, 2)
+		assert.Equal(t, "system", body.Messages[0].Role)
+		schema, ok := body.Format.(map[string]interface{})
+		require.True(t, ok, "默认以 JSON Schema 约束输出")
+		assert.Equal(t, "object", schema["type"])
+
+		fmt.Fprint(w, `{"model":"qwen2.5","message":{"role":"assistant","content":"你好"},"done":true,"prompt_eval_count":12,"eval_count":3}`)
+	}))
+	defer server.Close()
+
+	provider := newOllamaProvider(server.URL)
+	resp, err := provider.Chat(context.Background(), ChatRequest{
+		Model:    "qwen2.5",
+		Messages: []ChatMessage{{Role: "system", Content: "系统"}, {Role: "user", Content: "问题"}},
+		Schema:   &analysisResultSchema,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "你好", resp.Content)
+	assert.Equal(t, 12, resp.PromptTokens)
+	assert.Equal(t, 3, resp.CompletionTokens)
+}
+
+func TestAnthropicProvider_Chat(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/messages", r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("x-api-key"))
+		assert.Equal(t, anthropicVersion, r.Header.Get("anthropic-version"))
+
+		var body anthropicRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "系统", body.System, "system 消息应单独传递")
+		require.Len(t, body.Messages, 1)
+		assert.Equal(t, "user", body.Messages[0].Role)
+		assert.Equal(t, defaultAnthropicMaxTokens, body.MaxTokens)
+
+		fmt.Fprint(w, `{"model":"claude-test","content":[{"type":"text","text":"回答"}],"usage":{"input_tokens":20,"output_tokens":5}}`)
+	}))
+	defer server.Close()
+
+	provider := newAnthropicProvider("test-key", server.URL)
+	resp, err := provider.Chat(context.Background(), ChatRequest{
+		Model:    "claude-test",
+		Messages: []ChatMessage{{Role: "system", Content: "系统"}, {Role: "user", Content: "问题"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "回答", resp.Content)
+	assert.Equal(t, 20, resp.PromptTokens)
+	assert.Equal(t, 5, resp.CompletionTokens)
+}
+
+func TestAnthropicProvider_ToolUseForStructuredOutput(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body anthropicRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Len(t, body.Tools, 1)
+		assert.Equal(t, analysisResultSchema.Name, body.Tools[0].Name)
+		require.NotNil(t, body.ToolChoice)
+		assert.Equal(t, "tool", body.ToolChoice.Type)
+
+		fmt.Fprint(w, `{"model":"claude-test","content":[{"type":"text","text":"好的"},{"type":"tool_use","id":"t1","name":"article_analysis","input":{"core_viewpoints":"观点"}}],"usage":{"input_tokens":20,"output_tokens":5}}`)
+	}))
+	defer server.Close()
+
+	provider := newAnthropicProvider("test-key", server.URL)
+	resp, err := provider.Chat(context.Background(), ChatRequest{
+		Model:    "claude-test",
+		Messages: []ChatMessage{{Role: "user", Content: "问题"}},
+		Schema:   &analysisResultSchema,
+		JSONMode: JSONModeAuto,
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"core_viewpoints":"观点"}`, resp.Content, "工具参数作为结果返回")
+}
+
+func TestAnthropicProvider_RateLimitError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`)
+	}))
+	defer server.Close()
+
+	provider := newAnthropicProvider("test-key", server.URL)
+	_, err := provider.Chat(context.Background(), ChatRequest{Model: "claude-test", Messages: []ChatMessage{{Role: "user", Content: "问题"}}})
+
+	var providerErr *ProviderError
+	require.True(t, errors.As(err, &providerErr))
+	assert.Equal(t, http.StatusTooManyRequests, providerErr.StatusCode)
+	assert.Equal(t, "slow down", providerErr.Message)
+	assert.Equal(t, 7*time.Second, providerErr.RetryAfter)
+
+	retryable, _ := classifyError(err)
+	assert.True(t, retryable)
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// LLMAttempt 一次模型调用尝试的记录
+type LLMAttempt struct {
+	Attempt    int
+	Profile    string // 本次调用的模型配置，发生降级时与任务请求的配置不同
+	StatusCode int
+	Retryable  bool
+	Err        error
+	Duration   time.Duration
+	Wait       time.Duration // 下一次重试前的等待，不再重试时为 0
+
+	// 调用成功时服务端返回的用量
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// AttemptObserver 接收每次模型调用尝试的结果
+type AttemptObserver func(attempt LLMAttempt)
+
+type attemptObserverKey struct{}
+
+// WithAttemptObserver 返回携带尝试观察者的上下文，模型调用的每次尝试都会回调该函数
+func WithAttemptObserver(ctx context.Context, observer AttemptObserver) context.Context {
+	return context.WithValue(ctx, attemptObserverKey{}, observer)
+}
+
+func notifyAttempt(ctx context.Context, attempt LLMAttempt) {
+	if observer, ok := ctx.Value(attemptObserverKey{}).(AttemptObserver); ok && observer != nil {
+		observer(attempt)
+	}
+}
+
+// retryPolicy 指数退避重试策略
+type retryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+// backoff 计算第 n 次重试（从1开始）前的等待时间：
+// 以 baseDelay*2^(n-1) 为上限，在其一半到全部之间随机取值，并不超过 maxDelay
+func (p retryPolicy) backoff(n int) time.Duration {
+	delay := p.baseDelay
+	for i := 1; i < n && delay < p.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.maxDelay {
+		delay = p.maxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// classifyError 判断模型调用错误是否值得重试，并返回HTTP状态码（无则为0）
+//
+// 只有超时、网络错误、连接中断等传输层问题和 429、5xx 可重试；鉴权失败、请求参数错误等 4xx 错误，
+// 以及响应解析失败、结果不符合格式、空响应等错误重试也不会成功。
+func classifyError(err error) (retryable bool, statusCode int) {
+	if err == nil {
+		return false, 0
+	}
+	if errors.Is(err, context.Canceled) {
+		return false, 0
+	}
+
+	var providerErr *ProviderError
+	if errors.As(err, &providerErr) && providerErr.StatusCode > 0 {
+		return retryableStatus(providerErr.StatusCode), providerErr.StatusCode
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr) {
+		return true, 0
+	}
+	return false, 0
+}
+
+func retryableStatus(code int) bool {
+	switch {
+	case code == http.StatusTooManyRequests, code == http.StatusRequestTimeout:
+		return true
+	case code >= 500:
+		return true
+	default:
+		return false
+	}
+}
+
+// parseRetryAfter 解析 Retry-After 头，支持秒数和HTTP日期两种格式
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		wait := at.Sub(now)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// sleepContext 等待指定时长，上下文取消时提前返回错误
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"article-analysis/internal/config"
+	"article-analysis/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testAnalysisJSON 每个维度一条只有标题的要点，生成的文本即为标题
+const testAnalysisJSON = `{\"core_viewpoints\":[{\"title\":\"观点\",\"explanation\":\"\",\"quote\":\"\"}],` +
+	`\"file_structure\":[{\"title\":\"结构\",\"explanation\":\"\",\"quote\":\"\"}],` +
+	`\"author_thoughts\":[{\"title\":\"思路\",\"explanation\":\"\",\"quote\":\"\"}],` +
+	`\"related_materials\":[{\"title\":\"素材\",\"explanation\":\"\",\"quote\":\"\"}]}`
+
+// writeChatCompletion 写出一个OpenAI兼容的聊天补全响应
+func writeChatCompletion(w http.ResponseWriter, content string) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"id":"1","object":"chat.completion","model":"test","choices":[{"index":0,"message":{"role":"assistant","content":"%s"},"finish_reason":"stop"}]}`, content)
+}
+
+func newRetryTestClient(baseURL string, maxRetries int) *OpenAIClient {
+	cfg := &config.Config{
+		OpenAI: config.OpenAIConfig{
+			APIKey:         "test-api-key",
+			APIBase:        baseURL,
+			Model:          "test-model",
+			MaxRetries:     maxRetries,
+			RetryBaseDelay: 1,
+			RetryMaxDelay:  10,
+		},
+	}
+	return NewOpenAIClient(cfg, logger.NewLogger("error"))
+}
+
+func TestOpenAIClient_RetriesRateLimitWithRetryAfter(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error":{"message":"rate limited","type":"rate_limit_reached_error"}}`)
+			return
+		}
+		writeChatCompletion(w, testAnalysisJSON)
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(server.URL, 3)
+
+	var attempts []LLMAttempt
+	ctx := WithAttemptObserver(context.Background(), func(a LLMAttempt) {
+		attempts = append(attempts, a)
+	})
+
+	start := time.Now()
+	result, err := client.AnalyzeArticle(ctx, "测试文章")
+	require.NoError(t, err)
+	assert.Equal(t, "观点", result.CoreViewpoints)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second, "应按 Retry-After 等待")
+
+	require.Len(t, attempts, 2)
+	assert.Equal(t, http.StatusTooManyRequests, attempts[0].StatusCode)
+	assert.True(t, attempts[0].Retryable)
+	assert.Equal(t, time.Second, attempts[0].Wait)
+	assert.NoError(t, attempts[1].Err)
+}
+
+func TestOpenAIClient_DoesNotRetryAuthError(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":{"message":"invalid api key","type":"invalid_authentication_error"}}`)
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(server.URL, 3)
+	_, err := client.AnalyzeArticle(context.Background(), "测试文章")
+
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "鉴权错误不应重试")
+}
+
+func TestOpenAIClient_DoesNotRetryMalformedResponse(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"1","choices":[}`)
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(server.URL, 3)
+	var attempts []LLMAttempt
+	ctx := WithAttemptObserver(context.Background(), func(a LLMAttempt) {
+		attempts = append(attempts, a)
+	})
+	_, err := client.AnalyzeArticle(ctx, "测试文章")
+
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "响应无法解析时重试也不会成功")
+	require.Len(t, attempts, 1)
+	assert.False(t, attempts[0].Retryable)
+}
+
+func TestOpenAIClient_GivesUpAfterMaxRetries(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(server.URL, 2)
+	_, err := client.AnalyzeArticle(context.Background(), "测试文章")
+
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls), "首次调用加两次重试")
+}
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		name      string
+		err       error
+		retryable bool
+		status    int
+	}{
+		{"限流", &ProviderError{StatusCode: 429}, true, 429},
+		{"服务端错误", &ProviderError{StatusCode: 503}, true, 503},
+		{"鉴权失败", &ProviderError{StatusCode: 401}, false, 401},
+		{"参数错误", fmt.Errorf("wrap: %w", &ProviderError{StatusCode: 400}), false, 400},
+		{"取消", context.Canceled, false, 0},
+		{"超时", context.DeadlineExceeded, true, 0},
+		{"网络错误", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true, 0},
+		{"连接中断", fmt.Errorf("读取响应失败: %w", io.ErrUnexpectedEOF), true, 0},
+		{"解析失败", fmt.Errorf("解析响应失败: %w", json.Unmarshal([]byte(`{"choices":`), &struct{}{})), false, 0},
+		{"格式不符", fmt.Errorf("%w: 缺少字段", ErrInvalidOutput), false, 0},
+		{"空响应", errors.New("模型未返回内容"), false, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			retryable, status := classifyError(tc.err)
+			assert.Equal(t, tc.retryable, retryable)
+			assert.Equal(t, tc.status, status)
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	wait, ok := parseRetryAfter("5", now)
+	assert.True(t, ok)
+	assert.Equal(t, 5*time.Second, wait)
+
+	wait, ok = parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now)
+	assert.True(t, ok)
+	assert.Equal(t, 30*time.Second, wait)
+
+	_, ok = parseRetryAfter("", now)
+	assert.False(t, ok)
+	_, ok = parseRetryAfter("soon", now)
+	assert.False(t, ok)
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := retryPolicy{maxRetries: 5, baseDelay: 100 * time.Millisecond, maxDelay: time.Second}
+	for i := 0; i < 20; i++ {
+		first := policy.backoff(1)
+		assert.GreaterOrEqual(t, first, 50*time.Millisecond)
+		assert.LessOrEqual(t, first, 100*time.Millisecond)
+
+		third := policy.backoff(3)
+		assert.GreaterOrEqual(t, third, 200*time.Millisecond)
+		assert.LessOrEqual(t, third, 400*time.Millisecond)
+
+		assert.LessOrEqual(t, policy.backoff(10), time.Second)
+	}
+}
@@ -0,0 +1,364 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"article-analysis/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// 分析结果的审核状态
+const (
+	ReviewDraft     = "draft"     // 模型生成或修改后尚未审核，只有编辑可见
+	ReviewReviewed  = "reviewed"  // 审核通过，学生可见
+	ReviewPublished = "published" // 已定稿发布，学生可见
+)
+
+// 修改记录的类型
+const (
+	RevisionActionEdit   = "edit"   // 修改分析内容
+	RevisionActionReview = "review" // 变更审核状态
+)
+
+// maxRevisionComment 修改说明的最大长度（按字符计）
+const maxRevisionComment = 500
+
+var (
+	ErrAnalysisNotEditable     = errors.New("分析尚未完成，不能修改")
+	ErrReviewLocked            = errors.New("分析已审核，需退回草稿后才能修改")
+	ErrInvalidReviewTransition = errors.New("不支持的审核状态变更")
+	ErrRevisionConflict        = errors.New("分析结果已被他人修改，请刷新后重试")
+	ErrInvalidEdit             = errors.New("修改内容无效")
+)
+
+// reviewTransitions 每个审核状态允许变更到的状态；已审核或发布的结果需退回草稿才能修改
+var reviewTransitions = map[string][]string{
+	ReviewDraft:     {ReviewReviewed},
+	ReviewReviewed:  {ReviewPublished, ReviewDraft},
+	ReviewPublished: {ReviewDraft},
+}
+
+// studentVisibleStatuses 学生可以看到的审核状态
+var studentVisibleStatuses = []string{ReviewReviewed, ReviewPublished}
+
+// VisibleToStudents 该审核状态的分析结果是否向学生展示
+func VisibleToStudents(reviewStatus string) bool {
+	return containsString(studentVisibleStatuses, reviewStatus)
+}
+
+// AnalysisEdit 编辑对分析结果的一次修改
+type AnalysisEdit struct {
+	Editor     string                     // 修改人，由 API Key 识别的调用方标识
+	EditorName string                     // 修改人自报的显示名称，只用于展示
+	Fields     map[string]json.RawMessage // 按维度字段名给出修改后的值，未给出的维度保持不变
+	Revision   *int                       // 编辑看到的修订号，不为空时与当前修订号不一致则拒绝修改
+	Comment    string                     // 修改说明
+}
+
+// ReviewChange 一次审核状态变更
+type ReviewChange struct {
+	Editor     string
+	EditorName string
+	Status     string
+	Revision   *int
+	Comment    string
+}
+
+// currentAnalysis 获取文章当前的分析结果
+func (s *AnalysisService) currentAnalysis(articleID uint64) (*model.ArticleAnalysis, error) {
+	analysis, err := s.analysisRepo.GetByArticleID(articleID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAnalysisNotFound
+		}
+		return nil, fmt.Errorf("查询分析记录失败: %w", err)
+	}
+	return analysis, nil
+}
+
+// GetVisibleAnalysis 获取向学生展示的分析结果：审核通过的当前结果，当前结果未审核时为最近一次审核通过的结果
+func (s *AnalysisService) GetVisibleAnalysis(articleID uint64) (*model.ArticleAnalysis, error) {
+	analysis, err := s.analysisRepo.GetVisibleByArticleID(articleID, studentVisibleStatuses)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAnalysisNotFound
+		}
+		return nil, fmt.Errorf("查询分析记录失败: %w", err)
+	}
+	return analysis, nil
+}
+
+// UpdateAnalysis 修改文章当前的分析结果并记录修改前后的值
+//
+// 只能修改已完成且处于草稿状态的结果。修改后重新核对要点引文，由要点生成的文本字段随之更新；
+// 内容没有变化时不产生修改记录。
+func (s *AnalysisService) UpdateAnalysis(articleID uint64, edit AnalysisEdit) (*model.ArticleAnalysis, error) {
+	if len(edit.Fields) == 0 {
+		return nil, fmt.Errorf("%w：没有需要修改的维度", ErrInvalidEdit)
+	}
+	if err := checkRevisionComment(edit.Comment); err != nil {
+		return nil, err
+	}
+	analysis, err := s.currentAnalysis(articleID)
+	if err != nil {
+		return nil, err
+	}
+	if analysis.AnalysisStatus != "completed" {
+		return nil, ErrAnalysisNotEditable
+	}
+	if analysis.ReviewStatus != ReviewDraft {
+		return nil, ErrReviewLocked
+	}
+	if edit.Revision != nil && *edit.Revision != analysis.Revision {
+		return nil, ErrRevisionConflict
+	}
+	schema, err := s.analysisSchema(analysis)
+	if err != nil {
+		return nil, err
+	}
+
+	before := analysisFieldValues(analysis, schema)
+	after := make(map[string]json.RawMessage, len(before))
+	for key, value := range before {
+		after[key] = value
+	}
+	for key, value := range edit.Fields {
+		field, ok := schemaField(schema, key)
+		if !ok {
+			return nil, fmt.Errorf("%w：分析方案 %s 中没有维度 %s", ErrInvalidEdit, schema.Name, key)
+		}
+		normalized, err := normalizeFieldValue(field, value)
+		if err != nil {
+			return nil, err
+		}
+		after[key] = normalized
+	}
+
+	content := analysis.Article.Content
+	if isDefaultSchema(schema) {
+		result := &model.AnalysisResult{}
+		for key, target := range resultDimensions(result) {
+			json.Unmarshal(after[key], target)
+		}
+		groundResult(content, result)
+		analysis.Result = result
+		analysis.CoreViewpoints = model.FormatItems(result.CoreViewpoints)
+		analysis.FileStructure = model.FormatItems(result.FileStructure)
+		analysis.AuthorThoughts = model.FormatItems(result.AuthorThoughts)
+		analysis.RelatedMaterials = model.FormatItems(result.RelatedMaterials)
+		after = analysisFieldValues(analysis, schema)
+	} else {
+		fields := make(model.AnalysisFields, len(after))
+		for key, value := range after {
+			fields[key] = value
+		}
+		groundFields(content, schema, fields)
+		analysis.Fields = fields
+		after = fields
+	}
+
+	var changes model.FieldChanges
+	for _, field := range schema.Fields {
+		if !bytes.Equal(before[field.Key], after[field.Key]) {
+			changes = append(changes, model.FieldChange{Field: field.Key, Before: before[field.Key], After: after[field.Key]})
+		}
+	}
+	if len(changes) == 0 {
+		return analysis, nil
+	}
+
+	revision := &model.AnalysisRevision{
+		AnalysisID: analysis.ID,
+		ArticleID:  articleID,
+		Revision:   analysis.Revision + 1,
+		Editor:     edit.Editor,
+		EditorName: edit.EditorName,
+		Action:     RevisionActionEdit,
+		Changes:    changes,
+		Comment:    strings.TrimSpace(edit.Comment),
+	}
+	if err := s.saveRevision(analysis, revision); err != nil {
+		return nil, err
+	}
+	return analysis, nil
+}
+
+// SetReviewStatus 变更文章当前分析结果的审核状态并记录
+func (s *AnalysisService) SetReviewStatus(articleID uint64, change ReviewChange) (*model.ArticleAnalysis, error) {
+	if err := checkRevisionComment(change.Comment); err != nil {
+		return nil, err
+	}
+	analysis, err := s.currentAnalysis(articleID)
+	if err != nil {
+		return nil, err
+	}
+	if analysis.AnalysisStatus != "completed" {
+		return nil, ErrAnalysisNotEditable
+	}
+	if change.Revision != nil && *change.Revision != analysis.Revision {
+		return nil, ErrRevisionConflict
+	}
+	if !containsString(reviewTransitions[analysis.ReviewStatus], change.Status) {
+		return nil, fmt.Errorf("%w：%s → %s", ErrInvalidReviewTransition, analysis.ReviewStatus, change.Status)
+	}
+
+	before, _ := json.Marshal(analysis.ReviewStatus)
+	after, _ := json.Marshal(change.Status)
+	analysis.ReviewStatus = change.Status
+	revision := &model.AnalysisRevision{
+		AnalysisID: analysis.ID,
+		ArticleID:  articleID,
+		Revision:   analysis.Revision + 1,
+		Editor:     change.Editor,
+		EditorName: change.EditorName,
+		Action:     RevisionActionReview,
+		Changes:    model.FieldChanges{{Field: "review_status", Before: before, After: after}},
+		Comment:    strings.TrimSpace(change.Comment),
+	}
+	if err := s.saveRevision(analysis, revision); err != nil {
+		return nil, err
+	}
+	return analysis, nil
+}
+
+// ListRevisions 按时间倒序列出文章当前分析结果的修改记录
+func (s *AnalysisService) ListRevisions(articleID uint64) ([]model.AnalysisRevision, error) {
+	analysis, err := s.currentAnalysis(articleID)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := s.analysisRepo.ListRevisions(analysis.ID)
+	if err != nil {
+		return nil, fmt.Errorf("查询修改记录失败: %w", err)
+	}
+	return revisions, nil
+}
+
+// saveRevision 保存修改，期间分析记录被他人修改时返回 ErrRevisionConflict
+func (s *AnalysisService) saveRevision(analysis *model.ArticleAnalysis, revision *model.AnalysisRevision) error {
+	saved, err := s.analysisRepo.SaveRevision(analysis, revision)
+	if err != nil {
+		s.log.Error("保存分析修改失败", err)
+		return fmt.Errorf("保存分析修改失败: %w", err)
+	}
+	if !saved {
+		return ErrRevisionConflict
+	}
+	analysis.Revision = revision.Revision
+	return nil
+}
+
+func checkRevisionComment(comment string) error {
+	if len([]rune(strings.TrimSpace(comment))) > maxRevisionComment {
+		return fmt.Errorf("%w：修改说明不能超过 %d 字", ErrInvalidEdit, maxRevisionComment)
+	}
+	return nil
+}
+
+func schemaField(schema *model.AnalysisSchema, key string) (model.SchemaField, bool) {
+	for _, field := range schema.Fields {
+		if field.Key == key {
+			return field, true
+		}
+	}
+	return model.SchemaField{}, false
+}
+
+// resultDimensions 内置方案各维度在结构化结果中对应的要点列表
+func resultDimensions(result *model.AnalysisResult) map[string]*[]model.AnalysisItem {
+	return map[string]*[]model.AnalysisItem{
+		"core_viewpoints":   &result.CoreViewpoints,
+		"file_structure":    &result.FileStructure,
+		"author_thoughts":   &result.AuthorThoughts,
+		"related_materials": &result.RelatedMaterials,
+	}
+}
+
+// analysisFieldValues 按维度字段名返回分析结果各维度的值。早期只有文本的结果，
+// 每个维度的文本视为一条要点的说明，修改后按要点重新生成文本
+func analysisFieldValues(analysis *model.ArticleAnalysis, schema *model.AnalysisSchema) map[string]json.RawMessage {
+	values := make(map[string]json.RawMessage, len(schema.Fields))
+	if !isDefaultSchema(schema) {
+		for _, field := range schema.Fields {
+			if value := analysis.Fields[field.Key]; value != nil {
+				values[field.Key] = value
+			}
+		}
+		return values
+	}
+
+	result := analysis.Result
+	if result == nil {
+		result = &model.AnalysisResult{}
+		texts := map[string]string{
+			"core_viewpoints":   analysis.CoreViewpoints,
+			"file_structure":    analysis.FileStructure,
+			"author_thoughts":   analysis.AuthorThoughts,
+			"related_materials": analysis.RelatedMaterials,
+		}
+		for key, items := range resultDimensions(result) {
+			if text := strings.TrimSpace(texts[key]); text != "" {
+				*items = []model.AnalysisItem{{Explanation: text}}
+			}
+		}
+	}
+	for key, items := range resultDimensions(result) {
+		list := *items
+		if list == nil {
+			list = []model.AnalysisItem{}
+		}
+		values[key], _ = json.Marshal(list)
+	}
+	return values
+}
+
+// normalizeFieldValue 按维度类型校验修改后的值，去除首尾空白并丢弃空要点，返回重新编码的值；
+// 要点的引文位置由服务端重新核对，提交的 evidence、verified 忽略
+func normalizeFieldValue(field model.SchemaField, value json.RawMessage) (json.RawMessage, error) {
+	invalid := func(want string) error {
+		return fmt.Errorf("%w：维度 %s 应为%s", ErrInvalidEdit, field.Key, want)
+	}
+	switch field.Type {
+	case model.FieldTypeText:
+		var text string
+		if err := json.Unmarshal(value, &text); err != nil {
+			return nil, invalid("字符串")
+		}
+		return json.Marshal(strings.TrimSpace(text))
+	case model.FieldTypeList:
+		var list []string
+		if err := json.Unmarshal(value, &list); err != nil {
+			return nil, invalid("字符串数组")
+		}
+		kept := []string{}
+		for _, item := range list {
+			if item = strings.TrimSpace(item); item != "" {
+				kept = append(kept, item)
+			}
+		}
+		return json.Marshal(kept)
+	default:
+		var items []model.AnalysisItem
+		if err := json.Unmarshal(value, &items); err != nil {
+			return nil, invalid("要点数组，每条要点包含 title、explanation 和 quote")
+		}
+		kept := []model.AnalysisItem{}
+		for _, item := range items {
+			item = model.AnalysisItem{
+				Title:       strings.TrimSpace(item.Title),
+				Explanation: strings.TrimSpace(item.Explanation),
+				Quote:       strings.TrimSpace(item.Quote),
+			}
+			if item.Title == "" && item.Explanation == "" {
+				continue
+			}
+			kept = append(kept, item)
+		}
+		return json.Marshal(kept)
+	}
+}
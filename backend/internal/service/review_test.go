@@ -0,0 +1,128 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"article-analysis/internal/model"
+	"article-analysis/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalysisService_EditAndReviewAnalysis(t *testing.T) {
+	analyzer := &MockOpenAIClient{}
+	s, taskRepo, db := newTestAnalysisServiceWithAnalyzer(t, nil, analyzer)
+
+	content := "城市的夜晚灯火通明。作者认为阅读让人安静下来。"
+	article := &model.Article{Title: "文章", Author: "张三", Content: content, FilePath: "f.txt"}
+	require.NoError(t, repository.NewArticleRepository(db).Create(article))
+
+	analyzer.On("AnalyzeArticle", mock.Anything, content).Return(&AnalysisResponse{
+		CoreViewpoints: "阅读使人安静",
+		Result: model.AnalysisResult{
+			CoreViewpoints: []model.AnalysisItem{{Title: "阅读使人安静", Quote: "阅读让人安静下来"}},
+			FileStructure:  []model.AnalysisItem{{Title: "先写景后议论"}},
+		},
+	}, nil)
+	submitted, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{})
+	require.NoError(t, err)
+	runUntilFinished(t, s, taskRepo, submitted.TaskID)
+
+	_, err = s.GetVisibleAnalysis(article.ID)
+	assert.ErrorIs(t, err, ErrAnalysisNotFound, "草稿不向学生展示")
+
+	stale := 0
+	edited, err := s.UpdateAnalysis(article.ID, AnalysisEdit{
+		Editor:     RequesterForAPIKey("wang-key"),
+		EditorName: "王老师",
+		Revision:   &stale,
+		Comment:    "补充引文",
+		Fields: map[string]json.RawMessage{
+			"file_structure": json.RawMessage(`[{"title":" 先写景，后议论 ","quote":"城市的夜晚灯火通明"},{"title":""}]`),
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, edited.Revision)
+	require.Len(t, edited.Result.FileStructure, 1, "丢弃空要点")
+	assert.Equal(t, "先写景，后议论", edited.Result.FileStructure[0].Title)
+	assert.True(t, edited.Result.FileStructure[0].Verified, "修改后重新核对引文")
+	assert.Equal(t, "先写景，后议论（原文：“城市的夜晚灯火通明”）", edited.FileStructure)
+	assert.Equal(t, "阅读使人安静", edited.Result.CoreViewpoints[0].Title, "未修改的维度保持不变")
+
+	_, err = s.UpdateAnalysis(article.ID, AnalysisEdit{Revision: &stale, Fields: map[string]json.RawMessage{
+		"core_viewpoints": json.RawMessage(`[]`),
+	}})
+	assert.ErrorIs(t, err, ErrRevisionConflict)
+	_, err = s.UpdateAnalysis(article.ID, AnalysisEdit{Fields: map[string]json.RawMessage{"keywords": json.RawMessage(`[]`)}})
+	assert.ErrorIs(t, err, ErrInvalidEdit)
+	_, err = s.UpdateAnalysis(article.ID, AnalysisEdit{Fields: map[string]json.RawMessage{"core_viewpoints": json.RawMessage(`"一段文字"`)}})
+	assert.ErrorIs(t, err, ErrInvalidEdit)
+
+	_, err = s.SetReviewStatus(article.ID, ReviewChange{Editor: "李主任", Status: ReviewPublished})
+	assert.ErrorIs(t, err, ErrInvalidReviewTransition, "草稿需先审核")
+	reviewed, err := s.SetReviewStatus(article.ID, ReviewChange{Editor: RequesterForAPIKey("li-key"), EditorName: "李主任", Status: ReviewReviewed, Comment: "通过"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, reviewed.Revision)
+
+	visible, err := s.GetVisibleAnalysis(article.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "先写景，后议论（原文：“城市的夜晚灯火通明”）", visible.FileStructure)
+
+	_, err = s.UpdateAnalysis(article.ID, AnalysisEdit{Fields: map[string]json.RawMessage{"core_viewpoints": json.RawMessage(`[]`)}})
+	assert.ErrorIs(t, err, ErrReviewLocked)
+
+	revisions, err := s.ListRevisions(article.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, RevisionActionReview, revisions[0].Action)
+	assert.Equal(t, RequesterForAPIKey("li-key"), revisions[0].Editor)
+	assert.Equal(t, "李主任", revisions[0].EditorName)
+	assert.JSONEq(t, `[{"field":"review_status","before":"draft","after":"reviewed"}]`, mustJSON(t, revisions[0].Changes))
+
+	edit := revisions[1]
+	assert.Equal(t, RevisionActionEdit, edit.Action)
+	assert.Equal(t, RequesterForAPIKey("wang-key"), edit.Editor)
+	assert.Equal(t, "王老师", edit.EditorName)
+	assert.Equal(t, "补充引文", edit.Comment)
+	require.Len(t, edit.Changes, 1, "只记录有变化的维度")
+	assert.Equal(t, "file_structure", edit.Changes[0].Field)
+	var before, after []model.AnalysisItem
+	require.NoError(t, json.Unmarshal(edit.Changes[0].Before, &before))
+	require.NoError(t, json.Unmarshal(edit.Changes[0].After, &after))
+	assert.Equal(t, "先写景后议论", before[0].Title)
+	assert.Equal(t, "先写景，后议论", after[0].Title)
+}
+
+func TestAnalysisService_EditLegacyAnalysis(t *testing.T) {
+	s, _, db := newTestAnalysisService(t)
+
+	article := &model.Article{Title: "文章", Author: "张三", Content: "早期文章", FilePath: "f.txt"}
+	require.NoError(t, repository.NewArticleRepository(db).Create(article))
+	require.NoError(t, repository.NewAnalysisRepository(db).Create(&model.ArticleAnalysis{
+		ArticleID: article.ID, Version: 1, IsCurrent: true, AnalysisStatus: "completed",
+		CoreViewpoints: "1. 观点一 2. 观点二", FileStructure: "总分总",
+	}))
+
+	edited, err := s.UpdateAnalysis(article.ID, AnalysisEdit{Fields: map[string]json.RawMessage{
+		"author_thoughts": json.RawMessage(`[{"title":"由景入理"}]`),
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "1. 观点一 2. 观点二", edited.CoreViewpoints, "只有文本的维度保持原文")
+	assert.Equal(t, "总分总", edited.FileStructure)
+	assert.Equal(t, "由景入理", edited.AuthorThoughts)
+
+	revisions, err := s.ListRevisions(article.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	require.Len(t, revisions[0].Changes, 1)
+	assert.Equal(t, "author_thoughts", revisions[0].Changes[0].Field)
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(data)
+}
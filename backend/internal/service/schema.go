@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"article-analysis/internal/model"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// DefaultSchemaName 内置分析方案的名称：核心观点、文件结构、作者思路、相关素材与事例四个维度
+const DefaultSchemaName = "default"
+
+// maxSchemaFields 一个分析方案最多包含的维度数
+const maxSchemaFields = 20
+
+var (
+	ErrUnknownSchema = errors.New("分析方案不存在")
+	ErrInvalidSchema = errors.New("分析方案无效")
+	ErrSchemaExists  = errors.New("分析方案已存在")
+)
+
+var (
+	schemaNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,99}$`)
+	fieldKeyPattern   = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+)
+
+// defaultAnalysisSchema 内置分析方案，结果保存在 ArticleAnalysis.Result 及对应的文本字段中
+var defaultAnalysisSchema = &model.AnalysisSchema{
+	Name:        DefaultSchemaName,
+	Description: "内置分析方案",
+	Fields: model.SchemaFields{
+		{Key: "core_viewpoints", Label: "核心观点", Description: "总结文章的主要观点和核心论点", Type: model.FieldTypeItems},
+		{Key: "file_structure", Label: "文件结构", Description: "分析文章的结构组织方式", Type: model.FieldTypeItems},
+		{Key: "author_thoughts", Label: "作者思路", Description: "分析作者的写作思路和逻辑脉络", Type: model.FieldTypeItems},
+		{Key: "related_materials", Label: "相关素材与事例", Description: "提取文章中的重要素材、案例和论据", Type: model.FieldTypeItems},
+	},
+}
+
+func isDefaultSchema(schema *model.AnalysisSchema) bool {
+	return schema == nil || schema.Name == DefaultSchemaName
+}
+
+// validateAnalysisSchema 检查管理员提交的分析方案，并将未填写的维度类型设为 items
+func validateAnalysisSchema(schema *model.AnalysisSchema) error {
+	schema.Name = strings.TrimSpace(schema.Name)
+	if !schemaNamePattern.MatchString(schema.Name) {
+		return fmt.Errorf("%w：名称应以小写字母开头，由小写字母、数字、下划线和连字符组成", ErrInvalidSchema)
+	}
+	if schema.Name == DefaultSchemaName {
+		return fmt.Errorf("%w：%s 为内置方案名称", ErrInvalidSchema, DefaultSchemaName)
+	}
+	if len(schema.Fields) == 0 || len(schema.Fields) > maxSchemaFields {
+		return fmt.Errorf("%w：维度数量应为 1 到 %d 个", ErrInvalidSchema, maxSchemaFields)
+	}
+
+	seen := make(map[string]bool)
+	for i := range schema.Fields {
+		field := &schema.Fields[i]
+		field.Key = strings.TrimSpace(field.Key)
+		field.Label = strings.TrimSpace(field.Label)
+		if !fieldKeyPattern.MatchString(field.Key) {
+			return fmt.Errorf("%w：字段名 %q 应以小写字母开头，由小写字母、数字和下划线组成", ErrInvalidSchema, field.Key)
+		}
+		if seen[field.Key] {
+			return fmt.Errorf("%w：字段名 %s 重复", ErrInvalidSchema, field.Key)
+		}
+		seen[field.Key] = true
+		if field.Label == "" {
+			return fmt.Errorf("%w：维度 %s 缺少显示名称", ErrInvalidSchema, field.Key)
+		}
+		switch field.Type {
+		case "":
+			field.Type = model.FieldTypeItems
+		case model.FieldTypeItems, model.FieldTypeText, model.FieldTypeList:
+		default:
+			return fmt.Errorf("%w：维度 %s 的类型 %q 不支持，应为 items、text 或 list", ErrInvalidSchema, field.Key, field.Type)
+		}
+	}
+	return nil
+}
+
+// responseSchema 分析方案对应的结果 JSON Schema，用于结构化输出和校验
+func responseSchema(schema *model.AnalysisSchema) *ResponseSchema {
+	if isDefaultSchema(schema) {
+		return &analysisResultSchema
+	}
+
+	def := jsonschema.Definition{
+		Type:                 jsonschema.Object,
+		Properties:           make(map[string]jsonschema.Definition, len(schema.Fields)),
+		AdditionalProperties: false,
+	}
+	for _, field := range schema.Fields {
+		var property jsonschema.Definition
+		switch field.Type {
+		case model.FieldTypeText:
+			property = jsonschema.Definition{Type: jsonschema.String}
+		case model.FieldTypeList:
+			property = jsonschema.Definition{Type: jsonschema.Array, Items: &jsonschema.Definition{Type: jsonschema.String}}
+		default:
+			property = jsonschema.Definition{Type: jsonschema.Array, Items: &analysisItemSchema}
+		}
+		property.Description = field.Label
+		def.Properties[field.Key] = property
+		def.Required = append(def.Required, field.Key)
+	}
+	return &ResponseSchema{Name: "article_analysis", Description: "文章分析结果", Definition: def}
+}
+
+// resultFormat 提示词中给出的返回格式
+func resultFormat(schema *model.AnalysisSchema) string {
+	if isDefaultSchema(schema) {
+		return analysisResultFormat
+	}
+
+	var b strings.Builder
+	b.WriteString("{\n")
+	hasItems := false
+	for i, field := range schema.Fields {
+		var example string
+		switch field.Type {
+		case model.FieldTypeText:
+			example = `"一段文字"`
+		case model.FieldTypeList:
+			example = `["词语或短句"]`
+		default:
+			example = `[
+    {"title": "要点标题", "explanation": "具体说明", "quote": "原文中支撑该要点的句子"}
+  ]`
+			hasItems = true
+		}
+		fmt.Fprintf(&b, "  %q: %s", field.Key, example)
+		if i < len(schema.Fields)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("}")
+	if hasItems {
+		b.WriteString("\n\n要点列表中的每条要点包含标题、说明和引文；quote 必须摘自原文，没有合适的引文时填空字符串。")
+	}
+	return b.String()
+}
+
+// schemaDigest 分析方案内容的摘要，方案修改后不复用之前的缓存结果
+func schemaDigest(schema *model.AnalysisSchema) string {
+	if isDefaultSchema(schema) {
+		return DefaultSchemaName
+	}
+	data, _ := json.Marshal(schema.Fields)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// fieldText 将一个维度的结果渲染为文本，用于合并长文的分段结果
+func fieldText(field model.SchemaField, value json.RawMessage) string {
+	switch field.Type {
+	case model.FieldTypeText:
+		var text string
+		json.Unmarshal(value, &text)
+		return text
+	case model.FieldTypeList:
+		var list []string
+		json.Unmarshal(value, &list)
+		return strings.Join(list, "、")
+	default:
+		var items []model.AnalysisItem
+		json.Unmarshal(value, &items)
+		return model.FormatItems(items)
+	}
+}
+
+type analysisSchemaKey struct{}
+
+// WithAnalysisSchema 返回携带分析方案的上下文，分析器按其生成提示词并校验结果
+func WithAnalysisSchema(ctx context.Context, schema *model.AnalysisSchema) context.Context {
+	return context.WithValue(ctx, analysisSchemaKey{}, schema)
+}
+
+// schemaFromContext 返回上下文中的分析方案，未设置时使用内置方案
+func schemaFromContext(ctx context.Context) *model.AnalysisSchema {
+	if schema, ok := ctx.Value(analysisSchemaKey{}).(*model.AnalysisSchema); ok && schema != nil {
+		return schema
+	}
+	return defaultAnalysisSchema
+}
+
+// resolveSchema 按名称查询分析方案，名称为空时使用内置方案
+func (s *AnalysisService) resolveSchema(name string) (*model.AnalysisSchema, error) {
+	if name == "" || name == DefaultSchemaName {
+		return defaultAnalysisSchema, nil
+	}
+	schema, err := s.analysisRepo.GetSchema(name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownSchema, name)
+		}
+		return nil, fmt.Errorf("查询分析方案失败: %w", err)
+	}
+	return schema, nil
+}
+
+// analysisSchema 返回分析结果所用的分析方案：优先使用分析时保存的维度快照，之后修改或删除方案不影响读取；
+// 早期没有快照的结果按名称查找，方案已被删除时按结果推断维度
+func (s *AnalysisService) analysisSchema(analysis *model.ArticleAnalysis) (*model.AnalysisSchema, error) {
+	if len(analysis.SchemaFields) > 0 {
+		return &model.AnalysisSchema{Name: analysis.Schema, Fields: analysis.SchemaFields}, nil
+	}
+	schema, err := s.resolveSchema(analysis.Schema)
+	if errors.Is(err, ErrUnknownSchema) && analysis.Fields != nil {
+		s.log.Warn("分析方案不存在，按结果推断维度", zap.String("schema", analysis.Schema))
+		return &model.AnalysisSchema{Name: analysis.Schema, Fields: inferFields(analysis.Fields)}, nil
+	}
+	return schema, err
+}
+
+// ListSchemas 列出可用的分析方案，第一个为内置方案
+func (s *AnalysisService) ListSchemas() ([]model.AnalysisSchema, error) {
+	schemas, err := s.analysisRepo.ListSchemas()
+	if err != nil {
+		return nil, fmt.Errorf("查询分析方案失败: %w", err)
+	}
+	return append([]model.AnalysisSchema{*defaultAnalysisSchema}, schemas...), nil
+}
+
+// GetSchema 按名称获取分析方案
+func (s *AnalysisService) GetSchema(name string) (*model.AnalysisSchema, error) {
+	return s.resolveSchema(name)
+}
+
+// CreateSchema 新建分析方案
+func (s *AnalysisService) CreateSchema(schema *model.AnalysisSchema) error {
+	if err := validateAnalysisSchema(schema); err != nil {
+		return err
+	}
+	if _, err := s.analysisRepo.GetSchema(schema.Name); err == nil {
+		return fmt.Errorf("%w: %s", ErrSchemaExists, schema.Name)
+	}
+	if err := s.analysisRepo.CreateSchema(schema); err != nil {
+		return fmt.Errorf("保存分析方案失败: %w", err)
+	}
+	return nil
+}
+
+// UpdateSchema 修改分析方案的说明和维度，名称不可修改
+func (s *AnalysisService) UpdateSchema(name string, update *model.AnalysisSchema) (*model.AnalysisSchema, error) {
+	if name == DefaultSchemaName {
+		return nil, fmt.Errorf("%w：内置方案不可修改", ErrInvalidSchema)
+	}
+	schema, err := s.resolveSchema(name)
+	if err != nil {
+		return nil, err
+	}
+	update.Name = schema.Name
+	if err := validateAnalysisSchema(update); err != nil {
+		return nil, err
+	}
+	schema.Description = update.Description
+	schema.Fields = update.Fields
+	if err := s.analysisRepo.UpdateSchema(schema); err != nil {
+		return nil, fmt.Errorf("保存分析方案失败: %w", err)
+	}
+	return schema, nil
+}
+
+// DeleteSchema 删除分析方案，已有的分析结果保留
+func (s *AnalysisService) DeleteSchema(name string) error {
+	if name == DefaultSchemaName {
+		return fmt.Errorf("%w：内置方案不可删除", ErrInvalidSchema)
+	}
+	deleted, err := s.analysisRepo.DeleteSchema(name)
+	if err != nil {
+		return fmt.Errorf("删除分析方案失败: %w", err)
+	}
+	if !deleted {
+		return fmt.Errorf("%w: %s", ErrUnknownSchema, name)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"article-analysis/internal/config"
+	"article-analysis/internal/model"
+	"article-analysis/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRhetoricSchema() *model.AnalysisSchema {
+	return &model.AnalysisSchema{
+		Name:        "rhetoric",
+		Description: "修辞与语言",
+		Fields: model.SchemaFields{
+			{Key: "devices", Label: "修辞手法", Description: "文中使用的修辞手法"},
+			{Key: "style", Label: "语言风格", Type: model.FieldTypeText},
+			{Key: "topics", Label: "适用作文题目", Type: model.FieldTypeList},
+		},
+	}
+}
+
+func TestValidateAnalysisSchema(t *testing.T) {
+	schema := newRhetoricSchema()
+	require.NoError(t, validateAnalysisSchema(schema))
+	assert.Equal(t, model.FieldTypeItems, schema.Fields[0].Type, "未填写类型时为要点列表")
+
+	cases := map[string]func(s *model.AnalysisSchema){
+		"名称不合法": func(s *model.AnalysisSchema) { s.Name = "修辞" },
+		"内置名称":  func(s *model.AnalysisSchema) { s.Name = DefaultSchemaName },
+		"没有维度":  func(s *model.AnalysisSchema) { s.Fields = nil },
+		"字段名重复": func(s *model.AnalysisSchema) { s.Fields[1].Key = "devices" },
+		"缺少名称":  func(s *model.AnalysisSchema) { s.Fields[0].Label = " " },
+		"类型不支持": func(s *model.AnalysisSchema) { s.Fields[0].Type = "number" },
+	}
+	for name, mutate := range cases {
+		schema := newRhetoricSchema()
+		mutate(schema)
+		assert.ErrorIs(t, validateAnalysisSchema(schema), ErrInvalidSchema, name)
+	}
+}
+
+func TestAnalysisService_SchemaCRUD(t *testing.T) {
+	s, _, _ := newTestAnalysisService(t)
+
+	require.NoError(t, s.CreateSchema(newRhetoricSchema()))
+	assert.ErrorIs(t, s.CreateSchema(newRhetoricSchema()), ErrSchemaExists)
+
+	schemas, err := s.ListSchemas()
+	require.NoError(t, err)
+	require.Len(t, schemas, 2)
+	assert.Equal(t, DefaultSchemaName, schemas[0].Name)
+	assert.Equal(t, "rhetoric", schemas[1].Name)
+	assert.Len(t, schemas[1].Fields, 3)
+
+	updated, err := s.UpdateSchema("rhetoric", &model.AnalysisSchema{
+		Fields: model.SchemaFields{{Key: "devices", Label: "修辞"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "rhetoric", updated.Name)
+	stored, err := s.GetSchema("rhetoric")
+	require.NoError(t, err)
+	require.Len(t, stored.Fields, 1)
+	assert.Equal(t, "修辞", stored.Fields[0].Label)
+
+	_, err = s.UpdateSchema(DefaultSchemaName, newRhetoricSchema())
+	assert.ErrorIs(t, err, ErrInvalidSchema)
+	assert.ErrorIs(t, s.DeleteSchema(DefaultSchemaName), ErrInvalidSchema)
+
+	require.NoError(t, s.DeleteSchema("rhetoric"))
+	assert.ErrorIs(t, s.DeleteSchema("rhetoric"), ErrUnknownSchema)
+	_, err = s.GetSchema("rhetoric")
+	assert.ErrorIs(t, err, ErrUnknownSchema)
+}
+
+func TestAnalysisService_CustomSchemaAnalysis(t *testing.T) {
+	reply, _ := json.Marshal(`{"devices":[{"title":"比喻","explanation":"把时间比作流水","quote":"时间像流水一样"}],` +
+		`"style":"朴素自然","topics":["珍惜时间","成长"]}`)
+	var mu sync.Mutex
+	var prompts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		prompts = append(prompts, string(body))
+		mu.Unlock()
+		writeChatCompletion(w, strings.Trim(string(reply), `"`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		OpenAI:   config.OpenAIConfig{APIKey: "test-api-key", APIBase: server.URL, Model: "test-model"},
+		Analysis: config.AnalysisConfig{MaxConcurrency: 1, PollInterval: 1, Timeout: 10},
+	}
+	s, taskRepo, db := newTestAnalysisServiceWithAnalyzer(t, cfg, nil)
+	articleRepo := repository.NewArticleRepository(db)
+	require.NoError(t, s.CreateSchema(newRhetoricSchema()))
+
+	article := &model.Article{Title: "时间", Author: "张三", Content: "时间像流水一样，一去不复返。", FilePath: "f.txt"}
+	require.NoError(t, articleRepo.Create(article))
+
+	_, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{Schema: "missing"})
+	assert.ErrorIs(t, err, ErrUnknownSchema)
+	_, err = s.AnalyzeArticle(article.ID, AnalyzeOptions{Schema: "rhetoric", Prompt: "v2"})
+	assert.ErrorIs(t, err, ErrPromptSchemaMismatch, "固定维度的提示词不能用于自定义方案")
+
+	submitted, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{Schema: "rhetoric"})
+	require.NoError(t, err)
+	task := runUntilFinished(t, s, taskRepo, submitted.TaskID)
+	require.Equal(t, model.TaskStatusCompleted, task.Status, task.ErrorMessage)
+	assert.Equal(t, "rhetoric", task.Schema)
+
+	analysis, err := s.GetAnalysisResult(article.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "rhetoric", analysis.Schema)
+	assert.Nil(t, analysis.Result)
+	require.Contains(t, analysis.Fields, "devices")
+
+	var devices []model.AnalysisItem
+	require.NoError(t, json.Unmarshal(analysis.Fields["devices"], &devices))
+	require.Len(t, devices, 1)
+	assert.True(t, devices[0].Verified, "自定义方案中的要点同样核对引文")
+	var topics []string
+	require.NoError(t, json.Unmarshal(analysis.Fields["topics"], &topics))
+	assert.Equal(t, []string{"珍惜时间", "成长"}, topics)
+	assert.JSONEq(t, `"朴素自然"`, string(analysis.Fields["style"]))
+	require.Len(t, analysis.SchemaFields, 3, "保存分析时的方案维度")
+	assert.Equal(t, "修辞手法", analysis.SchemaFields[0].Label)
+
+	// 方案删除后仍按分析时的维度修改结果
+	require.NoError(t, s.DeleteSchema("rhetoric"))
+	edited, err := s.UpdateAnalysis(article.ID, AnalysisEdit{Fields: map[string]json.RawMessage{
+		"style": json.RawMessage(`" 平实 "`),
+	}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `"平实"`, string(edited.Fields["style"]))
+
+	// 早期没有维度快照的结果按内容推断维度
+	require.NoError(t, db.Model(&model.ArticleAnalysis{}).Where("id = ?", analysis.ID).Update("schema_fields", nil).Error)
+	edited, err = s.UpdateAnalysis(article.ID, AnalysisEdit{Fields: map[string]json.RawMessage{
+		"topics": json.RawMessage(`["珍惜时间"]`),
+	}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `["珍惜时间"]`, string(edited.Fields["topics"]))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, prompts, 1)
+	assert.Contains(t, prompts[0], "修辞手法", "提示词按方案列出维度")
+	assert.Contains(t, prompts[0], `\"topics\"`)
+	assert.NotContains(t, prompts[0], "核心观点")
+}
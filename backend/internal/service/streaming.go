@@ -0,0 +1,27 @@
+package service
+
+import "context"
+
+// TokenObserver 接收模型流式输出的增量内容
+type TokenObserver func(delta string)
+
+type tokenObserverKey struct{}
+
+// WithTokenObserver 返回携带增量内容观察者的上下文；模型服务支持流式输出时改用流式调用，
+// 每收到一段内容回调一次，不支持时只在调用结束后得到完整结果
+func WithTokenObserver(ctx context.Context, observer TokenObserver) context.Context {
+	return context.WithValue(ctx, tokenObserverKey{}, observer)
+}
+
+func tokenObserverFrom(ctx context.Context) TokenObserver {
+	if observer, ok := ctx.Value(tokenObserverKey{}).(TokenObserver); ok {
+		return observer
+	}
+	return nil
+}
+
+// streamingProvider 支持流式输出的模型服务，onDelta 按到达顺序接收增量内容，
+// 返回的结果与 Chat 相同
+type streamingProvider interface {
+	ChatStream(ctx context.Context, req ChatRequest, onDelta func(delta string)) (*ChatResponse, error)
+}
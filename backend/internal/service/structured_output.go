@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"article-analysis/internal/model"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidOutput 模型输出无法解析或不符合结果格式
+var ErrInvalidOutput = errors.New("模型输出不符合结果格式")
+
+// analysisItemSchema 分析结果中一条要点的结构
+var analysisItemSchema = jsonschema.Definition{
+	Type: jsonschema.Object,
+	Properties: map[string]jsonschema.Definition{
+		"title":       {Type: jsonschema.String, Description: "要点标题"},
+		"explanation": {Type: jsonschema.String, Description: "具体说明"},
+		"quote":       {Type: jsonschema.String, Description: "原文中支撑该要点的引文，没有时为空字符串"},
+	},
+	Required:             []string{"title", "explanation", "quote"},
+	AdditionalProperties: false,
+}
+
+// analysisResultSchema 分析结果的 JSON Schema，全文、分段和合并分析返回的结构相同
+var analysisResultSchema = ResponseSchema{
+	Name:        "article_analysis",
+	Description: "文章分析结果",
+	Definition: jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"core_viewpoints":   {Type: jsonschema.Array, Description: "核心观点", Items: &analysisItemSchema},
+			"file_structure":    {Type: jsonschema.Array, Description: "文件结构", Items: &analysisItemSchema},
+			"author_thoughts":   {Type: jsonschema.Array, Description: "作者思路", Items: &analysisItemSchema},
+			"related_materials": {Type: jsonschema.Array, Description: "相关素材与事例", Items: &analysisItemSchema},
+		},
+		Required:             []string{"core_viewpoints", "file_structure", "author_thoughts", "related_materials"},
+		AdditionalProperties: false,
+	},
+}
+
+// extractJSON 从模型输出中取出 JSON 对象：去掉 Markdown 代码块标记，前后有说明文字时取第一个 { 到最后一个 } 之间的内容
+func extractJSON(content string) (string, error) {
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, "```") {
+		content = strings.TrimPrefix(content, "```json")
+		content = strings.TrimPrefix(content, "```")
+		content = strings.TrimSpace(strings.TrimSuffix(content, "```"))
+	}
+
+	startIdx := strings.Index(content, "{")
+	endIdx := strings.LastIndex(content, "}")
+	if startIdx == -1 || endIdx < startIdx {
+		return "", fmt.Errorf("%w：无法解析AI响应格式，找不到JSON内容", ErrInvalidOutput)
+	}
+	return content[startIdx : endIdx+1], nil
+}
+
+// decodeStructured 解析模型输出并按 schema 校验，通过后解码到 v
+func decodeStructured(content string, schema jsonschema.Definition, v interface{}) error {
+	jsonStr, err := extractJSON(content)
+	if err != nil {
+		return err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(jsonStr), &value); err != nil {
+		return fmt.Errorf("%w：JSON解析失败: %v", ErrInvalidOutput, err)
+	}
+	if problems := validateSchema(schema, value, "$"); len(problems) > 0 {
+		return fmt.Errorf("%w：%s", ErrInvalidOutput, strings.Join(problems, "；"))
+	}
+	if err := json.Unmarshal([]byte(jsonStr), v); err != nil {
+		return fmt.Errorf("%w：JSON解析失败: %v", ErrInvalidOutput, err)
+	}
+	return nil
+}
+
+// validateSchema 按 JSON Schema 的常用子集（type、properties、required、additionalProperties、items、enum）校验，
+// 返回全部不符合之处，便于一次性反馈给模型修正
+func validateSchema(schema jsonschema.Definition, value interface{}, path string) []string {
+	if len(schema.Enum) > 0 {
+		s, ok := value.(string)
+		if !ok || !containsString(schema.Enum, s) {
+			return []string{fmt.Sprintf("%s 应为 %s 之一", path, strings.Join(schema.Enum, "、"))}
+		}
+	}
+
+	switch schema.Type {
+	case jsonschema.Object:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s 应为对象，实际为%s", path, jsonTypeName(value))}
+		}
+		var problems []string
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				problems = append(problems, fmt.Sprintf("%s 缺少必填字段 %s", path, name))
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			property, ok := schema.Properties[key]
+			if !ok {
+				if allowed, isBool := schema.AdditionalProperties.(bool); isBool && !allowed {
+					problems = append(problems, fmt.Sprintf("%s 包含未定义的字段 %s", path, key))
+				}
+				continue
+			}
+			problems = append(problems, validateSchema(property, obj[key], path+"."+key)...)
+		}
+		return problems
+	case jsonschema.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s 应为数组，实际为%s", path, jsonTypeName(value))}
+		}
+		if schema.Items == nil {
+			return nil
+		}
+		var problems []string
+		for i, item := range items {
+			problems = append(problems, validateSchema(*schema.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return problems
+	case jsonschema.String:
+		if _, ok := value.(string); !ok {
+			return []string{fmt.Sprintf("%s 应为字符串，实际为%s", path, jsonTypeName(value))}
+		}
+	case jsonschema.Number:
+		if _, ok := value.(float64); !ok {
+			return []string{fmt.Sprintf("%s 应为数字，实际为%s", path, jsonTypeName(value))}
+		}
+	case jsonschema.Integer:
+		if n, ok := value.(float64); !ok || n != math.Trunc(n) {
+			return []string{fmt.Sprintf("%s 应为整数，实际为%s", path, jsonTypeName(value))}
+		}
+	case jsonschema.Boolean:
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s 应为布尔值，实际为%s", path, jsonTypeName(value))}
+		}
+	}
+	return nil
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "对象"
+	case []interface{}:
+		return "数组"
+	case string:
+		return "字符串"
+	case float64:
+		return "数字"
+	case bool:
+		return "布尔值"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// repairAttempts 返回输出不符合格式时要求模型修正的次数
+func (c *OpenAIClient) repairAttempts() int {
+	if c.config.Analysis.RepairAttempts < 0 {
+		return 0
+	}
+	return c.config.Analysis.RepairAttempts
+}
+
+// parseWithRepair 解析模型输出，不符合结果格式时把原输出和校验错误发回模型要求修正，
+// 修正次数用尽后返回最后一次的错误
+func (c *OpenAIClient) parseWithRepair(ctx context.Context, backend *llmBackend, schema *model.AnalysisSchema, req ChatRequest, resp *ChatResponse) (*AnalysisResponse, *ChatResponse, error) {
+	messages := append([]ChatMessage(nil), req.Messages...)
+	for repair := 1; ; repair++ {
+		result, err := c.parseAIResponse(resp.Content, schema)
+		if err == nil {
+			return result, resp, nil
+		}
+		if repair > c.repairAttempts() || !errors.Is(err, ErrInvalidOutput) {
+			return nil, nil, err
+		}
+
+		c.log.Warn("模型输出不符合结果格式，要求修正",
+			zap.String("profile", backend.profile.Name),
+			zap.Int("repair", repair),
+			zap.Error(err))
+		messages = append(messages,
+			ChatMessage{Role: "assistant", Content: resp.Content},
+			ChatMessage{Role: "user", Content: buildRepairPrompt(err)},
+		)
+		req.Messages = messages
+		if resp, err = c.chat(ctx, backend, req); err != nil {
+			return nil, nil, fmt.Errorf("请求修正输出失败: %w", err)
+		}
+	}
+}
+
+func buildRepairPrompt(err error) string {
+	return fmt.Sprintf(`你上一次的回复未通过格式校验：%v
+
+请修正后重新输出完整结果。只输出一个JSON对象，不要包含任何解释文字或Markdown代码块标记，字段必须与要求的格式完全一致。`, err)
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"article-analysis/internal/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeStructured_ValidatesSchema(t *testing.T) {
+	var result model.AnalysisResult
+
+	err := decodeStructured("```json\n"+strings.ReplaceAll(testAnalysisJSON, `\"`, `"`)+"\n```", analysisResultSchema.Definition, &result)
+	require.NoError(t, err, "应去掉Markdown代码块标记")
+	require.Len(t, result.CoreViewpoints, 1)
+	assert.Equal(t, "观点", result.CoreViewpoints[0].Title)
+
+	err = decodeStructured(`{"core_viewpoints":"观点","file_structure":[{"title":"结构"}],"author_thoughts":[],"summary":"多余"}`,
+		analysisResultSchema.Definition, &result)
+	require.ErrorIs(t, err, ErrInvalidOutput)
+	assert.Contains(t, err.Error(), "缺少必填字段 related_materials")
+	assert.Contains(t, err.Error(), "$.core_viewpoints 应为数组，实际为字符串")
+	assert.Contains(t, err.Error(), "$.file_structure[0] 缺少必填字段 explanation")
+	assert.Contains(t, err.Error(), "未定义的字段 summary")
+
+	err = decodeStructured(`{"core_viewpoints": "观点",}`, analysisResultSchema.Definition, &result)
+	assert.ErrorIs(t, err, ErrInvalidOutput)
+	assert.Contains(t, err.Error(), "JSON解析失败")
+}
+
+func TestOpenAIClient_RepairsInvalidOutput(t *testing.T) {
+	var mu sync.Mutex
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		mu.Lock()
+		requests = append(requests, body)
+		first := len(requests) == 1
+		mu.Unlock()
+
+		if first {
+			writeChatCompletion(w, `{\"core_viewpoints\":\"观点\"}`)
+			return
+		}
+		writeChatCompletion(w, testAnalysisJSON)
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(server.URL, 0)
+	client.config.Analysis.RepairAttempts = 2
+
+	var attempts []LLMAttempt
+	ctx := WithAttemptObserver(context.Background(), func(a LLMAttempt) {
+		attempts = append(attempts, a)
+	})
+
+	result, err := client.AnalyzeArticle(ctx, "测试文章")
+	require.NoError(t, err)
+	assert.Equal(t, "素材", result.RelatedMaterials)
+	assert.Len(t, attempts, 2, "修正请求同样计入调用记录")
+
+	require.Len(t, requests, 2)
+	format := requests[0]["response_format"].(map[string]interface{})
+	assert.Equal(t, "json_object", format["type"], "默认使用JSON mode")
+
+	messages := requests[1]["messages"].([]interface{})
+	require.Len(t, messages, 4)
+	assert.Equal(t, "assistant", messages[2].(map[string]interface{})["role"])
+	repair := messages[3].(map[string]interface{})["content"].(string)
+	assert.Contains(t, repair, "缺少必填字段 file_structure")
+}
+
+func TestOpenAIClient_RepairGivesUp(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		writeChatCompletion(w, "无法完成分析")
+	}))
+	defer server.Close()
+
+	client := newRetryTestClient(server.URL, 0)
+	client.config.Analysis.RepairAttempts = 1
+
+	_, err := client.AnalyzeArticle(context.Background(), "测试文章")
+	require.ErrorIs(t, err, ErrInvalidOutput)
+	assert.Equal(t, 2, calls, "首次调用加1次修正")
+}
+
+func TestOpenAIClient_JSONModes(t *testing.T) {
+	for mode, check := range map[string]func(t *testing.T, body map[string]interface{}){
+		JSONModeSchema: func(t *testing.T, body map[string]interface{}) {
+			format := body["response_format"].(map[string]interface{})
+			assert.Equal(t, "json_schema", format["type"])
+			schema := format["json_schema"].(map[string]interface{})
+			assert.Equal(t, analysisResultSchema.Name, schema["name"])
+			assert.Equal(t, true, schema["strict"])
+		},
+		JSONModeTool: func(t *testing.T, body map[string]interface{}) {
+			tools := body["tools"].([]interface{})
+			require.Len(t, tools, 1)
+			choice := body["tool_choice"].(map[string]interface{})
+			assert.Equal(t, analysisResultSchema.Name, choice["function"].(map[string]interface{})["name"])
+		},
+		JSONModeOff: func(t *testing.T, body map[string]interface{}) {
+			assert.NotContains(t, body, "response_format")
+			assert.NotContains(t, body, "tools")
+		},
+	} {
+		t.Run(mode, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]interface{}
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+				check(t, body)
+
+				if mode == JSONModeTool {
+					// 函数调用时结果在参数中返回
+					w.Header().Set("Content-Type", "application/json")
+					arguments, _ := json.Marshal(strings.ReplaceAll(testAnalysisJSON, `\"`, `"`))
+					w.Write([]byte(`{"id":"1","object":"chat.completion","model":"test","choices":[{"index":0,"message":{"role":"assistant","content":"","tool_calls":[{"id":"call_1","type":"function","function":{"name":"article_analysis","arguments":` + string(arguments) + `}}]},"finish_reason":"tool_calls"}]}`))
+					return
+				}
+				writeChatCompletion(w, testAnalysisJSON)
+			}))
+			defer server.Close()
+
+			client := newRetryTestClient(server.URL, 0)
+			client.backends[0].profile.JSONMode = mode
+
+			result, err := client.AnalyzeArticle(context.Background(), "测试文章")
+			require.NoError(t, err)
+			assert.Equal(t, "观点", result.CoreViewpoints)
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"article-analysis/internal/model"
+	"sync"
+	"time"
+)
+
+// 任务事件类型
+const (
+	TaskEventQueued    = "queued"    // 任务入队，或中断后重新排队
+	TaskEventStarted   = "started"   // 工作协程开始执行
+	TaskEventChunk     = "chunk"     // 长文分段分析完成一次模型调用
+	TaskEventRetrying  = "retrying"  // 重新调用模型（重试、降级或修复无效输出），此前的增量内容作废
+	TaskEventToken     = "token"     // 模型流式输出的增量内容
+	TaskEventCompleted = "completed" // 分析完成
+	TaskEventFailed    = "failed"    // 分析失败
+	TaskEventCancelled = "cancelled" // 任务被取消
+)
+
+// maxTaskEventHistory 每个任务保留的生命周期事件数，供中途订阅者补发
+const maxTaskEventHistory = 100
+
+// TaskEvent 分析任务执行过程中的事件，仅在本进程内分发
+type TaskEvent struct {
+	Type     string    `json:"type"`
+	TaskID   string    `json:"task_id"`
+	Status   string    `json:"status,omitempty"`
+	Progress int       `json:"progress,omitempty"`
+	Chunk    int       `json:"chunk,omitempty"` // 已完成的模型调用次数
+	Total    int       `json:"total,omitempty"` // 预计的模型调用总次数
+	Attempt  int       `json:"attempt,omitempty"`
+	WaitMs   int64     `json:"wait_ms,omitempty"`
+	Delta    string    `json:"delta,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Terminal 任务已结束，之后不会再有事件
+func (e TaskEvent) Terminal() bool {
+	switch e.Type {
+	case TaskEventCompleted, TaskEventFailed, TaskEventCancelled:
+		return true
+	}
+	return false
+}
+
+// taskEventStream 单个任务的事件历史和订阅者
+type taskEventStream struct {
+	history     []TaskEvent
+	partial     []byte                  // 当前模型调用已输出的内容，出现其他事件时清空
+	subscribers map[chan TaskEvent]bool // 值为是否接收增量内容
+}
+
+// taskEventHub 按任务分发事件
+//
+// 生命周期事件保留最近若干条，增量内容合并为一条补发，使中途订阅的客户端能看到完整进度；
+// 任务结束、重新排队或被其他执行者接手后关闭所有订阅并丢弃历史，之后的订阅者应从任务表读取状态。
+// 订阅者处理不及时时丢弃增量内容；生命周期事件无法送达时关闭该订阅，由客户端重新订阅。
+type taskEventHub struct {
+	mu      sync.Mutex
+	streams map[string]*taskEventStream
+}
+
+func newTaskEventHub() *taskEventHub {
+	return &taskEventHub{streams: make(map[string]*taskEventStream)}
+}
+
+func (h *taskEventHub) stream(taskID string) *taskEventStream {
+	stream, ok := h.streams[taskID]
+	if !ok {
+		stream = &taskEventStream{subscribers: make(map[chan TaskEvent]bool)}
+		h.streams[taskID] = stream
+	}
+	return stream
+}
+
+// publish 分发事件，终止事件分发后关闭该任务的所有订阅
+func (h *taskEventHub) publish(event TaskEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stream := h.stream(event.TaskID)
+	switch {
+	case event.Type == TaskEventToken:
+		stream.partial = append(stream.partial, event.Delta...)
+	case event.Terminal():
+	default:
+		stream.partial = stream.partial[:0]
+		if len(stream.history) >= maxTaskEventHistory {
+			stream.history = stream.history[1:]
+		}
+		stream.history = append(stream.history, event)
+	}
+
+	for ch, withTokens := range stream.subscribers {
+		if event.Type == TaskEventToken && !withTokens {
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+			if event.Type != TaskEventToken {
+				delete(stream.subscribers, ch)
+				close(ch)
+			}
+		}
+	}
+
+	if event.Terminal() {
+		for ch := range stream.subscribers {
+			close(ch)
+		}
+		delete(h.streams, event.TaskID)
+	}
+}
+
+// drop 关闭任务的所有订阅并丢弃历史，用于任务离开本进程（重新排队或被其他执行者接手）之后
+func (h *taskEventHub) drop(taskID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stream, ok := h.streams[taskID]
+	if !ok {
+		return
+	}
+	for ch := range stream.subscribers {
+		close(ch)
+	}
+	delete(h.streams, taskID)
+}
+
+// subscribe 订阅任务事件，返回已发生的事件和后续事件的通道，withTokens 为 false 时不接收增量内容；
+// 通道在任务结束、离开本进程或调用 cancel 后关闭
+func (h *taskEventHub) subscribe(taskID string, withTokens bool) (past []TaskEvent, events <-chan TaskEvent, cancel func()) {
+	ch := make(chan TaskEvent, 64)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stream := h.stream(taskID)
+	past = append(past, stream.history...)
+	if withTokens && len(stream.partial) > 0 {
+		past = append(past, TaskEvent{Type: TaskEventToken, TaskID: taskID, Delta: string(stream.partial), Time: time.Now()})
+	}
+	stream.subscribers[ch] = withTokens
+
+	var once sync.Once
+	cancel = func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			if current, ok := h.streams[taskID]; ok {
+				if _, ok := current.subscribers[ch]; ok {
+					delete(current.subscribers, ch)
+					close(ch)
+				}
+				if len(current.subscribers) == 0 && len(current.history) == 0 {
+					delete(h.streams, taskID)
+				}
+			}
+		})
+	}
+	return past, ch, cancel
+}
+
+// watched 任务当前是否有接收增量内容的订阅者
+func (h *taskEventHub) watched(taskID string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stream, ok := h.streams[taskID]
+	if !ok {
+		return false
+	}
+	for _, withTokens := range stream.subscribers {
+		if withTokens {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscribeTaskEvents 订阅任务事件，先返回任务当前状态和已发生的事件。
+// 任务已结束时直接返回终止事件且通道为空；任务开始执行时已有接收增量内容的订阅者才会流式调用模型并推送增量内容。
+// 增量内容是未经审核的模型输出，withTokens 只应对编辑开启。
+// 任务在其他实例执行时本进程收不到事件，调用方需定期调用 GetTaskEvent 检查任务状态
+func (s *AnalysisService) SubscribeTaskEvents(taskID string, withTokens bool) (past []TaskEvent, events <-chan TaskEvent, cancel func(), err error) {
+	// 先查任务，不存在或已结束的任务不创建订阅
+	current, err := s.GetTaskEvent(taskID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if current.Terminal() {
+		return []TaskEvent{current}, nil, func() {}, nil
+	}
+
+	past, events, cancel = s.events.subscribe(taskID, withTokens)
+	// 查询与订阅之间任务可能已结束，终止事件不会再发布
+	current, err = s.GetTaskEvent(taskID)
+	if err != nil {
+		cancel()
+		return nil, nil, nil, err
+	}
+	if current.Terminal() {
+		cancel()
+		return []TaskEvent{current}, nil, func() {}, nil
+	}
+	if len(past) == 0 {
+		past = []TaskEvent{current}
+	}
+	return past, events, cancel, nil
+}
+
+// GetTaskEvent 以事件的形式返回任务表中记录的当前状态
+func (s *AnalysisService) GetTaskEvent(taskID string) (TaskEvent, error) {
+	task, err := s.taskRepo.GetByTaskID(taskID)
+	if err != nil {
+		return TaskEvent{}, ErrTaskNotFound
+	}
+	event := TaskEvent{TaskID: task.TaskID, Status: task.Status, Progress: task.Progress, Error: task.ErrorMessage, Time: time.Now()}
+	switch task.Status {
+	case model.TaskStatusQueued:
+		event.Type = TaskEventQueued
+	case model.TaskStatusRunning:
+		event.Type, event.Attempt = TaskEventStarted, task.Attempts
+	case model.TaskStatusCompleted:
+		event.Type = TaskEventCompleted
+	case model.TaskStatusCancelled:
+		event.Type = TaskEventCancelled
+	default:
+		event.Type = TaskEventFailed
+	}
+	return event, nil
+}
+
+// SetEventBus 设置发布分析状态变化的事件总线，未设置时只分发任务事件
+func (s *AnalysisService) SetEventBus(bus *EventBus) {
+	s.bus = bus
+}
+
+// analysisStatusByEvent 任务事件对应的分析记录状态
+var analysisStatusByEvent = map[string]string{
+	TaskEventQueued:    "pending",
+	TaskEventStarted:   "processing",
+	TaskEventCompleted: "completed",
+	TaskEventFailed:    "failed",
+	TaskEventCancelled: "cancelled",
+}
+
+// retryingEvent 模型调用结束后重新调用时的事件
+func retryingEvent(attempt LLMAttempt) TaskEvent {
+	event := TaskEvent{Type: TaskEventRetrying, Attempt: attempt.Attempt, WaitMs: attempt.Wait.Milliseconds()}
+	if attempt.Err != nil {
+		event.Error = attempt.Err.Error()
+	}
+	return event
+}
+
+// publishTaskEvent 发布任务事件，任务状态变化同时作为分析状态事件发布到事件总线
+func (s *AnalysisService) publishTaskEvent(task *model.AnalysisTask, event TaskEvent) {
+	event.TaskID = task.TaskID
+	s.events.publish(event)
+
+	status, ok := analysisStatusByEvent[event.Type]
+	if !ok || s.bus == nil {
+		return
+	}
+	var author string
+	if article, err := s.articleRepo.GetByID(task.ArticleID); err == nil {
+		author = article.Author
+	}
+	s.bus.Publish(DomainEvent{
+		Type:      EventAnalysisStatus,
+		ArticleID: task.ArticleID,
+		Author:    author,
+		Data:      AnalysisStatusData{AnalysisID: task.AnalysisID, TaskID: task.TaskID, Status: status, Error: event.Error},
+	})
+}
+
+// onTaskStatus 工作池更新任务状态后发布对应的事件
+func (s *AnalysisService) onTaskStatus(task *model.AnalysisTask, status, errorMsg string) {
+	event := TaskEvent{Status: status, Error: errorMsg}
+	switch status {
+	case model.TaskStatusQueued:
+		event.Type = TaskEventQueued
+	case model.TaskStatusCompleted:
+		event.Type, event.Progress = TaskEventCompleted, 100
+	default:
+		event.Type = TaskEventFailed
+	}
+	s.publishTaskEvent(task, event)
+	if status == model.TaskStatusQueued {
+		// 重新排队的任务可能由其他实例执行，本进程不会再收到它的事件
+		s.events.drop(task.TaskID)
+	}
+}
+
+// onTaskReleased 任务被取消或回收给其他执行者后，本进程不会再收到它的事件，关闭订阅由客户端重新订阅
+func (s *AnalysisService) onTaskReleased(task *model.AnalysisTask) {
+	s.events.drop(task.TaskID)
+}
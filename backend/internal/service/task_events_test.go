@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"article-analysis/internal/config"
+	"article-analysis/internal/model"
+	"article-analysis/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeChatCompletionStream 以流式响应写出内容，每个分片一段，最后一个分片携带用量
+func writeChatCompletionStream(w http.ResponseWriter, pieces []string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, piece := range pieces {
+		delta, _ := json.Marshal(piece)
+		fmt.Fprintf(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"model\":\"stream-model\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%s}}]}\n\n", delta)
+	}
+	fmt.Fprint(w, "data: {\"id\":\"1\",\"object\":\"chat.completion.chunk\",\"model\":\"stream-model\",\"choices\":[],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":34,\"total_tokens\":46}}\n\n")
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func TestTaskEventHub(t *testing.T) {
+	hub := newTaskEventHub()
+	hub.publish(TaskEvent{Type: TaskEventStarted, TaskID: "t1", Status: model.TaskStatusRunning})
+	hub.publish(TaskEvent{Type: TaskEventToken, TaskID: "t1", Delta: "你"})
+	hub.publish(TaskEvent{Type: TaskEventToken, TaskID: "t1", Delta: "好"})
+
+	assert.False(t, hub.watched("t1"))
+	studentPast, studentEvents, studentCancel := hub.subscribe("t1", false)
+	defer studentCancel()
+	require.Len(t, studentPast, 1, "不接收增量内容的订阅者不补发模型输出")
+	assert.False(t, hub.watched("t1"), "只有接收增量内容的订阅者才需要流式调用")
+
+	past, events, cancel := hub.subscribe("t1", true)
+	defer cancel()
+	assert.True(t, hub.watched("t1"))
+	require.Len(t, past, 2)
+	assert.Equal(t, TaskEventStarted, past[0].Type)
+	assert.Equal(t, "你好", past[1].Delta, "中途订阅时合并补发已输出的内容")
+
+	hub.publish(TaskEvent{Type: TaskEventToken, TaskID: "t1", Delta: "！"})
+	hub.publish(TaskEvent{Type: TaskEventCompleted, TaskID: "t1", Status: model.TaskStatusCompleted})
+	event := <-events
+	assert.Equal(t, "！", event.Delta)
+	event, ok := <-events
+	require.True(t, ok)
+	assert.True(t, event.Terminal())
+	event = <-studentEvents
+	assert.True(t, event.Terminal(), "增量内容不推送给学生")
+	_, ok = <-events
+	assert.False(t, ok, "任务结束后关闭订阅")
+	assert.Empty(t, hub.streams, "任务结束后丢弃历史")
+
+	// 任务离开本进程时关闭订阅，不等终止事件
+	hub.publish(TaskEvent{Type: TaskEventStarted, TaskID: "t2", Status: model.TaskStatusRunning})
+	_, events, cancel = hub.subscribe("t2", true)
+	hub.drop("t2")
+	_, ok = <-events
+	assert.False(t, ok)
+	assert.Empty(t, hub.streams)
+	cancel()
+}
+
+func TestAnalysisService_StreamsTaskEvents(t *testing.T) {
+	var calls int32
+	unescaped := strings.ReplaceAll(testAnalysisJSON, `\"`, `"`)
+	runes := []rune(unescaped)
+	pieces := []string{string(runes[:40]), string(runes[40:100]), string(runes[100:])}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Stream bool `json:"stream"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if !req.Stream {
+			t.Error("有增量内容观察者时应使用流式调用")
+		}
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeChatCompletionStream(w, pieces)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		OpenAI: config.OpenAIConfig{APIKey: "test-api-key", APIBase: server.URL, Model: "test-model",
+			MaxRetries: 1, RetryBaseDelay: 1, RetryMaxDelay: 10},
+		Analysis: config.AnalysisConfig{MaxConcurrency: 1, PollInterval: 1, Timeout: 10},
+	}
+	s, taskRepo, db := newTestAnalysisServiceWithAnalyzer(t, cfg, nil)
+	articleRepo := repository.NewArticleRepository(db)
+
+	article := &model.Article{Title: "作文", Author: "张三", Content: "作文内容", FilePath: "f.txt"}
+	require.NoError(t, articleRepo.Create(article))
+
+	_, _, _, err := s.SubscribeTaskEvents("missing", true)
+	assert.ErrorIs(t, err, ErrTaskNotFound)
+	assert.Empty(t, s.events.streams, "不存在的任务不创建订阅")
+
+	submitted, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{})
+	require.NoError(t, err)
+	past, events, cancel, err := s.SubscribeTaskEvents(submitted.TaskID, true)
+	require.NoError(t, err)
+	defer cancel()
+	require.Len(t, past, 1)
+	assert.Equal(t, TaskEventQueued, past[0].Type)
+
+	ctx, stop := context.WithCancel(context.Background())
+	s.Start(ctx)
+	defer func() {
+		stop()
+		s.Wait()
+	}()
+
+	var types []string
+	var output strings.Builder
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				done = true
+				break
+			}
+			assert.Equal(t, submitted.TaskID, event.TaskID)
+			if event.Type == TaskEventToken {
+				output.WriteString(event.Delta)
+				continue
+			}
+			types = append(types, event.Type)
+		case <-timeout:
+			t.Fatalf("未收到任务结束事件，已收到: %v", types)
+		}
+	}
+	assert.Equal(t, []string{TaskEventStarted, TaskEventRetrying, TaskEventCompleted}, types)
+	assert.Equal(t, unescaped, output.String())
+
+	task, err := taskRepo.GetByTaskID(submitted.TaskID)
+	require.NoError(t, err)
+	assert.Equal(t, model.TaskStatusCompleted, task.Status)
+	assert.Equal(t, "stream-model", task.Model)
+	assert.Equal(t, 34, task.CompletionTokens, "流式调用同样记录用量")
+
+	past, events, _, err = s.SubscribeTaskEvents(submitted.TaskID, true)
+	require.NoError(t, err)
+	assert.Nil(t, events)
+	require.Len(t, past, 1)
+	assert.Equal(t, TaskEventCompleted, past[0].Type, "任务结束后订阅直接返回最终状态")
+}
+
+func TestAnalysisService_RetryingEventDiscardsPartialOutput(t *testing.T) {
+	var calls int32
+	unescaped := strings.ReplaceAll(testAnalysisJSON, `\"`, `"`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// 第一次输出不符合结果格式，要求修正后重新输出
+			writeChatCompletionStream(w, []string{`{"core_viewpoints":`, `"不是数组"}`})
+			return
+		}
+		writeChatCompletionStream(w, []string{unescaped})
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		OpenAI:   config.OpenAIConfig{APIKey: "test-api-key", APIBase: server.URL, Model: "test-model"},
+		Analysis: config.AnalysisConfig{MaxConcurrency: 1, PollInterval: 1, Timeout: 10, RepairAttempts: 1},
+	}
+	s, _, db := newTestAnalysisServiceWithAnalyzer(t, cfg, nil)
+	articleRepo := repository.NewArticleRepository(db)
+
+	article := &model.Article{Title: "作文", Author: "张三", Content: "作文内容", FilePath: "f.txt"}
+	require.NoError(t, articleRepo.Create(article))
+	submitted, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{})
+	require.NoError(t, err)
+	_, events, cancel, err := s.SubscribeTaskEvents(submitted.TaskID, true)
+	require.NoError(t, err)
+	defer cancel()
+
+	ctx, stop := context.WithCancel(context.Background())
+	s.Start(ctx)
+	defer func() {
+		stop()
+		s.Wait()
+	}()
+
+	var types []string
+	var output strings.Builder
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				done = true
+				break
+			}
+			switch event.Type {
+			case TaskEventToken:
+				output.WriteString(event.Delta)
+				continue
+			case TaskEventRetrying:
+				output.Reset()
+			}
+			types = append(types, event.Type)
+		case <-timeout:
+			t.Fatalf("未收到任务结束事件，已收到: %v", types)
+		}
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Equal(t, []string{TaskEventStarted, TaskEventRetrying, TaskEventCompleted}, types,
+		"重新调用模型前通知客户端丢弃已输出的内容")
+	assert.Equal(t, unescaped, output.String())
+}
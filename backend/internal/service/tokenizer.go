@@ -0,0 +1,61 @@
+package service
+
+import (
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+// pretokenPattern 预分词规则，参照 cl100k 等 BPE 分词器的切分方式：
+// 中日韩文字逐字成段，英文单词连同前导空格、不超过三位的数字、标点串、空白串分别成段
+var pretokenPattern = regexp.MustCompile(`[\p{Han}\p{Hiragana}\p{Katakana}\p{Hangul}]|'(?:s|t|re|ve|m|ll|d)|\s?[^\P{L}\p{Han}\p{Hiragana}\p{Katakana}\p{Hangul}]+|\s?\p{N}{1,3}|\s?[^\s\p{L}\p{N}]+|\s+`)
+
+// estimateTokens 估算文本的 token 数
+//
+// 各家模型的分词器不同且多数未公开，这里先按 BPE 分词器的规则预分词，再按经验估计每段的 token 数：
+// 中日韩文字每字 1 个，英文单词每 6 个字符 1 个，数字每段 1 个，标点串中全角标点每个 1 个、其余每 2 个 1 个，空白串 1 个。
+// 同一段文本的任意子串估算值都不超过其字符数。
+func estimateTokens(text string) int {
+	tokens := 0
+	for _, piece := range pretokenPattern.FindAllString(text, -1) {
+		tokens += pieceTokens(piece)
+	}
+	return tokens
+}
+
+func pieceTokens(piece string) int {
+	first, _ := utf8.DecodeRuneInString(piece)
+	n := utf8.RuneCountInString(piece)
+	switch {
+	case isWideRune(first):
+		return n
+	case unicode.IsSpace(first) && n > 1:
+		second, _ := utf8.DecodeRuneInString(piece[utf8.RuneLen(first):])
+		if unicode.IsSpace(second) {
+			return 1
+		}
+		return pieceTokens(piece[utf8.RuneLen(first):])
+	case unicode.IsSpace(first):
+		return 1
+	case unicode.IsLetter(first) || first == '\'':
+		return (n + 5) / 6
+	case unicode.IsNumber(first):
+		return 1
+	default:
+		wide, narrow := 0, 0
+		for _, r := range piece {
+			if isWideRune(r) {
+				wide++
+			} else {
+				narrow++
+			}
+		}
+		return wide + (narrow+1)/2
+	}
+}
+
+func isWideRune(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		(r >= 0x3000 && r <= 0x303F) || // 中日韩标点
+		(r >= 0xFF00 && r <= 0xFFEF) // 全角字符
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"article-analysis/internal/repository"
+)
+
+// ErrInvalidUsageQuery 用量统计参数错误
+var ErrInvalidUsageQuery = errors.New("统计参数错误")
+
+const usageDateLayout = "2006-01-02"
+
+// UsageReport 模型用量报表
+type UsageReport struct {
+	From     string                `json:"from"`
+	To       string                `json:"to"`
+	GroupBy  []string              `json:"group_by"`
+	Currency string                `json:"currency"`
+	Rows     []repository.UsageRow `json:"rows"`
+	Total    repository.UsageRow   `json:"total"`
+}
+
+// callCost 按模型配置的价格计算一次调用的费用，未配置价格时为 0
+func (s *AnalysisService) callCost(profile string, promptTokens, completionTokens int) float64 {
+	p, ok := s.profileConfig(profile)
+	if !ok {
+		return 0
+	}
+	return float64(promptTokens)*p.InputPrice/1e6 + float64(completionTokens)*p.OutputPrice/1e6
+}
+
+// UsageReport 统计 [from, to] 日期范围内的模型用量，按 groupBy 指定的维度（day/model/author）汇总
+//
+// from 默认为当月第一天，to 默认为今天，groupBy 默认按天。
+func (s *AnalysisService) UsageReport(from, to string, groupBy []string) (*UsageReport, error) {
+	now := time.Now()
+	fromDate := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	toDate := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	var err error
+	if from != "" {
+		if fromDate, err = time.ParseInLocation(usageDateLayout, from, time.Local); err != nil {
+			return nil, fmt.Errorf("%w：开始日期格式应为 YYYY-MM-DD", ErrInvalidUsageQuery)
+		}
+	}
+	if to != "" {
+		if toDate, err = time.ParseInLocation(usageDateLayout, to, time.Local); err != nil {
+			return nil, fmt.Errorf("%w：结束日期格式应为 YYYY-MM-DD", ErrInvalidUsageQuery)
+		}
+	}
+	if toDate.Before(fromDate) {
+		return nil, fmt.Errorf("%w：结束日期早于开始日期", ErrInvalidUsageQuery)
+	}
+
+	if len(groupBy) == 0 {
+		groupBy = []string{repository.UsageGroupDay}
+	}
+	seen := make(map[string]bool)
+	dims := make([]string, 0, len(groupBy))
+	for _, dim := range groupBy {
+		switch dim {
+		case repository.UsageGroupDay, repository.UsageGroupModel, repository.UsageGroupAuthor, repository.UsageGroupRequester:
+		default:
+			return nil, fmt.Errorf("%w：不支持的分组维度 %s", ErrInvalidUsageQuery, dim)
+		}
+		if !seen[dim] {
+			seen[dim] = true
+			dims = append(dims, dim)
+		}
+	}
+
+	query := repository.UsageQuery{From: fromDate, To: toDate.AddDate(0, 0, 1), GroupBy: dims}
+	rows, err := s.taskRepo.UsageReport(query)
+	if err != nil {
+		s.log.Error("统计模型用量失败", err)
+		return nil, errors.New("统计模型用量失败")
+	}
+	if rows == nil {
+		rows = []repository.UsageRow{}
+	}
+
+	query.GroupBy = nil
+	totals, err := s.taskRepo.UsageReport(query)
+	if err != nil {
+		s.log.Error("统计模型用量失败", err)
+		return nil, errors.New("统计模型用量失败")
+	}
+
+	report := &UsageReport{
+		From:     fromDate.Format(usageDateLayout),
+		To:       toDate.Format(usageDateLayout),
+		GroupBy:  dims,
+		Currency: s.currency,
+		Rows:     rows,
+	}
+	if len(totals) > 0 {
+		report.Total = totals[0]
+	}
+	return report, nil
+}
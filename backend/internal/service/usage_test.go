@@ -0,0 +1,84 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"article-analysis/internal/config"
+	"article-analysis/internal/model"
+	"article-analysis/internal/repository"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalysisService_RecordsUsagePerRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":"1","object":"chat.completion","model":"served-model","choices":[{"index":0,"message":{"role":"assistant","content":"%s"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1000,"completion_tokens":200,"total_tokens":1200}}`, testAnalysisJSON)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		OpenAI: config.OpenAIConfig{
+			APIKey:      "test-api-key",
+			APIBase:     server.URL,
+			Model:       "test-model",
+			InputPrice:  4,
+			OutputPrice: 16,
+			Currency:    "CNY",
+		},
+		Analysis: config.AnalysisConfig{MaxConcurrency: 1, PollInterval: 1, Timeout: 10},
+	}
+	s, taskRepo, db := newTestAnalysisServiceWithAnalyzer(t, cfg, nil)
+	articleRepo := repository.NewArticleRepository(db)
+
+	for i, author := range []string{"张三", "张三", "李四"} {
+		// 内容各不相同，避免命中分析结果缓存
+		article := &model.Article{Title: "文章" + author, Author: author, Content: fmt.Sprintf("第%d篇内容", i+1), FilePath: "f.txt"}
+		require.NoError(t, articleRepo.Create(article))
+		submitted, err := s.AnalyzeArticle(article.ID, AnalyzeOptions{})
+		require.NoError(t, err)
+
+		task := runUntilFinished(t, s, taskRepo, submitted.TaskID)
+		require.Equal(t, model.TaskStatusCompleted, task.Status)
+		assert.Equal(t, 1000, task.PromptTokens)
+		assert.Equal(t, 200, task.CompletionTokens)
+		assert.InDelta(t, 0.0072, task.Cost, 1e-9)
+		assert.Equal(t, "served-model", task.Model)
+
+		attempts, err := taskRepo.ListAttempts(task.ID)
+		require.NoError(t, err)
+		require.Len(t, attempts, 1)
+		assert.Equal(t, "served-model", attempts[0].Model)
+		assert.Equal(t, 1000, attempts[0].PromptTokens)
+	}
+
+	today := time.Now().Format("2006-01-02")
+	report, err := s.UsageReport(today, today, []string{"day", "author"})
+	require.NoError(t, err)
+	require.Len(t, report.Rows, 2)
+	assert.Equal(t, today, report.Rows[0].Day)
+	assert.Equal(t, "张三", report.Rows[0].Author)
+	assert.Equal(t, int64(2), report.Rows[0].Runs)
+	assert.Equal(t, int64(2000), report.Rows[0].PromptTokens)
+	assert.Equal(t, "李四", report.Rows[1].Author)
+	assert.Equal(t, int64(3), report.Total.Runs)
+	assert.Equal(t, int64(600), report.Total.CompletionTokens)
+	assert.InDelta(t, 0.0216, report.Total.Cost, 1e-9)
+	assert.Equal(t, "CNY", report.Currency)
+
+	report, err = s.UsageReport("", "", []string{"model"})
+	require.NoError(t, err)
+	require.Len(t, report.Rows, 1)
+	assert.Equal(t, "served-model", report.Rows[0].Model)
+	assert.Equal(t, int64(3), report.Rows[0].Calls)
+
+	_, err = s.UsageReport("2026/01/01", "", nil)
+	assert.ErrorIs(t, err, ErrInvalidUsageQuery)
+	_, err = s.UsageReport("", "", []string{"team"})
+	assert.ErrorIs(t, err, ErrInvalidUsageQuery)
+}
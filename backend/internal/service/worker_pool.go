@@ -0,0 +1,268 @@
+package service
+
+import (
+	"article-analysis/internal/config"
+	"article-analysis/internal/model"
+	"article-analysis/internal/repository"
+	"article-analysis/pkg/logger"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// errTaskInterrupted 任务因服务停止或失去租约而中断，应放回队列而不是标记失败
+	errTaskInterrupted = errors.New("分析任务被中断")
+	// errTaskCancelled 任务被用户取消，状态已在取消时写入，工作池无需再更新
+	errTaskCancelled = errors.New("分析任务已取消")
+	// errLeaseLost 续约失败，任务已被回收给其他执行者
+	errLeaseLost = errors.New("任务租约已失效")
+)
+
+// TaskHandler 执行单个已认领的任务，返回错误时任务被标记为失败
+type TaskHandler func(ctx context.Context, task *model.AnalysisTask) error
+
+// TaskStatusHook 工作池更新任务状态后回调，status 为任务结束的状态或中断后重新排队的 queued
+type TaskStatusHook func(task *model.AnalysisTask, status, errorMsg string)
+
+// TaskReleaseHook 任务被取消或回收给其他执行者、工作池未更新其状态就停止执行时回调
+type TaskReleaseHook func(task *model.AnalysisTask)
+
+// WorkerPool 固定数量的工作协程，从任务表中认领并执行分析任务
+//
+// 每个执行中的任务持有一个租约，工作协程按心跳间隔续约；进程退出或失联后租约过期，
+// 由 AnalysisService 的回收流程重新排队或标记失败。
+type WorkerPool struct {
+	taskRepo     *repository.TaskRepository
+	handler      TaskHandler
+	concurrency  int
+	pollInterval time.Duration
+	lease        time.Duration
+	heartbeat    time.Duration
+	instanceID   string
+	wake         chan struct{}
+	wg           sync.WaitGroup
+	mu           sync.Mutex
+	running      map[uint64]context.CancelCauseFunc
+	onStatus     TaskStatusHook
+	onRelease    TaskReleaseHook
+	log          *logger.Logger
+}
+
+func NewWorkerPool(taskRepo *repository.TaskRepository, cfg config.AnalysisConfig, handler TaskHandler, log *logger.Logger) *WorkerPool {
+	concurrency := cfg.MaxConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	pollInterval := time.Duration(cfg.PollInterval) * time.Second
+	if pollInterval <= 0 {
+		pollInterval = 2 * time.Second
+	}
+	lease := time.Duration(cfg.LeaseDuration) * time.Second
+	if lease <= 0 {
+		lease = 60 * time.Second
+	}
+	heartbeat := time.Duration(cfg.Heartbeat) * time.Second
+	if heartbeat <= 0 || heartbeat >= lease {
+		heartbeat = lease / 3
+	}
+
+	hostname, _ := os.Hostname()
+	return &WorkerPool{
+		taskRepo:     taskRepo,
+		handler:      handler,
+		concurrency:  concurrency,
+		pollInterval: pollInterval,
+		lease:        lease,
+		heartbeat:    heartbeat,
+		instanceID:   fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), newOpaqueID()[:8]),
+		wake:         make(chan struct{}, concurrency),
+		running:      make(map[uint64]context.CancelCauseFunc),
+		log:          log,
+	}
+}
+
+// Start 启动工作协程，ctx 取消后工作协程中断当前任务并退出
+func (p *WorkerPool) Start(ctx context.Context) {
+	for i := 0; i < p.concurrency; i++ {
+		p.wg.Add(1)
+		go p.worker(ctx, fmt.Sprintf("%s/%d", p.instanceID, i))
+	}
+	p.log.Info("分析工作池已启动",
+		zap.Int("concurrency", p.concurrency),
+		zap.String("instance", p.instanceID))
+}
+
+// Wait 等待所有工作协程退出
+func (p *WorkerPool) Wait() {
+	p.wg.Wait()
+}
+
+// Notify 唤醒空闲的工作协程，新任务入队后调用以免等待下一次轮询
+func (p *WorkerPool) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// OnStatusChange 设置任务状态更新后的回调，需在 Start 之前调用
+func (p *WorkerPool) OnStatusChange(hook TaskStatusHook) {
+	p.onStatus = hook
+}
+
+// OnRelease 设置任务不再由本执行者持有时的回调，需在 Start 之前调用
+func (p *WorkerPool) OnRelease(hook TaskReleaseHook) {
+	p.onRelease = hook
+}
+
+// Cancel 取消本实例正在执行的任务，任务不在本实例执行时返回 false
+//
+// 其他实例上执行的任务会在下一次续约时发现已被取消。
+func (p *WorkerPool) Cancel(taskID uint64) bool {
+	p.mu.Lock()
+	cancel, ok := p.running[taskID]
+	p.mu.Unlock()
+	if ok {
+		cancel(errTaskCancelled)
+	}
+	return ok
+}
+
+func (p *WorkerPool) worker(ctx context.Context, workerID string) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		task, err := p.taskRepo.ClaimNext(workerID, p.lease)
+		if err != nil {
+			p.log.Error("认领分析任务失败", err, zap.String("worker", workerID))
+		}
+		if task != nil {
+			p.run(ctx, task)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *WorkerPool) run(ctx context.Context, task *model.AnalysisTask) {
+	taskCtx, cancel := context.WithCancelCause(ctx)
+	p.mu.Lock()
+	p.running[task.ID] = cancel
+	p.mu.Unlock()
+
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		p.keepAlive(taskCtx, cancel, task)
+	}()
+	defer func() {
+		cancel(nil)
+		<-heartbeatDone
+		p.mu.Lock()
+		delete(p.running, task.ID)
+		p.mu.Unlock()
+	}()
+
+	defer func() {
+		if r := recover(); r != nil {
+			p.log.Error("分析任务异常退出", nil, zap.String("task_id", task.TaskID), zap.Any("panic", r))
+			p.finish(task, model.TaskStatusFailed, "任务执行异常")
+		}
+	}()
+
+	err := p.handler(taskCtx, task)
+	switch {
+	case err == nil:
+		p.finish(task, model.TaskStatusCompleted, "")
+	case errors.Is(err, errTaskCancelled):
+		p.log.Info("任务已取消", zap.String("task_id", task.TaskID))
+		p.notifyReleased(task)
+	case errors.Is(err, errTaskInterrupted):
+		requeued, rqErr := p.taskRepo.Requeue(task.ID, task.WorkerID, true)
+		if rqErr != nil {
+			p.log.Error("任务重新排队失败", rqErr, zap.String("task_id", task.TaskID))
+		} else if requeued {
+			p.log.Info("任务已中断并重新排队", zap.String("task_id", task.TaskID))
+			p.notifyStatus(task, model.TaskStatusQueued, "")
+		} else {
+			p.notifyReleased(task)
+		}
+	default:
+		p.finish(task, model.TaskStatusFailed, err.Error())
+	}
+}
+
+// keepAlive 按心跳间隔续约；续约失败说明任务已被取消或回收，以对应原因取消任务上下文
+func (p *WorkerPool) keepAlive(ctx context.Context, cancel context.CancelCauseFunc, task *model.AnalysisTask) {
+	ticker := time.NewTicker(p.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			held, err := p.taskRepo.Heartbeat(task.ID, task.WorkerID, p.lease)
+			if err != nil {
+				p.log.Warn("任务续约失败", zap.String("task_id", task.TaskID), zap.Error(err))
+				continue
+			}
+			if held {
+				continue
+			}
+			if current, err := p.taskRepo.GetByID(task.ID); err == nil && current.Status == model.TaskStatusCancelled {
+				cancel(errTaskCancelled)
+				return
+			}
+			p.log.Warn("任务租约已失效，停止执行", zap.String("task_id", task.TaskID))
+			cancel(errLeaseLost)
+			return
+		}
+	}
+}
+
+func (p *WorkerPool) finish(task *model.AnalysisTask, status, errorMsg string) {
+	finished, err := p.taskRepo.Finish(task.ID, task.WorkerID, status, errorMsg)
+	if err != nil {
+		p.log.Error("更新任务状态失败", err, zap.String("task_id", task.TaskID))
+		return
+	}
+	if !finished {
+		// 任务已被取消或回收给其他执行者，状态由对方负责通知
+		p.log.Info("任务已不由本执行者持有，不更新状态", zap.String("task_id", task.TaskID))
+		p.notifyReleased(task)
+		return
+	}
+	p.notifyStatus(task, status, errorMsg)
+}
+
+func (p *WorkerPool) notifyStatus(task *model.AnalysisTask, status, errorMsg string) {
+	if p.onStatus != nil {
+		p.onStatus(task, status, errorMsg)
+	}
+}
+
+func (p *WorkerPool) notifyReleased(task *model.AnalysisTask) {
+	if p.onRelease != nil {
+		p.onRelease(task)
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"article-analysis/internal/config"
+	"article-analysis/internal/model"
+	"article-analysis/internal/repository"
+	"article-analysis/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newTestDB 创建临时SQLite数据库并迁移任务相关表
+func newTestDB(t *testing.T) *gorm.DB {
+	dsn := "file:" + filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=5000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	require.NoError(t, db.AutoMigrate(
+		&repository.Article{},
+		&repository.ArticleAnalysis{},
+		&model.AnalysisTask{},
+		&model.AnalysisTaskAttempt{},
+		&model.AnalysisBatch{},
+		&model.BudgetAlert{},
+		&model.AnalysisCache{},
+		&model.AnalysisSchema{},
+		&model.AnalysisRevision{},
+	))
+	return db
+}
+
+func TestWorkerPool_RespectsConcurrencyLimit(t *testing.T) {
+	db := newTestDB(t)
+	taskRepo := repository.NewTaskRepository(db)
+
+	for i := 0; i < 6; i++ {
+		require.NoError(t, taskRepo.Create(&model.AnalysisTask{TaskID: newOpaqueID(), ArticleID: uint64(i + 1), Status: model.TaskStatusQueued}))
+	}
+
+	var running, maxRunning int32
+	var mu sync.Mutex
+	handled := map[uint64]int{}
+
+	handler := func(ctx context.Context, task *model.AnalysisTask) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+
+		mu.Lock()
+		handled[task.ID]++
+		mu.Unlock()
+		return nil
+	}
+
+	cfg := config.AnalysisConfig{MaxConcurrency: 2, PollInterval: 1}
+	pool := NewWorkerPool(taskRepo, cfg, handler, logger.NewLogger("error"))
+	ctx, cancel := context.WithCancel(context.Background())
+	pool.Start(ctx)
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(handled) == 6
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	pool.Wait()
+
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxRunning), int32(2), "并发数不应超过配置的上限")
+	for id, count := range handled {
+		assert.Equal(t, 1, count, "任务 %d 应只被执行一次", id)
+	}
+
+	var completed int64
+	db.Model(&model.AnalysisTask{}).Where("status = ?", model.TaskStatusCompleted).Count(&completed)
+	assert.Equal(t, int64(6), completed)
+}
+
+func TestWorkerPool_InterruptedTaskIsRequeued(t *testing.T) {
+	db := newTestDB(t)
+	taskRepo := repository.NewTaskRepository(db)
+
+	task := &model.AnalysisTask{TaskID: newOpaqueID(), ArticleID: 1, Status: model.TaskStatusQueued}
+	require.NoError(t, taskRepo.Create(task))
+
+	started := make(chan struct{})
+	handler := func(ctx context.Context, task *model.AnalysisTask) error {
+		close(started)
+		<-ctx.Done()
+		return errTaskInterrupted
+	}
+
+	cfg := config.AnalysisConfig{MaxConcurrency: 1, PollInterval: 1}
+	pool := NewWorkerPool(taskRepo, cfg, handler, logger.NewLogger("error"))
+	ctx, cancel := context.WithCancel(context.Background())
+	pool.Start(ctx)
+
+	<-started
+	cancel()
+	pool.Wait()
+
+	got, err := taskRepo.GetByID(task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.TaskStatusQueued, got.Status, "服务停止时执行中的任务应重新排队")
+	assert.Equal(t, 0, got.Attempts, "被中断的执行不计入次数")
+	assert.Nil(t, got.LeaseUntil)
+}
+
+func TestWorkerPool_CancelRunningTaskFreesWorker(t *testing.T) {
+	db := newTestDB(t)
+	taskRepo := repository.NewTaskRepository(db)
+
+	first := &model.AnalysisTask{TaskID: newOpaqueID(), ArticleID: 1, Status: model.TaskStatusQueued}
+	second := &model.AnalysisTask{TaskID: newOpaqueID(), ArticleID: 2, Status: model.TaskStatusQueued}
+	require.NoError(t, taskRepo.Create(first))
+	require.NoError(t, taskRepo.Create(second))
+
+	started := make(chan struct{})
+	secondDone := make(chan struct{})
+	handler := func(ctx context.Context, task *model.AnalysisTask) error {
+		if task.ID == first.ID {
+			close(started)
+			<-ctx.Done()
+			if errors.Is(context.Cause(ctx), errTaskCancelled) {
+				return errTaskCancelled
+			}
+			return errTaskInterrupted
+		}
+		close(secondDone)
+		return nil
+	}
+
+	cfg := config.AnalysisConfig{MaxConcurrency: 1, PollInterval: 1}
+	pool := NewWorkerPool(taskRepo, cfg, handler, logger.NewLogger("error"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		pool.Wait()
+	}()
+	pool.Start(ctx)
+
+	<-started
+	previous, err := taskRepo.Cancel(first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.TaskStatusRunning, previous)
+	assert.True(t, pool.Cancel(first.ID))
+
+	select {
+	case <-secondDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("取消任务后工作协程应被释放并执行下一个任务")
+	}
+
+	got, err := taskRepo.GetByID(first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.TaskStatusCancelled, got.Status)
+	assert.NotNil(t, got.FinishedAt)
+}
@@ -23,20 +23,174 @@ CREATE TABLE IF NOT EXISTS articles (
 CREATE TABLE IF NOT EXISTS article_analyses (
     id BIGINT PRIMARY KEY AUTO_INCREMENT,
     article_id BIGINT NOT NULL COMMENT '文章ID',
+    version INT NOT NULL DEFAULT 0 COMMENT '文章的第几次分析',
+    is_current TINYINT(1) NOT NULL DEFAULT 0 COMMENT '是否为文章的当前结果',
     core_viewpoints TEXT COMMENT '核心观点',
     file_structure TEXT COMMENT '文件结构',
     author_thoughts TEXT COMMENT '作者思路',
     related_materials TEXT COMMENT '相关素材与事例',
-    analysis_status ENUM('pending','processing','completed','failed') DEFAULT 'pending' COMMENT '分析状态',
+    analysis_status ENUM('pending','processing','completed','failed','cancelled') DEFAULT 'pending' COMMENT '分析状态',
     analysis_time TIMESTAMP NULL COMMENT '分析完成时间',
     error_message TEXT COMMENT '错误信息',
+    provider VARCHAR(50) COMMENT '实际完成分析的模型服务',
+    profile VARCHAR(100) COMMENT '实际使用的模型配置',
+    model VARCHAR(100) COMMENT '实际使用的模型',
+    prompt_version VARCHAR(50) COMMENT '生成结果所用的提示词版本',
+    result JSON COMMENT '结构化分析结果，各维度的要点列表',
+    `schema` VARCHAR(100) COMMENT '使用的分析方案',
+    genre VARCHAR(20) COMMENT '分析时采用的文章体裁',
+    genre_source VARCHAR(10) COMMENT '体裁来源 auto/user',
+    fields JSON COMMENT '自定义分析方案的结果',
+    schema_fields JSON COMMENT '分析时自定义方案的维度快照',
+    review_status VARCHAR(20) NOT NULL DEFAULT 'draft' COMMENT '审核状态 draft/reviewed/published',
+    revision INT NOT NULL DEFAULT 0 COMMENT '人工修改和审核的次数',
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     FOREIGN KEY (article_id) REFERENCES articles(id) ON DELETE CASCADE,
+    UNIQUE INDEX idx_article_version (article_id, version),
     INDEX idx_article_id (article_id),
-    INDEX idx_status (analysis_status)
+    INDEX idx_is_current (is_current),
+    INDEX idx_status (analysis_status),
+    INDEX idx_review_status (review_status)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='文章分析结果表';
 
+-- 创建分析任务表
+CREATE TABLE IF NOT EXISTS analysis_tasks (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    task_id VARCHAR(64) NOT NULL COMMENT '对外暴露的任务ID',
+    batch_id VARCHAR(64) COMMENT '所属批次ID',
+    article_id BIGINT NOT NULL COMMENT '文章ID',
+    analysis_id BIGINT NOT NULL COMMENT '分析记录ID',
+    status VARCHAR(20) NOT NULL DEFAULT 'queued' COMMENT '任务状态',
+    progress INT NOT NULL DEFAULT 0 COMMENT '进度(0-100)',
+    attempts INT NOT NULL DEFAULT 0 COMMENT '执行次数',
+    profile VARCHAR(100) COMMENT '模型配置名',
+    model VARCHAR(100) COMMENT '使用的模型',
+    requester VARCHAR(100) COMMENT '提交任务的调用方',
+    error_message TEXT COMMENT '错误信息',
+    worker_id VARCHAR(200) COMMENT '执行者标识',
+    lease_until TIMESTAMP NULL COMMENT '租约到期时间',
+    started_at TIMESTAMP NULL COMMENT '开始执行时间',
+    finished_at TIMESTAMP NULL COMMENT '结束时间',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    prompt_tokens INT NOT NULL DEFAULT 0 COMMENT '累计输入token数',
+    completion_tokens INT NOT NULL DEFAULT 0 COMMENT '累计输出token数',
+    latency_ms BIGINT NOT NULL DEFAULT 0 COMMENT '模型调用累计耗时(毫秒)',
+    cost DECIMAL(14,6) NOT NULL DEFAULT 0 COMMENT '累计费用',
+    estimated_tokens INT NOT NULL DEFAULT 0 COMMENT '提交时预估的token数',
+    estimated_cost DECIMAL(14,6) NOT NULL DEFAULT 0 COMMENT '提交时预估的费用',
+    prompt_version VARCHAR(50) COMMENT '使用的提示词版本',
+    `schema` VARCHAR(100) COMMENT '使用的分析方案',
+    genre VARCHAR(20) COMMENT '文章体裁',
+    genre_source VARCHAR(10) COMMENT '体裁来源 auto/user',
+    force_refresh TINYINT(1) NOT NULL DEFAULT 0 COMMENT '是否忽略缓存重新分析',
+    cache_hit TINYINT(1) NOT NULL DEFAULT 0 COMMENT '结果是否来自缓存',
+    comparison TINYINT(1) NOT NULL DEFAULT 0 COMMENT '是否为对比分析的任务',
+    active_article_id BIGINT NULL COMMENT '排队中或执行中的非对比任务所属文章，结束时清空',
+    UNIQUE INDEX idx_task_id (task_id),
+    UNIQUE INDEX idx_active_article_id (active_article_id),
+    INDEX idx_requester (requester),
+    INDEX idx_batch_id (batch_id),
+    INDEX idx_article_id (article_id),
+    INDEX idx_analysis_id (analysis_id),
+    INDEX idx_status (status),
+    INDEX idx_lease_until (lease_until)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='分析任务表';
+
+-- 创建批量分析批次表
+CREATE TABLE IF NOT EXISTS analysis_batches (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    batch_id VARCHAR(64) NOT NULL COMMENT '对外暴露的批次ID',
+    requested INT NOT NULL DEFAULT 0 COMMENT '请求分析的文章数',
+    queued INT NOT NULL DEFAULT 0 COMMENT '实际入队的任务数',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE INDEX idx_batch_id (batch_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='批量分析批次表';
+
+-- 创建模型调用尝试记录表
+CREATE TABLE IF NOT EXISTS analysis_task_attempts (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    task_id BIGINT NOT NULL COMMENT '任务ID',
+    attempt INT NOT NULL COMMENT '第几次尝试',
+    profile VARCHAR(100) COMMENT '调用的模型配置',
+    model VARCHAR(100) COMMENT '调用的模型',
+    status_code INT COMMENT 'HTTP状态码',
+    retryable BOOLEAN COMMENT '错误是否可重试',
+    error_message TEXT COMMENT '错误信息',
+    duration_ms BIGINT COMMENT '调用耗时(毫秒)',
+    wait_ms BIGINT COMMENT '重试前等待(毫秒)',
+    prompt_tokens INT NOT NULL DEFAULT 0 COMMENT '输入token数',
+    completion_tokens INT NOT NULL DEFAULT 0 COMMENT '输出token数',
+    cost DECIMAL(14,6) NOT NULL DEFAULT 0 COMMENT '费用',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_task_id (task_id),
+    INDEX idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='模型调用尝试记录表';
+
+-- 创建预算预警表
+CREATE TABLE IF NOT EXISTS budget_alerts (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    scope VARCHAR(100) NOT NULL COMMENT 'global 或调用方标识',
+    period VARCHAR(20) NOT NULL COMMENT '统计周期 daily/monthly',
+    period_key VARCHAR(20) NOT NULL COMMENT '周期标识',
+    metric VARCHAR(20) NOT NULL COMMENT '额度类型 tokens/cost',
+    level VARCHAR(20) NOT NULL COMMENT '预警级别 warning/exhausted',
+    used DOUBLE COMMENT '触发时的用量',
+    limit_value DOUBLE COMMENT '额度',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE INDEX idx_budget_alert (scope, period, period_key, metric, level),
+    INDEX idx_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='预算预警表';
+
+-- 创建分析结果缓存表
+CREATE TABLE IF NOT EXISTS analysis_caches (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    cache_key CHAR(64) NOT NULL COMMENT '内容、提示词版本、模型及参数的SHA-256',
+    prompt_version VARCHAR(50) COMMENT '提示词版本',
+    provider VARCHAR(50) COMMENT '模型服务提供方',
+    profile VARCHAR(100) COMMENT '模型配置名',
+    model VARCHAR(100) COMMENT '实际使用的模型',
+    core_viewpoints TEXT COMMENT '核心观点',
+    file_structure TEXT COMMENT '文件结构',
+    author_thoughts TEXT COMMENT '作者思路',
+    related_materials TEXT COMMENT '相关素材',
+    result JSON COMMENT '结构化分析结果',
+    fields JSON COMMENT '自定义分析方案的结果',
+    hits INT NOT NULL DEFAULT 0 COMMENT '命中次数',
+    last_hit_at TIMESTAMP NULL COMMENT '最近命中时间',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE INDEX idx_cache_key (cache_key)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='分析结果缓存表';
+
+-- 创建分析方案表
+CREATE TABLE IF NOT EXISTS analysis_schemas (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(100) NOT NULL COMMENT '方案名称',
+    description TEXT COMMENT '方案说明',
+    fields JSON COMMENT '分析维度',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE INDEX idx_name (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='分析方案表';
+
+-- 创建分析结果修改记录表
+CREATE TABLE IF NOT EXISTS analysis_revisions (
+    id BIGINT PRIMARY KEY AUTO_INCREMENT,
+    analysis_id BIGINT NOT NULL COMMENT '分析记录ID',
+    article_id BIGINT NOT NULL COMMENT '文章ID',
+    revision INT NOT NULL COMMENT '修改后分析记录的修订号',
+    editor VARCHAR(100) COMMENT '修改人，由 API Key 识别的调用方标识',
+    editor_name VARCHAR(100) COMMENT '修改人显示名称，未经验证',
+    action VARCHAR(20) NOT NULL COMMENT '修改类型 edit/review',
+    changes JSON COMMENT '各字段修改前后的值',
+    comment VARCHAR(500) COMMENT '修改说明',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_analysis_id (analysis_id),
+    INDEX idx_article_id (article_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='分析结果修改记录表';
+
 -- 插入测试数据
 INSERT INTO articles (title, author, content, file_path, file_size) VALUES
 ('人工智能的未来发展', '张三', '人工智能技术正在快速发展...', '/uploads/test1.txt', 1024),
@@ -46,4 +200,4 @@ INSERT INTO articles (title, author, content, file_path, file_size) VALUES
 -- 创建分析结果测试数据
 INSERT INTO article_analyses (article_id, core_viewpoints, file_structure, author_thoughts, related_materials, analysis_status, analysis_time) VALUES
 (1, 'AI将改变人类社会的各个方面', '总-分-总结构', '从宏观角度分析AI发展趋势', '引用了大量研究数据和案例', 'completed', NOW()),
-(2, '机器学习是AI的核心技术', '教程式结构', '循序渐进地介绍ML概念', '提供了实际代码示例', 'completed', NOW());
\ No newline at end of file
+(2, '机器学习是AI的核心技术', '教程式结构', '循序渐进地介绍ML概念', '提供了实际代码示例', 'completed', NOW());
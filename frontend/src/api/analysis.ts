@@ -1,14 +1,34 @@
 import api from '@/api/request'
-import type { ArticleAnalysis, ApiResponse } from '@/types'
+import type {
+  ArticleAnalysis,
+  AnalysisComparison,
+  AnalysisItem,
+  AnalysisRevision,
+  AnalysisSchema,
+  ApiResponse,
+  GenreInfo,
+  ReviewStatus
+} from '@/types'
 
 export interface CreateAnalysisRequest {
   article_id: string
+  profile?: string
+  prompt_version?: string
+  schema?: string
+  genre?: string
+  force?: boolean
 }
 
 export const analysisApi = {
   // 创建分析任务
   createAnalysis: (data: CreateAnalysisRequest) => {
-    return api.post<ApiResponse<{ task_id: string }>>(`/articles/${data.article_id}/analyze`, {})
+    return api.post<ApiResponse<{ task_id: string }>>(`/articles/${data.article_id}/analyze`, {
+      profile: data.profile,
+      prompt_version: data.prompt_version,
+      schema: data.schema,
+      genre: data.genre,
+      force: data.force
+    })
   },
 
   // 获取分析结果
@@ -16,8 +36,74 @@ export const analysisApi = {
     return api.get<ApiResponse<ArticleAnalysis>>(`/articles/${articleId}/analysis`)
   },
 
+  // 修改当前分析结果，fields 按维度字段名给出修改后的值（需要编辑权限）
+  updateAnalysis: (
+    articleId: string,
+    data: { fields: Record<string, AnalysisItem[] | string | string[]>; revision?: number; comment?: string }
+  ) => {
+    return api.put<ApiResponse<ArticleAnalysis>>(`/articles/${articleId}/analysis`, data)
+  },
+
+  // 变更当前分析结果的审核状态（需要编辑权限）
+  setReviewStatus: (articleId: string, data: { status: ReviewStatus; revision?: number; comment?: string }) => {
+    return api.put<ApiResponse<ArticleAnalysis>>(`/articles/${articleId}/analysis/status`, data)
+  },
+
+  // 获取当前分析结果的修改记录（需要编辑权限）
+  listRevisions: (articleId: string) => {
+    return api.get<ApiResponse<AnalysisRevision[]>>(`/articles/${articleId}/analysis/revisions`)
+  },
+
+  // 获取文章的历次分析记录
+  listAnalyses: (articleId: string) => {
+    return api.get<ApiResponse<ArticleAnalysis[]>>(`/articles/${articleId}/analyses`)
+  },
+
+  // 将某次分析设为当前结果
+  setCurrentAnalysis: (articleId: string, analysisId: string) => {
+    return api.put<ApiResponse<ArticleAnalysis>>(`/articles/${articleId}/analyses/${analysisId}/current`)
+  },
+
+  // 对比文章的两次分析
+  compareAnalyses: (articleId: string, a: string, b: string) => {
+    return api.get<ApiResponse<AnalysisComparison>>(`/articles/${articleId}/analyses/compare`, { params: { a, b } })
+  },
+
+  // 用两个模型配置同时分析文章，用于对比
+  analyzeWithProfiles: (articleId: string, profiles: string[]) => {
+    return api.post<ApiResponse<{ batch_id: string; runs: { profile: string; task_id: string; analysis_id: string }[] }>>(
+      `/articles/${articleId}/analyze/compare`,
+      { profiles }
+    )
+  },
+
   // 获取分析状态
   getAnalysisStatus: (taskId: string) => {
     return api.get<ApiResponse<{ status: string; result?: ArticleAnalysis }>>(`/analysis/status/${taskId}`)
+  },
+
+  // 订阅分析任务事件（Server-Sent Events），任务结束后需调用 close
+  openTaskEvents: (taskId: string) => {
+    return new EventSource(`/api/analysis/tasks/${taskId}/events`)
+  },
+
+  // 取消分析任务
+  cancelAnalysis: (taskId: string) => {
+    return api.delete<ApiResponse<{ task_id: string; status: string }>>(`/analysis/tasks/${taskId}`)
+  },
+
+  // 获取可选的文章体裁
+  listGenres: () => {
+    return api.get<ApiResponse<GenreInfo[]>>('/analysis/genres')
+  },
+
+  // 获取可用的分析方案
+  listSchemas: () => {
+    return api.get<ApiResponse<AnalysisSchema[]>>('/analysis/schemas')
+  },
+
+  // 获取分析方案详情
+  getSchema: (name: string) => {
+    return api.get<ApiResponse<AnalysisSchema>>(`/analysis/schemas/${name}`)
   }
 }
\ No newline at end of file
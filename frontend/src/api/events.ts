@@ -0,0 +1,38 @@
+import type { DomainEvent, EventFilter } from '@/types'
+
+// 连接领域事件推送，断开后自动重连；返回的 subscribe 用于更换订阅条件，close 关闭连接
+export const connectEvents = (onEvent: (event: DomainEvent) => void, filter: EventFilter = {}) => {
+  let socket: WebSocket | null = null
+  let closed = false
+  let current = filter
+
+  const open = () => {
+    const protocol = location.protocol === 'https:' ? 'wss' : 'ws'
+    socket = new WebSocket(`${protocol}://${location.host}/api/ws`)
+    socket.onopen = () => {
+      socket?.send(JSON.stringify({ action: 'subscribe', ...current }))
+    }
+    socket.onmessage = (e) => {
+      onEvent(JSON.parse(e.data))
+    }
+    socket.onclose = () => {
+      if (!closed) {
+        setTimeout(open, 3000)
+      }
+    }
+  }
+  open()
+
+  return {
+    subscribe: (filter: EventFilter) => {
+      current = filter
+      if (socket?.readyState === WebSocket.OPEN) {
+        socket.send(JSON.stringify({ action: 'subscribe', ...current }))
+      }
+    },
+    close: () => {
+      closed = true
+      socket?.close()
+    }
+  }
+}
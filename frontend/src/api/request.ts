@@ -11,6 +11,11 @@ const api = axios.create({
 // 请求拦截器
 api.interceptors.request.use(
   (config) => {
+    // 编辑、管理员在本地保存的 API Key，用于修改和审核分析结果
+    const apiKey = localStorage.getItem('apiKey')
+    if (apiKey) {
+      config.headers['X-API-Key'] = apiKey
+    }
     return config
   },
   (error) => {
@@ -11,21 +11,170 @@ export interface Article {
   has_analysis?: boolean
 }
 
+// 引文在文章内容中的位置，start/end 按字符计
+export interface Evidence {
+  start: number
+  end: number
+  paragraph: number
+  text: string
+  score: number
+}
+
+export interface AnalysisItem {
+  title: string
+  explanation: string
+  quote: string
+  evidence?: Evidence
+  verified: boolean
+}
+
+export type AnalysisDimension = 'core_viewpoints' | 'file_structure' | 'author_thoughts' | 'related_materials'
+
+export type AnalysisResult = Record<AnalysisDimension, AnalysisItem[]>
+
+// 分析方案中的维度：items 为要点列表，text 为一段文字，list 为词语列表
+export interface SchemaField {
+  key: string
+  label: string
+  description?: string
+  type: 'items' | 'text' | 'list'
+}
+
+export interface AnalysisSchema {
+  name: string
+  description?: string
+  fields: SchemaField[]
+}
+
 export interface ArticleAnalysis {
   id: string
   article_id: string
+  // 文章的第几次分析，每次分析单独保存
+  version: number
+  is_current: boolean
   core_viewpoints: string
   file_structure: string
   author_thoughts: string
   related_materials: string
+  result?: AnalysisResult | null
+  prompt_version?: string
+  schema?: string
+  genre?: string
+  genre_source?: 'auto' | 'user'
+  // 自定义分析方案的结果，按维度的 key 存放
+  fields?: Record<string, AnalysisItem[] | string | string[]> | null
+  // 分析时自定义方案的维度快照
+  schema_fields?: SchemaField[] | null
   analysis_status: string
   analysis_time?: string
   error_message: string
+  // 人工审核状态，只有 reviewed 和 published 的结果向学生展示
+  review_status: ReviewStatus
+  // 人工修改和审核的次数，提交修改时带上以发现他人的并发修改
+  revision: number
   created_at: string
   updated_at: string
   article?: Article
 }
 
+export type ReviewStatus = 'draft' | 'reviewed' | 'published'
+
+// 分析结果的一次人工修改或审核状态变更
+export interface AnalysisRevision {
+  id: number
+  analysis_id: number
+  article_id: number
+  revision: number
+  editor: string
+  action: 'edit' | 'review'
+  changes: { field: string; before: any; after: any }[]
+  comment: string
+  created_at: string
+}
+
+// 服务端通过 /api/ws 推送的领域事件
+export type DomainEventType = 'article.created' | 'article.deleted' | 'analysis.status'
+
+export interface DomainEvent {
+  type: DomainEventType
+  article_id: string
+  author: string
+  data?: any
+  time: string
+}
+
+// 领域事件的订阅条件，为空的条件不限制
+export interface EventFilter {
+  types?: DomainEventType[]
+  article_ids?: string[]
+  authors?: string[]
+}
+
+// 分析任务事件，由 /analysis/tasks/:id/events 推送
+export type TaskEventType = 'queued' | 'started' | 'chunk' | 'retrying' | 'token' | 'completed' | 'failed' | 'cancelled'
+
+export interface TaskEvent {
+  type: TaskEventType
+  task_id: string
+  status?: string
+  progress?: number
+  chunk?: number
+  total?: number
+  attempt?: number
+  wait_ms?: number
+  delta?: string
+  error?: string
+  time: string
+}
+
+// 文章体裁及其使用的提示词版本
+export interface GenreInfo {
+  genre: string
+  label: string
+  prompt_version: string
+}
+
+// 两次分析的对比
+export interface ItemDiff {
+  status: 'unchanged' | 'changed' | 'added' | 'removed'
+  a?: AnalysisItem
+  b?: AnalysisItem
+  similarity: number
+}
+
+export interface FieldDiff {
+  key: string
+  label: string
+  type: SchemaField['type']
+  similarity: number
+  items?: ItemDiff[]
+  added?: string[]
+  removed?: string[]
+  common?: string[]
+  a?: string
+  b?: string
+}
+
+export interface AnalysisRun {
+  id: string
+  version: number
+  provider: string
+  profile: string
+  model: string
+  prompt_version: string
+  schema: string
+  genre: string
+  analysis_time?: string
+}
+
+export interface AnalysisComparison {
+  article_id: string
+  a: AnalysisRun
+  b: AnalysisRun
+  similarity: number
+  fields: FieldDiff[]
+}
+
 export interface PaginationRequest {
   page?: number
   page_size?: number
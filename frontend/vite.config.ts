@@ -20,6 +20,7 @@ export default defineConfig({
         '/api': {
           target: 'http://localhost:8080',
           changeOrigin: true,
+          ws: true,
           rewrite: (path) => path // 不添加v1前缀，直接传递原始路径
         }
       }